@@ -0,0 +1,244 @@
+package kurobako
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ProblemSpecBuilder builds a ProblemSpec in a fluent manner.
+//
+// Parameter modifiers such as Log and When are applied to the most recently added parameter.
+// The specification is validated when Build is called.
+//
+// For example:
+//
+//	spec, err := kurobako.NewProblemSpecBuilder("Quadratic Function").
+//		Continuous("x", -10.0, 10.0).
+//		Discrete("y", -3, 3).
+//		Objective("x**2 + y").
+//		Build()
+type ProblemSpecBuilder struct {
+	spec   ProblemSpec
+	steps  []uint64
+	last   int
+	errors []string
+}
+
+// NewProblemSpecBuilder creates a new ProblemSpecBuilder instance.
+func NewProblemSpecBuilder(name string) *ProblemSpecBuilder {
+	return &ProblemSpecBuilder{
+		spec: NewProblemSpec(name),
+		last: -1,
+	}
+}
+
+// Attr sets an attribute of the problem.
+func (r *ProblemSpecBuilder) Attr(key string, value string) *ProblemSpecBuilder {
+	r.spec.Attrs[key] = value
+	r.last = -1
+	return r
+}
+
+// Continuous adds a continuous parameter that has the range [low, high).
+func (r *ProblemSpecBuilder) Continuous(name string, low float64, high float64) *ProblemSpecBuilder {
+	v := NewVar(name)
+	v.Range = ContinuousRange{Low: low, High: high}.ToRange()
+	return r.addParam(v)
+}
+
+// Discrete adds a discrete parameter that has the range [low, high).
+func (r *ProblemSpecBuilder) Discrete(name string, low int64, high int64) *ProblemSpecBuilder {
+	v := NewVar(name)
+	v.Range = DiscreteRange{Low: low, High: high}.ToRange()
+	return r.addParam(v)
+}
+
+// Categorical adds a categorical parameter that has the given choices.
+func (r *ProblemSpecBuilder) Categorical(name string, choices ...string) *ProblemSpecBuilder {
+	v := NewVar(name)
+	v.Range = CategoricalRange{Choices: choices}.ToRange()
+	return r.addParam(v)
+}
+
+// Log makes the last added parameter log-uniformally distributed.
+func (r *ProblemSpecBuilder) Log() *ProblemSpecBuilder {
+	if v := r.lastParam("Log"); v != nil {
+		v.Distribution = LogUniform
+	}
+	return r
+}
+
+// When sets the constraint of the last added parameter.
+//
+// The constraint is a Lua script or expression (e.g., `opt == "adam"`) that refers to the preceding parameters (see Var.Constraint).
+func (r *ProblemSpecBuilder) When(constraint string) *ProblemSpecBuilder {
+	if v := r.lastParam("When"); v != nil {
+		v.Constraint = &constraint
+	}
+	return r
+}
+
+// Steps sets the evaluation steps of the problem.
+func (r *ProblemSpecBuilder) Steps(steps ...uint64) *ProblemSpecBuilder {
+	r.steps = steps
+	r.last = -1
+	return r
+}
+
+// Objective adds an objective value that is to be minimized.
+func (r *ProblemSpecBuilder) Objective(name string) *ProblemSpecBuilder {
+	r.spec.Values = append(r.spec.Values, NewVar(name))
+	r.last = -1
+	return r
+}
+
+// Build validates the configured specification and returns it.
+func (r *ProblemSpecBuilder) Build() (*ProblemSpec, error) {
+	errs := append([]string{}, r.errors...)
+
+	if r.spec.Name == "" {
+		errs = append(errs, "empty problem name")
+	}
+
+	names := map[string]bool{}
+	for _, v := range r.spec.Params {
+		if names[v.Name] {
+			errs = append(errs, fmt.Sprintf("duplicate param name %q", v.Name))
+		}
+		names[v.Name] = true
+
+		if err := validateVar(v); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(r.spec.Values) == 0 {
+		errs = append(errs, "no objective")
+	}
+	objectives := map[string]bool{}
+	for _, v := range r.spec.Values {
+		if objectives[v.Name] {
+			errs = append(errs, fmt.Sprintf("duplicate objective name %q", v.Name))
+		}
+		objectives[v.Name] = true
+	}
+
+	spec := r.spec
+	if r.steps != nil {
+		steps, err := NewSteps(r.steps)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			spec.Steps = *steps
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid problem spec: %s", strings.Join(errs, "; "))
+	}
+
+	spec.Attrs = make(map[string]string, len(r.spec.Attrs))
+	for k, v := range r.spec.Attrs {
+		spec.Attrs[k] = v
+	}
+	spec.Params = append([]Var{}, r.spec.Params...)
+	spec.Values = append([]Var{}, r.spec.Values...)
+	return &spec, nil
+}
+
+func (r *ProblemSpecBuilder) addParam(v Var) *ProblemSpecBuilder {
+	r.spec.Params = append(r.spec.Params, v)
+	r.last = len(r.spec.Params) - 1
+	return r
+}
+
+func (r *ProblemSpecBuilder) lastParam(method string) *Var {
+	if r.last < 0 {
+		r.errors = append(r.errors, fmt.Sprintf("%s should follow a parameter definition", method))
+		return nil
+	}
+	return &r.spec.Params[r.last]
+}
+
+func validateVar(v Var) error {
+	if v.Name == "" {
+		return fmt.Errorf("empty param name")
+	}
+
+	if x := v.Range.AsContinuousRange(); x != nil {
+		if math.IsNaN(x.Low) || math.IsNaN(x.High) || !(x.Low < x.High) {
+			return fmt.Errorf("param %q has an empty range: [%v, %v)", v.Name, x.Low, x.High)
+		}
+	} else if x := v.Range.AsDiscreteRange(); x != nil {
+		if x.Low >= x.High {
+			return fmt.Errorf("param %q has an empty range: [%v, %v)", v.Name, x.Low, x.High)
+		}
+	} else if x := v.Range.AsCategoricalRange(); x != nil {
+		if len(x.Choices) == 0 {
+			return fmt.Errorf("param %q has no choices", v.Name)
+		}
+		choices := map[string]bool{}
+		for _, c := range x.Choices {
+			if choices[c] {
+				return fmt.Errorf("param %q has a duplicate choice %q", v.Name, c)
+			}
+			choices[c] = true
+		}
+		if v.Distribution == LogUniform {
+			return fmt.Errorf("categorical param %q can't be log-uniformally distributed", v.Name)
+		}
+	}
+
+	if v.Distribution == LogUniform && !(v.Range.Low() > 0.0 && isFinite(v.Range.High())) {
+		return fmt.Errorf("log-uniform param %q should have a positive and finite range", v.Name)
+	}
+
+	if v.Constraint != nil {
+		if _, err := DefaultConstraintEvaluator().Compile(*v.Constraint); err != nil {
+			return fmt.Errorf("param %q has a malformed constraint: %v", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// SolverSpecBuilder builds a SolverSpec in a fluent manner.
+type SolverSpecBuilder struct {
+	spec SolverSpec
+}
+
+// NewSolverSpecBuilder creates a new SolverSpecBuilder instance.
+//
+// The capabilities of the solver default to AllCapabilities.
+func NewSolverSpecBuilder(name string) *SolverSpecBuilder {
+	return &SolverSpecBuilder{NewSolverSpec(name)}
+}
+
+// Attr sets an attribute of the solver.
+func (r *SolverSpecBuilder) Attr(key string, value string) *SolverSpecBuilder {
+	r.spec.Attrs[key] = value
+	return r
+}
+
+// Capabilities sets the capabilities of the solver.
+func (r *SolverSpecBuilder) Capabilities(capabilities Capabilities) *SolverSpecBuilder {
+	r.spec.Capabilities = capabilities
+	return r
+}
+
+// Build validates the configured specification and returns it.
+func (r *SolverSpecBuilder) Build() (*SolverSpec, error) {
+	if r.spec.Name == "" {
+		return nil, fmt.Errorf("invalid solver spec: empty solver name")
+	}
+	if r.spec.Capabilities & ^AllCapabilities != 0 {
+		return nil, fmt.Errorf("invalid solver spec: unknown capabilities: %v", int64(r.spec.Capabilities))
+	}
+
+	spec := r.spec
+	spec.Attrs = make(map[string]string, len(r.spec.Attrs))
+	for k, v := range r.spec.Attrs {
+		spec.Attrs[k] = v
+	}
+	return &spec, nil
+}
@@ -0,0 +1,99 @@
+package kurobako
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProblemSpecBuilder(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("Quadratic Function").
+		Attr("version", "1").
+		Continuous("x", -10.0, 10.0).
+		Discrete("y", -3, 3).
+		Categorical("opt", "sgd", "adam").
+		Continuous("lr", 1e-5, 1e-1).Log().When("opt == 'adam'").
+		Steps(1, 2, 3).
+		Objective("x**2 + y").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := NewProblemSpec("Quadratic Function")
+	expected.Attrs["version"] = "1"
+
+	x := NewVar("x")
+	x.Range = ContinuousRange{-10.0, 10.0}.ToRange()
+
+	y := NewVar("y")
+	y.Range = DiscreteRange{-3, 3}.ToRange()
+
+	opt := NewVar("opt")
+	opt.Range = CategoricalRange{[]string{"sgd", "adam"}}.ToRange()
+
+	lr := NewVar("lr")
+	lr.Range = ContinuousRange{1e-5, 1e-1}.ToRange()
+	lr.Distribution = LogUniform
+	constraint := "opt == 'adam'"
+	lr.Constraint = &constraint
+
+	expected.Params = []Var{x, y, opt, lr}
+	expected.Values = []Var{NewVar("x**2 + y")}
+	steps, _ := NewSteps([]uint64{1, 2, 3})
+	expected.Steps = *steps
+
+	if !reflect.DeepEqual(*spec, expected) {
+		t.Fatalf("unexpected spec: %v", *spec)
+	}
+
+	adam, sgd := 1.0, 0.0
+	satisfied, err := lr.IsConstraintSatisfied(spec.Params, []*float64{nil, nil, &adam})
+	if err != nil || !satisfied {
+		t.Fatalf("unexpected constraint result: %v (err=%v)", satisfied, err)
+	}
+	satisfied, err = lr.IsConstraintSatisfied(spec.Params, []*float64{nil, nil, &sgd})
+	if err != nil || satisfied {
+		t.Fatalf("unexpected constraint result: %v (err=%v)", satisfied, err)
+	}
+}
+
+func TestProblemSpecBuilderValidation(t *testing.T) {
+	builders := []*ProblemSpecBuilder{
+		NewProblemSpecBuilder("").Continuous("x", 0, 1).Objective("v"),
+		NewProblemSpecBuilder("p").Continuous("x", 0, 1),
+		NewProblemSpecBuilder("p").Continuous("x", 1, 0).Objective("v"),
+		NewProblemSpecBuilder("p").Continuous("x", 0, 1).Log().Objective("v"),
+		NewProblemSpecBuilder("p").Discrete("x", 0, 1).Discrete("x", 0, 1).Objective("v"),
+		NewProblemSpecBuilder("p").Categorical("x").Objective("v"),
+		NewProblemSpecBuilder("p").Categorical("x", "a", "a").Objective("v"),
+		NewProblemSpecBuilder("p").Continuous("x", 0, 1).When("x ==").Objective("v"),
+		NewProblemSpecBuilder("p").Log().Continuous("x", 0, 1).Objective("v"),
+		NewProblemSpecBuilder("p").Continuous("x", 0, 1).Steps(3, 2).Objective("v"),
+	}
+	for i, b := range builders {
+		if _, err := b.Build(); err == nil {
+			t.Fatalf("expected an error: %d", i)
+		}
+	}
+}
+
+func TestSolverSpecBuilder(t *testing.T) {
+	spec, err := NewSolverSpecBuilder("Random Search").
+		Attr("version", "1").
+		Capabilities(UniformContinuous | Concurrent).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := NewSolverSpec("Random Search")
+	expected.Attrs["version"] = "1"
+	expected.Capabilities = UniformContinuous | Concurrent
+	if !reflect.DeepEqual(*spec, expected) {
+		t.Fatalf("unexpected spec: %v", *spec)
+	}
+
+	if _, err := NewSolverSpecBuilder("").Build(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
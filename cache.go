@@ -0,0 +1,64 @@
+package kurobako
+
+import (
+	"container/list"
+	"sync"
+)
+
+// constraintCacheSize is the maximum number of the compiled constraint scripts cached by an evaluator.
+const constraintCacheSize = 1024
+
+// lruCache is a cache that evicts the least recently used entry when the number of the entries exceeds its capacity.
+//
+// An lruCache instance is safe for concurrent use.
+type lruCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{capacity: capacity, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (r *lruCache) get(key string) (interface{}, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	r.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (r *lruCache) put(key string, value interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if e, ok := r.entries[key]; ok {
+		e.Value.(*lruEntry).value = value
+		r.order.MoveToFront(e)
+		return
+	}
+
+	r.entries[key] = r.order.PushFront(&lruEntry{key, value})
+	if r.order.Len() > r.capacity {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (r *lruCache) len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.order.Len()
+}
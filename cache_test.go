@@ -0,0 +1,26 @@
+package kurobako
+
+import (
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.put("a", 1)
+	cache.put("b", 2)
+	if v, ok := cache.get("a"); !ok || v != 1 {
+		t.Fatalf("unexpected value: %v (ok=%v)", v, ok)
+	}
+
+	// "b" is the least recently used entry.
+	cache.put("c", 3)
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("expected an evicted entry")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected a cached entry")
+	}
+	if cache.len() != 2 {
+		t.Fatalf("unexpected size: %d", cache.len())
+	}
+}
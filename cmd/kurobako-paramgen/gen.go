@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sile/kurobako-go"
+)
+
+func parseSpec(data []byte) (*kurobako.ProblemSpec, error) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		// The input may be the output of a problem command (i.e., a PROBLEM_SPEC_CAST message followed by others).
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[:i]
+		}
+	}
+
+	var message struct {
+		Type string          `json:"type"`
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	if message.Type != "" {
+		if message.Type != "PROBLEM_SPEC_CAST" {
+			return nil, fmt.Errorf("expected a PROBLEM_SPEC_CAST message, got %q", message.Type)
+		}
+		data = message.Spec
+	}
+
+	var spec kurobako.ProblemSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+type field struct {
+	name     string
+	v        kurobako.Var
+	optional bool
+}
+
+func (r *field) goType() string {
+	var t string
+	if r.v.Range.AsDiscreteRange() != nil {
+		t = "int64"
+	} else if r.v.Range.AsCategoricalRange() != nil {
+		t = "string"
+	} else {
+		t = "float64"
+	}
+
+	if r.optional {
+		return "*" + t
+	}
+	return t
+}
+
+func generate(spec *kurobako.ProblemSpec, typeName string, packageName string) ([]byte, error) {
+	names := newIdentSet()
+	names.add(typeName)
+	names.add("Decode" + typeName)
+	choiceDecoder := names.add("decode" + typeName + "Choice")
+
+	fields := make([]field, 0, len(spec.Params))
+	for _, v := range spec.Params {
+		fields = append(fields, field{names.add(toIdent(v.Name)), v, v.Constraint != nil})
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by kurobako-paramgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", packageName)
+	fmt.Fprintf(&b, "import \"fmt\"\n\n")
+
+	fmt.Fprintf(&b, "// %s is the parameters of the %s problem.\n", typeName, strconv.Quote(spec.Name))
+	fmt.Fprintf(&b, "type %s struct {\n", typeName)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "// %s is the value of the %s parameter", f.name, strconv.Quote(f.v.Name))
+		if f.optional {
+			fmt.Fprintf(&b, " (nil if inactive)")
+		}
+		fmt.Fprintf(&b, ".\n%s %s\n", f.name, f.goType())
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	for _, f := range fields {
+		x := f.v.Range.AsCategoricalRange()
+		if x == nil {
+			continue
+		}
+
+		fmt.Fprintf(&b, "// Choices of the %s parameter.\n", strconv.Quote(f.v.Name))
+		fmt.Fprintf(&b, "const (\n")
+		for _, c := range x.Choices {
+			fmt.Fprintf(&b, "%s = %s\n", names.add(f.name+toIdent(c)), strconv.Quote(c))
+		}
+		fmt.Fprintf(&b, ")\n\n")
+	}
+
+	fmt.Fprintf(&b, "// Decode%s decodes the given parameter values into a %s instance.\n", typeName, typeName)
+	fmt.Fprintf(&b, "//\n// A nil element in the params argument means that the corresponding parameter is inactive.\n")
+	fmt.Fprintf(&b, "func Decode%s(params []*float64) (*%s, error) {\n", typeName, typeName)
+	fmt.Fprintf(&b, "if len(params) != %d {\n", len(fields))
+	fmt.Fprintf(&b, "return nil, fmt.Errorf(\"expected %d params, got %%d\", len(params))\n}\n\n", len(fields))
+	fmt.Fprintf(&b, "var r %s\n", typeName)
+	if hasCategorical(fields, true) {
+		fmt.Fprintf(&b, "var err error\n")
+	}
+	for i, f := range fields {
+		value := fmt.Sprintf("*params[%d]", i)
+		if f.v.Range.AsDiscreteRange() != nil {
+			value = fmt.Sprintf("int64(%s)", value)
+		}
+
+		x := f.v.Range.AsCategoricalRange()
+		if x != nil {
+			choices := make([]string, 0, len(x.Choices))
+			for _, c := range x.Choices {
+				choices = append(choices, strconv.Quote(c))
+			}
+			value = fmt.Sprintf("%s(%s, []string{%s}, %s)",
+				choiceDecoder, strconv.Quote(f.v.Name), strings.Join(choices, ", "), value)
+		}
+
+		if f.optional {
+			fmt.Fprintf(&b, "if params[%d] != nil {\n", i)
+			if x != nil {
+				fmt.Fprintf(&b, "v, err := %s\n", value)
+				fmt.Fprintf(&b, "if err != nil {\nreturn nil, err\n}\n")
+			} else {
+				fmt.Fprintf(&b, "v := %s\n", value)
+			}
+			fmt.Fprintf(&b, "r.%s = &v\n}\n", f.name)
+			continue
+		}
+
+		fmt.Fprintf(&b, "if params[%d] == nil {\n", i)
+		fmt.Fprintf(&b, "return nil, fmt.Errorf(\"param %%q is inactive\", %s)\n}\n", strconv.Quote(f.v.Name))
+		if x != nil {
+			fmt.Fprintf(&b, "if r.%s, err = %s; err != nil {\nreturn nil, err\n}\n", f.name, value)
+		} else {
+			fmt.Fprintf(&b, "r.%s = %s\n", f.name, value)
+		}
+	}
+	fmt.Fprintf(&b, "return &r, nil\n}\n")
+
+	if hasCategorical(fields, false) {
+		writeChoiceDecoder(&b, choiceDecoder)
+	}
+	return format.Source(b.Bytes())
+}
+
+func writeChoiceDecoder(b *bytes.Buffer, name string) {
+	fmt.Fprintf(b, "\nfunc %s(name string, choices []string, value float64) (string, error) {\n", name)
+	fmt.Fprintf(b, "index := int(value)\n")
+	fmt.Fprintf(b, "if index < 0 || index >= len(choices) {\n")
+	fmt.Fprintf(b, "return \"\", fmt.Errorf(\"param %%q has an out of range choice index: %%v\", name, value)\n}\n")
+	fmt.Fprintf(b, "return choices[index], nil\n}\n")
+}
+
+func hasCategorical(fields []field, requiredOnly bool) bool {
+	for _, f := range fields {
+		if !(requiredOnly && f.optional) && f.v.Range.AsCategoricalRange() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// toIdent converts the given name to an exported Go identifier (e.g., "learning-rate" to "LearningRate").
+func toIdent(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(c) {
+			b.WriteByte('X')
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		b.WriteRune(c)
+	}
+
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+type identSet map[string]bool
+
+func newIdentSet() identSet {
+	return identSet{}
+}
+
+// add registers the given identifier and returns it (a numeric suffix is appended if the identifier is already used).
+func (r identSet) add(ident string) string {
+	unique := ident
+	for i := 2; r[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", ident, i)
+	}
+	r[unique] = true
+	return unique
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestToIdent(t *testing.T) {
+	for name, expected := range map[string]string{
+		"learning-rate": "LearningRate",
+		"x**2 + y":      "X2Y",
+		"2nd_layer":     "X2ndLayer",
+		"---":           "X",
+	} {
+		if ident := toIdent(name); ident != expected {
+			t.Fatalf("unexpected identifier for %q: %v", name, ident)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	input := `{"type":"PROBLEM_SPEC_CAST","spec":{"name":"foo","attrs":{},"params_domain":[` +
+		`{"name":"x","range":{"type":"CONTINUOUS","low":0,"high":1},"distribution":"UNIFORM","constraint":null},` +
+		`{"name":"opt","range":{"type":"CATEGORICAL","choices":["sgd","adam"]},"distribution":"UNIFORM","constraint":null},` +
+		`{"name":"n","range":{"type":"DISCRETE","low":1,"high":4},"distribution":"UNIFORM","constraint":"return opt == \"sgd\""}` +
+		`],"values_domain":[],"steps":1}}` + "\n" + `{"type":"SOMETHING_ELSE"}`
+
+	spec, err := parseSpec([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := generate(spec, "FooParams", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeCheck(t, code)
+	for _, s := range []string{
+		"package foo",
+		"type FooParams struct",
+		"X float64",
+		"Opt string",
+		"N *int64",
+		`OptAdam = "adam"`,
+		"func DecodeFooParams(params []*float64) (*FooParams, error)",
+	} {
+		if !strings.Contains(string(code), s) {
+			t.Fatalf("generated code doesn't contain %q:\n%s", s, code)
+		}
+	}
+}
+
+func TestGenerateTypeChecks(t *testing.T) {
+	for _, params := range []string{
+		// No categorical parameters.
+		`{"name":"x","range":{"type":"CONTINUOUS","low":0,"high":1},"distribution":"UNIFORM","constraint":null},` +
+			`{"name":"n","range":{"type":"DISCRETE","low":1,"high":10},"distribution":"UNIFORM","constraint":null}`,
+		// Only optional categorical parameters.
+		`{"name":"x","range":{"type":"CONTINUOUS","low":0,"high":1},"distribution":"UNIFORM","constraint":null},` +
+			`{"name":"kind","range":{"type":"CATEGORICAL","choices":["a","b"]},"distribution":"UNIFORM","constraint":"return x < 0.5"}`,
+		// Required and optional categorical parameters with conflicting identifiers.
+		`{"name":"kind","range":{"type":"CATEGORICAL","choices":["a","b"]},"distribution":"UNIFORM","constraint":null},` +
+			`{"name":"kind-a","range":{"type":"CATEGORICAL","choices":["x-y","x_y"]},"distribution":"UNIFORM","constraint":"return kind == \"a\""},` +
+			`{"name":"n","range":{"type":"DISCRETE","low":1,"high":10},"distribution":"LOG_UNIFORM","constraint":"return kind == \"b\""}`,
+	} {
+		input := `{"type":"PROBLEM_SPEC_CAST","spec":{"name":"foo","attrs":{},"params_domain":[` + params +
+			`],"values_domain":[],"steps":1}}`
+		spec, err := parseSpec([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, err := generate(spec, "Params", "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		typeCheck(t, code)
+	}
+}
+
+// typeCheck parses and type-checks the generated code.
+func typeCheck(t *testing.T, code []byte) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "params.go", code, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code doesn't parse: %v\n%s", err, code)
+	}
+
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := config.Check("foo", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated code doesn't type-check: %v\n%s", err, code)
+	}
+}
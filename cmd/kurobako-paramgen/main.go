@@ -0,0 +1,70 @@
+// Command kurobako-paramgen generates a Go struct that represents the parameters of a kurobako problem.
+//
+// It reads a problem specification from a JSON file (or the standard input if the file name is "-") and
+// emits a struct, a decoder from []*float64 and constants for the choices of categorical parameters.
+// The input may be either a raw ProblemSpec or a PROBLEM_SPEC_CAST message sent by a problem.
+//
+// Usage:
+//
+//	kurobako-paramgen -spec spec.json [-type Params] [-package name] [-o params_gen.go]
+//
+// It is intended to be used with `go generate`:
+//
+//	//go:generate sh -c "go run ./problem < /dev/null | kurobako-paramgen -spec - -o params_gen.go"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "a JSON file that contains a ProblemSpec or a PROBLEM_SPEC_CAST message (\"-\" means stdin)")
+	typeName := flag.String("type", "Params", "the name of the generated struct")
+	packageName := flag.String("package", os.Getenv("GOPACKAGE"), "the package name of the generated file")
+	output := flag.String("o", "", "the output file (defaults to stdout)")
+	flag.Parse()
+
+	if err := run(*specPath, *typeName, *packageName, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "kurobako-paramgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath string, typeName string, packageName string, output string) error {
+	if specPath == "" {
+		return fmt.Errorf("-spec is required")
+	}
+	if packageName == "" {
+		packageName = "main"
+	}
+
+	var data []byte
+	var err error
+	if specPath == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(specPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	spec, err := parseSpec(data)
+	if err != nil {
+		return err
+	}
+
+	code, err := generate(spec, typeName, packageName)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(output, code, 0644)
+}
@@ -0,0 +1,296 @@
+package kurobako
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Condition is a condition on preceding parameters that can be used as the constraint of a conditional parameter.
+//
+// A Condition is built by When and combined by And, Or and Not, for example:
+//
+//	cond := kurobako.When("optimizer").Eq("adam").And(kurobako.When("layers").Gt(2))
+type Condition struct {
+	expr string
+	refs []conditionRef
+	err  error
+}
+
+type conditionRef struct {
+	name  string
+	value interface{}
+}
+
+// ConditionVar is a parameter that is referred to by a Condition.
+type ConditionVar struct {
+	name string
+}
+
+// When starts a Condition on the parameter that has the given name.
+func When(name string) ConditionVar {
+	return ConditionVar{name}
+}
+
+// Eq returns a Condition that is satisfied if the parameter is equal to the given value.
+//
+// The value should be a string (the choice of a categorical parameter), a bool or a number.
+func (r ConditionVar) Eq(value interface{}) Condition {
+	return r.compare("==", value)
+}
+
+// Ne returns a Condition that is satisfied if the parameter is not equal to the given value.
+func (r ConditionVar) Ne(value interface{}) Condition {
+	return r.compare("~=", value)
+}
+
+// Lt returns a Condition that is satisfied if the parameter is less than the given number.
+func (r ConditionVar) Lt(value interface{}) Condition {
+	return r.compare("<", value)
+}
+
+// Le returns a Condition that is satisfied if the parameter is less than or equal to the given number.
+func (r ConditionVar) Le(value interface{}) Condition {
+	return r.compare("<=", value)
+}
+
+// Gt returns a Condition that is satisfied if the parameter is greater than the given number.
+func (r ConditionVar) Gt(value interface{}) Condition {
+	return r.compare(">", value)
+}
+
+// Ge returns a Condition that is satisfied if the parameter is greater than or equal to the given number.
+func (r ConditionVar) Ge(value interface{}) Condition {
+	return r.compare(">=", value)
+}
+
+// In returns a Condition that is satisfied if the parameter is equal to one of the given values.
+func (r ConditionVar) In(values ...interface{}) Condition {
+	if len(values) == 0 {
+		return Condition{err: fmt.Errorf("In(%q) requires at least one value", r.name)}
+	}
+
+	c := r.Eq(values[0])
+	for _, v := range values[1:] {
+		c = c.Or(r.Eq(v))
+	}
+	return c
+}
+
+// IsActive returns a Condition that is satisfied if the parameter is active (i.e., it has a value).
+func (r ConditionVar) IsActive() Condition {
+	if !isLuaName(r.name) {
+		return Condition{err: fmt.Errorf("%q can't be referred to from a Lua script", r.name)}
+	}
+	return Condition{
+		expr: fmt.Sprintf("%s ~= nil", r.name),
+		refs: []conditionRef{{r.name, nil}},
+	}
+}
+
+func (r ConditionVar) compare(op string, value interface{}) Condition {
+	if !isLuaName(r.name) {
+		return Condition{err: fmt.Errorf("%q can't be referred to from a Lua script", r.name)}
+	}
+
+	literal, err := toLuaLiteral(value)
+	if err != nil {
+		return Condition{err: err}
+	}
+	_, isString := value.(string)
+	_, isBool := value.(bool)
+	if op != "==" && op != "~=" && (isString || isBool) {
+		return Condition{err: fmt.Errorf("%q can't be compared with %v by %s", r.name, value, op)}
+	}
+
+	expr := fmt.Sprintf("%s %s %s", r.name, op, literal)
+	if op != "==" && op != "~=" {
+		// Inactive parameters are bound to nil, and comparing nil with a number raises a Lua error.
+		expr = fmt.Sprintf("(%s ~= nil and %s)", r.name, expr)
+	}
+	return Condition{expr: expr, refs: []conditionRef{{r.name, value}}}
+}
+
+// And returns a Condition that is satisfied if both the receiver and the given conditions are satisfied.
+func (r Condition) And(other Condition) Condition {
+	return r.combine("and", other)
+}
+
+// Or returns a Condition that is satisfied if the receiver or the given condition is satisfied.
+func (r Condition) Or(other Condition) Condition {
+	return r.combine("or", other)
+}
+
+// Not returns a Condition that is satisfied if the given condition isn't satisfied.
+func Not(c Condition) Condition {
+	if c.err != nil {
+		return c
+	}
+	return Condition{expr: fmt.Sprintf("not (%s)", c.expr), refs: c.refs}
+}
+
+func (r Condition) combine(op string, other Condition) Condition {
+	if r.err != nil {
+		return r
+	}
+	if other.err != nil {
+		return other
+	}
+
+	refs := append(append([]conditionRef{}, r.refs...), other.refs...)
+	return Condition{expr: fmt.Sprintf("(%s) %s (%s)", r.expr, op, other.expr), refs: refs}
+}
+
+// Lua returns the Lua script that represents the condition.
+func (r Condition) Lua() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return "return " + r.expr, nil
+}
+
+// Vars returns the names of the parameters referred to by the condition.
+func (r Condition) Vars() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, ref := range r.refs {
+		if !seen[ref.name] {
+			names = append(names, ref.name)
+			seen[ref.name] = true
+		}
+	}
+	return names
+}
+
+// check validates the condition against the given preceding parameters.
+func (r Condition) check(preceding []Var) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for _, ref := range r.refs {
+		var v *Var
+		for i := range preceding {
+			if preceding[i].Name == ref.name {
+				v = &preceding[i]
+			}
+		}
+		if v == nil {
+			return fmt.Errorf("condition refers to an unknown or succeeding param %q", ref.name)
+		}
+		if ref.value == nil {
+			continue
+		}
+
+		x := v.Range.AsCategoricalRange()
+		s, isString := ref.value.(string)
+		if b, ok := ref.value.(bool); ok {
+			s, isString = strconv.FormatBool(b), true
+		}
+
+		if x == nil && isString {
+			return fmt.Errorf("numerical param %q is compared with a string %q", ref.name, s)
+		}
+		if x != nil && !isString {
+			return fmt.Errorf("categorical param %q is compared with a number %v", ref.name, ref.value)
+		}
+		if x != nil {
+			found := false
+			for _, c := range x.Choices {
+				found = found || c == s
+			}
+			if !found {
+				return fmt.Errorf("categorical param %q doesn't have the choice %q", ref.name, s)
+			}
+		}
+	}
+	return nil
+}
+
+// If sets the given condition as the constraint of the last added parameter.
+//
+// The parameters referred to by the condition are validated when Build is called.
+func (r *ProblemSpecBuilder) If(cond Condition) *ProblemSpecBuilder {
+	v := r.lastParam("If")
+	if v == nil {
+		return r
+	}
+
+	if err := cond.check(r.spec.Params[:r.last]); err != nil {
+		r.errors = append(r.errors, fmt.Sprintf("param %q: %v", v.Name, err))
+		return r
+	}
+
+	constraint, _ := cond.Lua()
+	v.Constraint = &constraint
+	return r
+}
+
+func toLuaLiteral(value interface{}) (string, error) {
+	switch x := value.(type) {
+	case string:
+		return luaQuote(x), nil
+	case bool:
+		// Categorical parameters are bound to their choices (i.e., strings).
+		return luaQuote(strconv.FormatBool(x)), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("non-finite value can't be used in a condition: %v", f)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value in a condition: %v (%T)", value, value)
+	}
+}
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+func isLuaName(name string) bool {
+	if name == "" || luaKeywords[name] {
+		return false
+	}
+	for i, c := range name {
+		isAlpha := c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+		isDigit := '0' <= c && c <= '9'
+		if !isAlpha && !(i > 0 && isDigit) {
+			return false
+		}
+	}
+	return true
+}
+
+// luaQuote returns a Lua string literal that represents the given string.
+func luaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package kurobako
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionLua(t *testing.T) {
+	cond := When("optimizer").Eq("adam").And(When("layers").Gt(2))
+	script, err := cond.Lua()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script != `return (optimizer == "adam") and ((layers ~= nil and layers > 2))` {
+		t.Fatalf("unexpected script: %s", script)
+	}
+	if !reflect.DeepEqual(cond.Vars(), []string{"optimizer", "layers"}) {
+		t.Fatalf("unexpected vars: %v", cond.Vars())
+	}
+
+	script, _ = Not(When("name").In("a\"b", "c\n")).Lua()
+	if script != `return not ((name == "a\"b") or (name == "c\010"))` {
+		t.Fatalf("unexpected script: %s", script)
+	}
+
+	for _, c := range []Condition{
+		When("x").Gt("foo"),
+		When("x").Eq([]int{1}),
+		When("and").Eq(1),
+		When("and").IsActive(),
+		When("x-y").IsActive(),
+		When("x").In(),
+		When("x").Eq(1).Or(When("y").Lt(true)),
+	} {
+		if _, err := c.Lua(); err == nil {
+			t.Fatalf("expected an error: %v", c)
+		}
+	}
+}
+
+func TestProblemSpecBuilderIf(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Discrete("layers", 1, 5).
+		Continuous("beta", 0.0, 1.0).If(When("optimizer").Eq("adam").And(When("layers").Gt(2))).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sgd := 0.0
+	adam := 1.0
+	three := 3.0
+	for _, c := range []struct {
+		vals      []*float64
+		satisfied bool
+	}{
+		{[]*float64{&adam, &three}, true},
+		{[]*float64{&sgd, &three}, false},
+		{[]*float64{&adam, nil}, false},
+	} {
+		satisfied, err := spec.Params[2].IsConstraintSatisfied(spec.Params, c.vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if satisfied != c.satisfied {
+			t.Fatalf("unexpected constraint result: %v", satisfied)
+		}
+	}
+
+	for _, cond := range []Condition{
+		When("unknown").Eq(1),
+		When("optimizer").Eq("rmsprop"),
+		When("optimizer").Eq(1),
+		When("layers").Eq("adam"),
+		When("beta").Lt(0.5),
+	} {
+		_, err := NewProblemSpecBuilder("foo").
+			Categorical("optimizer", "sgd", "adam").
+			Discrete("layers", 1, 5).
+			Continuous("beta", 0.0, 1.0).If(cond).
+			Objective("v").
+			Build()
+		if err == nil {
+			t.Fatalf("expected an error: %v", cond)
+		}
+	}
+}
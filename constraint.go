@@ -0,0 +1,411 @@
+package kurobako
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// CompiledConstraints holds the compiled constraints of a parameters domain.
+//
+// Each constraint is compiled only once, and only the variables that a constraint actually refers to
+// are bound when it is evaluated.
+// A CompiledConstraints instance is safe for concurrent use.
+type CompiledConstraints struct {
+	vars        []Var
+	constraints []*compiledConstraint
+}
+
+type compiledConstraint struct {
+	script CompiledConstraint
+
+	// refs maps the names referred to by the script to the indices of the variables.
+	refs map[string]int
+}
+
+// CompileConstraints compiles the constraints of the given parameters domain (e.g., ProblemSpec.Params)
+// with the default ConstraintEvaluator.
+func CompileConstraints(params []Var) (*CompiledConstraints, error) {
+	return CompileConstraintsWith(params, DefaultConstraintEvaluator())
+}
+
+// CompileConstraintsWith compiles the constraints of the given parameters domain with the given ConstraintEvaluator.
+func CompileConstraintsWith(params []Var, evaluator ConstraintEvaluator) (*CompiledConstraints, error) {
+	indices := make(map[string]int, len(params))
+	for i, v := range params {
+		indices[v.Name] = i
+	}
+
+	constraints := make([]*compiledConstraint, len(params))
+	for i, v := range params {
+		if v.Constraint == nil {
+			continue
+		}
+
+		script, err := evaluator.Compile(*v.Constraint)
+		if err != nil {
+			return nil, fmt.Errorf("param %q has a malformed constraint: %v", v.Name, err)
+		}
+
+		refs := map[string]int{}
+		for _, name := range script.Names() {
+			if j, ok := indices[name]; ok {
+				refs[name] = j
+			}
+		}
+		constraints[i] = &compiledConstraint{script, refs}
+	}
+
+	return &CompiledConstraints{params, constraints}, nil
+}
+
+// IsSatisfied checks whether the constraint of the i-th parameter is satisfied under the given bound (i.e., already evaluated) values.
+//
+// This is equivalent to params[i].IsConstraintSatisfied(params, vals).
+func (r *CompiledConstraints) IsSatisfied(i int, vals []*float64) (bool, error) {
+	c := r.constraints[i]
+	if c == nil {
+		return true, nil
+	}
+
+	satisfied, err := c.script.Eval(func(name string) (interface{}, error) {
+		j, ok := c.refs[name]
+		if !ok || j >= len(vals) {
+			return nil, nil
+		}
+		return constraintValue(r.vars[j], vals[j])
+	})
+	if err != nil {
+		return false, fmt.Errorf("constraint of param %q: %w", r.vars[i].Name, err)
+	}
+	return satisfied, nil
+}
+
+// ActiveMask returns whether each parameter of a trial is active.
+//
+// The constraint of each parameter is evaluated under the preceding parameters.
+// Values of the parameters that turn out to be inactive aren't bound even if they are non-nil.
+func (r *CompiledConstraints) ActiveMask(vals []*float64) ([]bool, error) {
+	if len(vals) != len(r.vars) {
+		return nil, fmt.Errorf("expected %d params, got %d", len(r.vars), len(vals))
+	}
+
+	mask := make([]bool, len(vals))
+	bound := make([]*float64, len(vals))
+	for i := range vals {
+		satisfied, err := r.IsSatisfied(i, bound[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		mask[i] = satisfied
+		if satisfied {
+			bound[i] = vals[i]
+		}
+	}
+	return mask, nil
+}
+
+// DropInactive sets the values of the inactive parameters of a trial to nil (in place), and returns the active mask.
+//
+// This is useful for a solver that samples all the parameters regardless of the constraints.
+func (r *CompiledConstraints) DropInactive(vals []*float64) ([]bool, error) {
+	mask, err := r.ActiveMask(vals)
+	if err != nil {
+		return nil, err
+	}
+	for i, active := range mask {
+		if !active {
+			vals[i] = nil
+		}
+	}
+	return mask, nil
+}
+
+type compiledScript struct {
+	proto *lua.FunctionProto
+
+	// names is the global names referred to by the script.
+	names []string
+}
+
+var compiledScripts = newLRUCache(constraintCacheSize)
+
+// loadConstraint returns the compiled version of the given constraint script.
+//
+// Compiled scripts are cached (up to constraintCacheSize scripts), so each script is usually compiled only once.
+func loadConstraint(script string) (*compiledScript, error) {
+	if compiled, ok := compiledScripts.get(script); ok {
+		return compiled.(*compiledScript), nil
+	}
+
+	compiled, err := compileConstraint(script)
+	if err != nil {
+		return nil, err
+	}
+	compiledScripts.put(script, compiled)
+	return compiled, nil
+}
+
+// compileConstraint compiles the given constraint script.
+//
+// A script consisting of a single expression is compiled as `return <expression>` (see Var.Constraint).
+func compileConstraint(script string) (*compiledScript, error) {
+	chunk, err := parse.Parse(strings.NewReader("return "+script), "<constraint>")
+	if err != nil {
+		chunk, err = parse.Parse(strings.NewReader(script), "<constraint>")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	c := &freeNameCollector{f: func(name string) {
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}}
+	c.block(chunk)
+
+	sandboxConcat(chunk)
+	proto, err := lua.Compile(chunk, "<constraint>")
+	if err != nil {
+		return nil, err
+	}
+	return &compiledScript{proto, names}, nil
+}
+
+// freeNameCollector walks a Lua AST and calls f with the name of each global variable that is read.
+//
+// Names bound by `local` declarations, `for` statements and function parameters are skipped within their scopes.
+type freeNameCollector struct {
+	scopes []map[string]bool
+	f      func(string)
+}
+
+func (r *freeNameCollector) declare(names ...string) {
+	for _, name := range names {
+		r.scopes[len(r.scopes)-1][name] = true
+	}
+}
+
+func (r *freeNameCollector) isLocal(name string) bool {
+	for _, scope := range r.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *freeNameCollector) push() {
+	r.scopes = append(r.scopes, map[string]bool{})
+}
+
+func (r *freeNameCollector) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *freeNameCollector) block(stmts []ast.Stmt) {
+	r.push()
+	r.stmts(stmts)
+	r.pop()
+}
+
+func (r *freeNameCollector) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		r.stmt(stmt)
+	}
+}
+
+func (r *freeNameCollector) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		for _, e := range s.Lhs {
+			// Assigning to a global variable isn't a reference to it.
+			if _, ok := e.(*ast.IdentExpr); !ok {
+				r.expr(e)
+			}
+		}
+		r.exprs(s.Rhs)
+	case *ast.LocalAssignStmt:
+		r.exprs(s.Exprs)
+		r.declare(s.Names...)
+	case *ast.FuncCallStmt:
+		r.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		r.block(s.Stmts)
+	case *ast.WhileStmt:
+		r.expr(s.Condition)
+		r.block(s.Stmts)
+	case *ast.RepeatStmt:
+		// The condition can refer to the locals of the body.
+		r.push()
+		r.stmts(s.Stmts)
+		r.expr(s.Condition)
+		r.pop()
+	case *ast.IfStmt:
+		r.expr(s.Condition)
+		r.block(s.Then)
+		r.block(s.Else)
+	case *ast.NumberForStmt:
+		r.exprs([]ast.Expr{s.Init, s.Limit, s.Step})
+		r.push()
+		r.declare(s.Name)
+		r.stmts(s.Stmts)
+		r.pop()
+	case *ast.GenericForStmt:
+		r.exprs(s.Exprs)
+		r.push()
+		r.declare(s.Names...)
+		r.stmts(s.Stmts)
+		r.pop()
+	case *ast.FuncDefStmt:
+		if _, ok := s.Name.Func.(*ast.IdentExpr); !ok {
+			r.expr(s.Name.Func)
+		}
+		r.expr(s.Name.Receiver)
+		if s.Name.Method != "" {
+			r.function(s.Func, "self")
+		} else {
+			r.function(s.Func)
+		}
+	case *ast.ReturnStmt:
+		r.exprs(s.Exprs)
+	}
+}
+
+func (r *freeNameCollector) exprs(exprs []ast.Expr) {
+	for _, e := range exprs {
+		r.expr(e)
+	}
+}
+
+func (r *freeNameCollector) expr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if !r.isLocal(e.Value) {
+			r.f(e.Value)
+		}
+	case *ast.AttrGetExpr:
+		r.exprs([]ast.Expr{e.Object, e.Key})
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			r.exprs([]ast.Expr{field.Key, field.Value})
+		}
+	case *ast.FuncCallExpr:
+		r.exprs([]ast.Expr{e.Func, e.Receiver})
+		r.exprs(e.Args)
+	case *ast.LogicalOpExpr:
+		r.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.RelationalOpExpr:
+		r.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.StringConcatOpExpr:
+		r.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.ArithmeticOpExpr:
+		r.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.UnaryMinusOpExpr:
+		r.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		r.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		r.expr(e.Expr)
+	case *ast.FunctionExpr:
+		r.function(e)
+	}
+}
+
+func (r *freeNameCollector) function(f *ast.FunctionExpr, implicit ...string) {
+	r.push()
+	r.declare(implicit...)
+	r.declare(f.ParList.Names...)
+	r.stmts(f.Stmts)
+	r.pop()
+}
+
+type constraintState struct {
+	luaState *lua.LState
+
+	// envMeta is the metatable of environment tables that makes the (read-only) global variables visible.
+	envMeta *lua.LTable
+
+	// generation identifies the ConstraintLimits that the state was created with.
+	generation uint64
+}
+
+var constraintStates sync.Pool
+
+func getConstraintState() (*constraintState, ConstraintLimits) {
+	limits, generation := currentConstraintLimits()
+	if state, ok := constraintStates.Get().(*constraintState); ok {
+		if state.generation == generation {
+			return state, limits
+		}
+		state.luaState.Close()
+	}
+
+	luaState, globals := newSandboxedLuaState(limits)
+	envMeta := luaState.CreateTable(0, 1)
+	envMeta.RawSetString("__index", globals)
+	return &constraintState{luaState, envMeta, generation}, limits
+}
+
+// Names returns the global names referred to by the script.
+func (r *compiledScript) Names() []string {
+	return r.names
+}
+
+// Eval evaluates the script with a pooled and sandboxed Lua state.
+//
+// The values of the names are set to a fresh environment table, so evaluations never affect each other.
+func (r *compiledScript) Eval(bind func(name string) (interface{}, error)) (bool, error) {
+	state, limits := getConstraintState()
+	L := state.luaState
+
+	env := L.CreateTable(0, len(r.names))
+	env.Metatable = state.envMeta
+	for _, name := range r.names {
+		value, err := bind(name)
+		if err != nil {
+			constraintStates.Put(state)
+			return false, err
+		}
+		switch x := value.(type) {
+		case float64:
+			env.RawSetString(name, lua.LNumber(x))
+		case string:
+			env.RawSetString(name, lua.LString(x))
+		}
+	}
+
+	fn := L.NewFunctionFromProto(r.proto)
+	fn.Env = env
+	L.Push(fn)
+
+	finish := watchConstraint(L, limits)
+	err := L.PCall(0, 1, nil)
+	if exceeded := finish(); err != nil {
+		// The state may be left broken (e.g., aborted in the middle of a call), so it is discarded.
+		L.Close()
+		if exceeded != nil {
+			return false, exceeded
+		}
+		return false, err
+	}
+
+	value := L.Get(-1)
+	L.SetTop(0)
+	constraintStates.Put(state)
+
+	satisfied, ok := value.(lua.LBool)
+	if !ok {
+		return false, fmt.Errorf("expected a lua bool value, got %v", value)
+	}
+	return bool(satisfied), nil
+}
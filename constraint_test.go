@@ -0,0 +1,177 @@
+package kurobako
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCompiledConstraints(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-5, 1e-1).Log().When(`return optimizer == "adam"`).
+		Continuous("momentum", 0.0, 1.0).When(`return optimizer == "sgd"`).
+		Continuous("decay", 0.0, 1.0).When(`if lr == nil then return false end; return lr < 0.01`).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraints, err := CompileConstraints(spec.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adam := 1.0
+	sgd := 0.0
+	lr := 0.001
+	momentum := 0.9
+	for _, c := range []struct {
+		vals []*float64
+		mask []bool
+	}{
+		{[]*float64{&adam, &lr, nil, &lr}, []bool{true, true, false, true}},
+		{[]*float64{&sgd, nil, &momentum, nil}, []bool{true, false, true, false}},
+
+		// The value of the inactive "lr" shouldn't be bound.
+		{[]*float64{&sgd, &lr, &momentum, &lr}, []bool{true, false, true, false}},
+	} {
+		mask, err := constraints.ActiveMask(c.vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mask, c.mask) {
+			t.Fatalf("unexpected mask: %v", mask)
+		}
+
+		for i, p := range spec.Params {
+			expected, err := p.IsConstraintSatisfied(spec.Params, c.vals[:i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := constraints.IsSatisfied(i, c.vals[:i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != expected {
+				t.Fatalf("unexpected constraint result of %q: %v", p.Name, actual)
+			}
+		}
+	}
+
+	if _, err := constraints.ActiveMask([]*float64{&adam}); err == nil {
+		t.Fatal("expected a length mismatch error")
+	}
+
+	vals := []*float64{&sgd, &lr, &momentum, &lr}
+	mask, err := constraints.DropInactive(vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mask, []bool{true, false, true, false}) || vals[1] != nil || vals[3] != nil || vals[2] != &momentum {
+		t.Fatalf("unexpected result: %v, %v", mask, vals)
+	}
+
+	// A script can't pollute the environment of the succeeding evaluations.
+	constraint := "x = 1; return true"
+	polluter := NewVar("polluter")
+	polluter.Constraint = &constraint
+	if _, err := polluter.IsConstraintSatisfied(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	constraint2 := "return x == nil"
+	checker := NewVar("checker")
+	checker.Constraint = &constraint2
+	if ok, err := checker.IsConstraintSatisfied(nil, nil); err != nil || !ok {
+		t.Fatalf("unexpected constraint result: %v (err=%v)", ok, err)
+	}
+}
+
+func TestConstraintScriptForms(t *testing.T) {
+	kind := NewVar("kind")
+	kind.Range = CategoricalRange{[]string{"a", "b"}}.ToRange()
+	b := 1.0
+	vals := []*float64{&b}
+
+	// Any statement form that returns a bool and a bare expression are accepted,
+	// and every path evaluates the script in the same way.
+	for script, expected := range map[string]bool{
+		`kind == "b"`:                                      true,
+		`kind == "a" or kind == nil`:                       false,
+		`return kind == "b"`:                               true,
+		`return kind == "a";`:                              false,
+		`local k = kind; return k == "b"`:                  true,
+		`if kind == "a" then return true end return false`: false,
+	} {
+		x := NewVar("x")
+		x.Constraint = &script
+		params := []Var{kind, x}
+
+		satisfied, err := x.IsConstraintSatisfied(params, vals)
+		if err != nil || satisfied != expected {
+			t.Fatalf("script %q: unexpected result %v (err=%v)", script, satisfied, err)
+		}
+
+		for _, evaluator := range []ConstraintEvaluator{NewLuaConstraintEvaluator(), NewNativeConstraintEvaluator(nil)} {
+			constraints, err := CompileConstraintsWith(params, evaluator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			satisfied, err := constraints.IsSatisfied(1, vals)
+			if err != nil || satisfied != expected {
+				t.Fatalf("script %q (%T): unexpected result %v (err=%v)", script, evaluator, satisfied, err)
+			}
+		}
+	}
+}
+
+func TestConstraintFreeNames(t *testing.T) {
+	for script, expected := range map[string][]string{
+		`local t = x; return t > 0`:                                       {"x"},
+		`local x = x; return x > 0`:                                       {"x"},
+		`for i = 1, n do if i == m then return true end end return false`: {"n", "m"},
+		`for k, v in pairs(t) do return v == k end`:                       {"pairs", "t"},
+		`local f = function(a, b) return a < b end; return f(x, y)`:       {"x", "y"},
+		`do local z = 1 end; return z == nil`:                             {"z"},
+		`repeat local r = x until r; return r`:                            {"x", "r"},
+		`y = 1; return y == x`:                                            {"y", "x"},
+	} {
+		compiled, err := loadConstraint(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(compiled.names, expected) {
+			t.Fatalf("script %q: unexpected names %v", script, compiled.names)
+		}
+	}
+}
+
+func BenchmarkActiveMask(b *testing.B) {
+	builder := NewProblemSpecBuilder("foo").Categorical("root", "a", "b")
+	for i := 0; i < 200; i++ {
+		builder.Continuous(fmt.Sprintf("x%d", i), 0.0, 1.0).If(When("root").Eq("a"))
+	}
+	spec, err := builder.Objective("v").Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	constraints, err := CompileConstraints(spec.Params)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	vals := make([]*float64, len(spec.Params))
+	for i := range vals {
+		v := 0.0
+		vals[i] = &v
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := constraints.ActiveMask(vals); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package kurobako
+
+import (
+	"sync"
+)
+
+// ConstraintEvaluator is a backend that compiles and evaluates the constraint scripts of variables.
+//
+// The default backend is LuaConstraintEvaluator.
+type ConstraintEvaluator interface {
+	// Compile compiles the given constraint script.
+	Compile(script string) (CompiledConstraint, error)
+}
+
+// CompiledConstraint is a constraint script compiled by a ConstraintEvaluator.
+type CompiledConstraint interface {
+	// Names returns the names of the (global) variables referred to by the script.
+	Names() []string
+
+	// Eval evaluates the script and returns whether the constraint is satisfied.
+	//
+	// The bind function is called to get the value of each name returned by Names.
+	// The value is nil (unbound), a float64 (numerical variable) or a string (categorical variable).
+	Eval(bind func(name string) (interface{}, error)) (bool, error)
+}
+
+// LuaConstraintEvaluator is a ConstraintEvaluator that executes constraint scripts by gopher-lua.
+//
+// Scripts are executed in a sandbox (see ConstraintLimits).
+type LuaConstraintEvaluator struct{}
+
+// NewLuaConstraintEvaluator creates a new LuaConstraintEvaluator instance.
+func NewLuaConstraintEvaluator() *LuaConstraintEvaluator {
+	return &LuaConstraintEvaluator{}
+}
+
+// Compile compiles the given constraint script.
+//
+// Recently compiled scripts are cached (in a cache shared by all LuaConstraintEvaluator instances).
+func (r *LuaConstraintEvaluator) Compile(script string) (CompiledConstraint, error) {
+	compiled, err := loadConstraint(script)
+	if err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+var defaultConstraintEvaluator = struct {
+	sync.RWMutex
+	evaluator ConstraintEvaluator
+}{evaluator: NewLuaConstraintEvaluator()}
+
+// DefaultConstraintEvaluator returns the default ConstraintEvaluator.
+//
+// It is used by every function that compiles constraints without taking a ConstraintEvaluator:
+// Var.IsConstraintSatisfied, CompileConstraints, NewDependencyGraph, ProblemSpec.ActiveMask,
+// ProblemSpec.CheckTrialParams and ProblemSpecBuilder.Build (to validate constraints).
+func DefaultConstraintEvaluator() ConstraintEvaluator {
+	defaultConstraintEvaluator.RLock()
+	defer defaultConstraintEvaluator.RUnlock()
+	return defaultConstraintEvaluator.evaluator
+}
+
+// SetDefaultConstraintEvaluator sets the default ConstraintEvaluator (see DefaultConstraintEvaluator).
+//
+// Use CompileConstraintsWith or NewDependencyGraphWith to choose a backend for a specific parameters domain.
+func SetDefaultConstraintEvaluator(evaluator ConstraintEvaluator) {
+	defaultConstraintEvaluator.Lock()
+	defer defaultConstraintEvaluator.Unlock()
+	defaultConstraintEvaluator.evaluator = evaluator
+}
@@ -8,19 +8,11 @@ type quadraticProblemFactory struct {
 }
 
 func (r *quadraticProblemFactory) Specification() (*kurobako.ProblemSpec, error) {
-	spec := kurobako.NewProblemSpec("Quadratic Function")
-
-	x := kurobako.NewVar("x")
-	x.Range = kurobako.ContinuousRange{-10.0, 10.0}.ToRange()
-
-	y := kurobako.NewVar("y")
-	y.Range = kurobako.DiscreteRange{-3, 3}.ToRange()
-
-	spec.Params = []kurobako.Var{x, y}
-
-	spec.Values = []kurobako.Var{kurobako.NewVar("x**2 + y")}
-
-	return &spec, nil
+	return kurobako.NewProblemSpecBuilder("Quadratic Function").
+		Continuous("x", -10.0, 10.0).
+		Discrete("y", -3, 3).
+		Objective("x**2 + y").
+		Build()
 }
 
 func (r *quadraticProblemFactory) CreateProblem(seed int64) (kurobako.Problem, error) {
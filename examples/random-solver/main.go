@@ -16,12 +16,17 @@ func (r *randomSolverFactory) Specification() (*kurobako.SolverSpec, error) {
 
 func (r *randomSolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
 	rng := rand.New(rand.NewSource(seed))
-	return &randomSolver{rng, problem}, nil
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &randomSolver{rng, problem, constraints}, nil
 }
 
 type randomSolver struct {
-	rng     *rand.Rand
-	problem kurobako.ProblemSpec
+	rng         *rand.Rand
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
 }
 
 func (r *randomSolver) sampleUniform(low float64, high float64) float64 {
@@ -45,6 +50,11 @@ func (r *randomSolver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial,
 		}
 	}
 
+	// Inactive conditional parameters must be nil.
+	if _, err := r.constraints.DropInactive(trial.Params); err != nil {
+		return trial, err
+	}
+
 	trial.TrialID = idg.Generate()
 	trial.NextStep = r.problem.Steps.Last()
 	return trial, nil
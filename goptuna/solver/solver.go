@@ -48,19 +48,25 @@ func (r *GoptunaSolverFactory) CreateSolver(seed int64, problem kurobako.Problem
 		return nil, err
 	}
 
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
 	var waitings trialQueue
 	var pruned trialQueue
 	runnings := map[uint64]int{}
-	return &GoptunaSolver{study, problem, waitings, pruned, runnings}, nil
+	return &GoptunaSolver{study, problem, constraints, waitings, pruned, runnings}, nil
 }
 
 // GoptunaSolver is a Solver implementation based on Goptuna.
 type GoptunaSolver struct {
-	study    *goptuna.Study
-	problem  kurobako.ProblemSpec
-	waitings trialQueue
-	pruned   trialQueue
-	runnings map[uint64]int
+	study       *goptuna.Study
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	waitings    trialQueue
+	pruned      trialQueue
+	runnings    map[uint64]int
 }
 
 func (r *GoptunaSolver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
@@ -82,7 +88,11 @@ func (r *GoptunaSolver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial,
 		}
 
 		currentStep, _ := frozenTrial.GetLatestStep()
-		nextTrial.NextStep = uint64(currentStep) + 1
+		nextStep, ok := r.problem.Steps.Next(uint64(currentStep))
+		if !ok {
+			nextStep = r.problem.Steps.Last()
+		}
+		nextTrial.NextStep = nextStep
 	} else {
 		nextTrial.TrialID = idg.Generate()
 		newGoptunaTrialID, err := r.study.Storage.CreateNewTrial(r.study.ID)
@@ -90,15 +100,15 @@ func (r *GoptunaSolver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial,
 			return nextTrial, err
 		}
 		goptunaTrialID = newGoptunaTrialID
-		nextTrial.NextStep = 1
+		nextTrial.NextStep = r.problem.Steps.First()
 
 		relativeValues, err := r.callRelativeSampler(goptunaTrialID, r.problem.Params)
 		if err != nil {
 			return nextTrial, err
 		}
 
-		for _, p := range r.problem.Params {
-			satisfied, err := p.IsConstraintSatisfied(r.problem.Params, nextTrial.Params)
+		for i, p := range r.problem.Params {
+			satisfied, err := r.constraints.IsSatisfied(i, nextTrial.Params)
 			if err != nil {
 				return nextTrial, err
 			}
@@ -0,0 +1,203 @@
+package kurobako
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxEnumerableDiscreteValues is the maximum number of the values of a discrete parent parameter that can be enumerated.
+	maxEnumerableDiscreteValues = 1024
+
+	// maxSubSpaces is the maximum number of the sub-spaces that can be enumerated.
+	maxSubSpaces = 4096
+
+	// maxSubSpaceCombinations is the maximum number of the combinations of the parent values enumerated by SubSpaces.
+	//
+	// The number of the combinations can be much larger than the number of the distinct sub-spaces.
+	maxSubSpaceCombinations = 1 << 16
+)
+
+// DependencyGraph represents the activation dependencies among the parameters of a problem.
+//
+// If the constraint of a parameter refers to another parameter, the former is a child of the latter.
+// Because a constraint is evaluated under the preceding parameters, the graph is always a DAG and
+// the order of the parameters is a topological order.
+type DependencyGraph struct {
+	vars        []Var
+	constraints *CompiledConstraints
+	parents     [][]int
+	children    [][]int
+}
+
+// NewDependencyGraph analyzes the constraints of the given parameters domain and builds the dependency graph
+// with the default ConstraintEvaluator.
+//
+// This returns an error if a constraint refers to itself or a succeeding parameter,
+// because such a reference is never bound during the evaluation of the constraint.
+func NewDependencyGraph(params []Var) (*DependencyGraph, error) {
+	return NewDependencyGraphWith(params, DefaultConstraintEvaluator())
+}
+
+// NewDependencyGraphWith analyzes the constraints of the given parameters domain with the given ConstraintEvaluator
+// and builds the dependency graph.
+func NewDependencyGraphWith(params []Var, evaluator ConstraintEvaluator) (*DependencyGraph, error) {
+	constraints, err := CompileConstraintsWith(params, evaluator)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make(map[string]int, len(params))
+	for i, v := range params {
+		if _, ok := indices[v.Name]; ok {
+			return nil, fmt.Errorf("duplicate param name %q", v.Name)
+		}
+		indices[v.Name] = i
+	}
+
+	parents := make([][]int, len(params))
+	children := make([][]int, len(params))
+	for i, v := range params {
+		if v.Constraint == nil {
+			continue
+		}
+
+		for _, name := range constraints.constraints[i].script.Names() {
+			j, ok := indices[name]
+			if !ok {
+				continue
+			}
+			if j >= i {
+				return nil, fmt.Errorf("constraint of param %q refers to itself or a succeeding param %q",
+					v.Name, name)
+			}
+
+			parents[i] = append(parents[i], j)
+			children[j] = append(children[j], i)
+		}
+	}
+
+	return &DependencyGraph{params, constraints, parents, children}, nil
+}
+
+// Parents returns the indices of the parameters that the constraint of the i-th parameter refers to.
+func (r *DependencyGraph) Parents(i int) []int {
+	return r.parents[i]
+}
+
+// Children returns the indices of the parameters whose constraints refer to the i-th parameter.
+func (r *DependencyGraph) Children(i int) []int {
+	return r.children[i]
+}
+
+// Roots returns the indices of the parameters that don't depend on any other parameters.
+func (r *DependencyGraph) Roots() []int {
+	var roots []int
+	for i := range r.vars {
+		if len(r.parents[i]) == 0 {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+// IsConditional returns whether the i-th parameter has a constraint.
+func (r *DependencyGraph) IsConditional(i int) bool {
+	return r.vars[i].Constraint != nil
+}
+
+// SubSpaces enumerates the distinct sets of the parameters that can be active at the same time.
+//
+// Each sub-space is represented by the ascending indices of the active parameters.
+// The enumeration branches on every value of the parameters that have children, so
+// this returns an error if such a parameter is continuous or has too many discrete values.
+// It is also an error if there are more than 4096 sub-spaces (or 65536 combinations of the values of the parents),
+// because the number of the sub-spaces grows exponentially with the number of the parents.
+func (r *DependencyGraph) SubSpaces() ([][]int, error) {
+	var subSpaces [][]int
+	seen := map[string]bool{}
+	bound := make([]*float64, len(r.vars))
+	combinations := 0
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		if i == len(r.vars) {
+			combinations++
+			if combinations > maxSubSpaceCombinations {
+				return fmt.Errorf("can't enumerate sub-spaces: more than %d combinations of parent values", maxSubSpaceCombinations)
+			}
+
+			var active []int
+			var key strings.Builder
+			for j, v := range bound[:i] {
+				if v != nil {
+					active = append(active, j)
+					fmt.Fprintf(&key, "%d,", j)
+				}
+			}
+			if !seen[key.String()] {
+				if len(subSpaces) == maxSubSpaces {
+					return fmt.Errorf("can't enumerate sub-spaces: more than %d sub-spaces", maxSubSpaces)
+				}
+				seen[key.String()] = true
+				subSpaces = append(subSpaces, active)
+			}
+			return nil
+		}
+
+		satisfied, err := r.constraints.IsSatisfied(i, bound[:i])
+		if err != nil {
+			return err
+		}
+		if !satisfied {
+			bound[i] = nil
+			return visit(i + 1)
+		}
+
+		values, err := r.branchValues(i)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			value := v
+			bound[i] = &value
+			if err := visit(i + 1); err != nil {
+				return err
+			}
+		}
+		bound[i] = nil
+		return nil
+	}
+
+	if err := visit(0); err != nil {
+		return nil, err
+	}
+	return subSpaces, nil
+}
+
+// branchValues returns the values of the i-th parameter to be enumerated by SubSpaces.
+func (r *DependencyGraph) branchValues(i int) ([]float64, error) {
+	v := r.vars[i]
+	if len(r.children[i]) == 0 {
+		// The value doesn't affect the activation of other parameters, so any value in the range suffices.
+		return []float64{v.Range.Low()}, nil
+	}
+
+	if x := v.Range.AsCategoricalRange(); x != nil {
+		values := make([]float64, len(x.Choices))
+		for j := range x.Choices {
+			values[j] = float64(j)
+		}
+		return values, nil
+	} else if x := v.Range.AsDiscreteRange(); x != nil {
+		if x.High-x.Low > maxEnumerableDiscreteValues {
+			return nil, fmt.Errorf("can't enumerate sub-spaces: discrete parent %q has too many values", v.Name)
+		}
+		var values []float64
+		for j := x.Low; j < x.High; j++ {
+			values = append(values, float64(j))
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("can't enumerate sub-spaces: continuous parent %q", v.Name)
+}
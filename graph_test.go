@@ -0,0 +1,157 @@
+package kurobako
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("model", "linear", "tree").
+		Continuous("alpha", 0.0, 1.0).If(When("model").Eq("linear")).
+		Discrete("depth", 1, 4).If(When("model").Eq("tree")).
+		Continuous("min_leaf", 0.0, 1.0).If(When("depth").Ge(3)).
+		Continuous("x", 0.0, 1.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := NewDependencyGraph(spec.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(graph.Roots(), []int{0, 4}) {
+		t.Fatalf("unexpected roots: %v", graph.Roots())
+	}
+	if !reflect.DeepEqual(graph.Children(0), []int{1, 2}) || !reflect.DeepEqual(graph.Parents(3), []int{2}) {
+		t.Fatal("unexpected edges")
+	}
+	if graph.IsConditional(0) || !graph.IsConditional(3) {
+		t.Fatal("unexpected conditional flags")
+	}
+
+	subSpaces, err := graph.SubSpaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int{{0, 1, 4}, {0, 2, 4}, {0, 2, 3, 4}}
+	if !reflect.DeepEqual(subSpaces, expected) {
+		t.Fatalf("unexpected sub-spaces: %v", subSpaces)
+	}
+
+	constraint := "y > 0"
+	x := NewVar("x")
+	x.Constraint = &constraint
+	if _, err := NewDependencyGraph([]Var{x, NewVar("y")}); err == nil {
+		t.Fatal("expected an ordering error")
+	}
+
+	constraint2 := "x > 0.5"
+	y := NewVar("y")
+	y.Constraint = &constraint2
+	graph, err = NewDependencyGraph([]Var{NewVar("x"), y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := graph.SubSpaces(); err == nil {
+		t.Fatal("expected an error for a continuous parent")
+	}
+
+	// The names of local variables aren't dependencies.
+	constraint3 := "local t = x; return t > 0"
+	y.Constraint = &constraint3
+	graph, err = NewDependencyGraph([]Var{NewVar("x"), y, NewVar("t")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Parents(1), []int{0}) || len(graph.Children(2)) != 0 {
+		t.Fatalf("unexpected edges: %v", graph.Parents(1))
+	}
+}
+
+func TestSubSpacesLimit(t *testing.T) {
+	// Each flag independently activates its own child, so there are 2^13 sub-spaces.
+	builder := NewProblemSpecBuilder("foo")
+	for i := 0; i < 13; i++ {
+		flag := fmt.Sprintf("flag%d", i)
+		builder.Categorical(flag, "off", "on").
+			Continuous(fmt.Sprintf("x%d", i), 0.0, 1.0).If(When(flag).Eq("on"))
+	}
+	spec, err := builder.Objective("v").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := NewDependencyGraph(spec.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := graph.SubSpaces(); err == nil {
+		t.Fatal("expected a too many sub-spaces error")
+	}
+
+	graph, err = NewDependencyGraph(spec.Params[:20])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subSpaces, err := graph.SubSpaces(); err != nil || len(subSpaces) != 1024 {
+		t.Fatalf("unexpected sub-spaces: %d (err=%v)", len(subSpaces), err)
+	}
+}
+
+func TestDependencyGraphUsesDefaultEvaluator(t *testing.T) {
+	evaluator := &countingEvaluator{inner: NewLuaConstraintEvaluator()}
+	SetDefaultConstraintEvaluator(evaluator)
+	defer SetDefaultConstraintEvaluator(NewLuaConstraintEvaluator())
+
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("kind", "a", "b").
+		Continuous("x", 0.0, 1.0).If(When("kind").Eq("a")).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compiled := evaluator.compiled
+	graph, err := NewDependencyGraph(spec.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := graph.SubSpaces(); err != nil {
+		t.Fatal(err)
+	}
+	if evaluator.compiled != compiled+1 || evaluator.evaluated == 0 {
+		t.Fatalf("the default evaluator isn't used: compiled=%d, evaluated=%d", evaluator.compiled, evaluator.evaluated)
+	}
+}
+
+// countingEvaluator is a ConstraintEvaluator that counts the compilations and the evaluations.
+type countingEvaluator struct {
+	inner     ConstraintEvaluator
+	compiled  int
+	evaluated int
+}
+
+func (r *countingEvaluator) Compile(script string) (CompiledConstraint, error) {
+	compiled, err := r.inner.Compile(script)
+	if err != nil {
+		return nil, err
+	}
+	r.compiled++
+	return &countingConstraint{compiled, r}, nil
+}
+
+type countingConstraint struct {
+	CompiledConstraint
+	evaluator *countingEvaluator
+}
+
+func (r *countingConstraint) Eval(bind func(name string) (interface{}, error)) (bool, error) {
+	r.evaluator.evaluated++
+	return r.CompiledConstraint.Eval(bind)
+}
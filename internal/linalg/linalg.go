@@ -0,0 +1,193 @@
+// This package provides small dense linear algebra routines used by the solvers.
+//
+// Matrices are represented as slices of rows (i.e., [][]float64).
+package linalg
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrorNotConverged is an error that is used when an iterative algorithm fails to converge.
+var ErrorNotConverged = errors.New("linalg: not converged")
+
+// maxJacobiSweeps is the maximum number of the sweeps of the Jacobi eigenvalue algorithm.
+const maxJacobiSweeps = 100
+
+// NewMatrix creates a zero matrix of the given size.
+func NewMatrix(rows int, cols int) [][]float64 {
+	m := make([][]float64, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+	}
+	return m
+}
+
+// Identity creates an identity matrix of the given size.
+func Identity(n int) [][]float64 {
+	m := NewMatrix(n, n)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+// Copy returns a deep copy of the given matrix.
+func Copy(a [][]float64) [][]float64 {
+	m := make([][]float64, len(a))
+	for i, row := range a {
+		m[i] = append([]float64(nil), row...)
+	}
+	return m
+}
+
+// MulVec returns the product of the matrix a and the vector x.
+func MulVec(a [][]float64, x []float64) []float64 {
+	y := make([]float64, len(a))
+	for i, row := range a {
+		y[i] = Dot(row, x)
+	}
+	return y
+}
+
+// Dot returns the inner product of the given vectors.
+func Dot(x []float64, y []float64) float64 {
+	s := 0.0
+	for i := range x {
+		s += x[i] * y[i]
+	}
+	return s
+}
+
+// Norm returns the Euclidean norm of the given vector.
+func Norm(x []float64) float64 {
+	return math.Sqrt(Dot(x, x))
+}
+
+// SymmetricEigen computes the eigenvalues and the eigenvectors of the symmetric matrix a
+// by the cyclic Jacobi eigenvalue algorithm.
+//
+// The k-th column of the returned matrix (i.e., vectors[i][k] for all i) is the eigenvector of the k-th eigenvalue.
+// The input matrix isn't modified.
+func SymmetricEigen(a [][]float64) ([]float64, [][]float64, error) {
+	n := len(a)
+	m := Copy(a)
+	v := Identity(n)
+
+	for sweep := 0; ; sweep++ {
+		off := 0.0
+		scale := 0.0
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j {
+					off += m[i][j] * m[i][j]
+				}
+				scale += m[i][j] * m[i][j]
+			}
+		}
+		if off <= 1e-30*scale || off == 0 {
+			break
+		}
+		if sweep == maxJacobiSweeps || math.IsNaN(off) || math.IsInf(off, 0) {
+			return nil, nil, ErrorNotConverged
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if m[p][q] == 0 {
+					continue
+				}
+
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				// m = J^T m J and v = v J, where J is the rotation in the (p, q) plane.
+				for k := 0; k < n; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - s*mkq
+					m[k][q] = s*mkp + c*mkq
+				}
+				for k := 0; k < n; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - s*mqk
+					m[q][k] = s*mpk + c*mqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = m[i][i]
+	}
+	return values, v, nil
+}
+
+// ErrorNotPositiveDefinite is an error that is used when a matrix isn't (numerically) positive definite.
+var ErrorNotPositiveDefinite = errors.New("linalg: not positive definite")
+
+// Cholesky computes the lower triangular matrix l such that a = l l^T.
+//
+// The input matrix must be symmetric positive definite, and it isn't modified.
+func Cholesky(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	l := NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			s := a[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if !(s > 0) {
+					return nil, ErrorNotPositiveDefinite
+				}
+				l[i][i] = math.Sqrt(s)
+			} else {
+				l[i][j] = s / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// SolveLower solves l x = b by forward substitution (where l is a lower triangular matrix).
+func SolveLower(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range x {
+		s := b[i]
+		for k := 0; k < i; k++ {
+			s -= l[i][k] * x[k]
+		}
+		x[i] = s / l[i][i]
+	}
+	return x
+}
+
+// SolveLowerTransposed solves l^T x = b by backward substitution (where l is a lower triangular matrix).
+func SolveLowerTransposed(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := len(x) - 1; i >= 0; i-- {
+		s := b[i]
+		for k := i + 1; k < len(x); k++ {
+			s -= l[k][i] * x[k]
+		}
+		x[i] = s / l[i][i]
+	}
+	return x
+}
+
+// SolveCholesky solves a x = b where l is the Cholesky factor of a.
+func SolveCholesky(l [][]float64, b []float64) []float64 {
+	return SolveLowerTransposed(l, SolveLower(l, b))
+}
@@ -0,0 +1,90 @@
+package linalg
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSymmetricEigen(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, n := range []int{1, 2, 5, 20} {
+		a := NewMatrix(n, n)
+		for i := 0; i < n; i++ {
+			for j := 0; j <= i; j++ {
+				a[i][j] = rng.NormFloat64()
+				a[j][i] = a[i][j]
+			}
+		}
+
+		values, vectors, err := SymmetricEigen(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k := 0; k < n; k++ {
+			v := make([]float64, n)
+			for i := range v {
+				v[i] = vectors[i][k]
+			}
+			if math.Abs(Norm(v)-1) > 1e-9 {
+				t.Fatalf("eigenvector %d isn't normalized: %v", k, v)
+			}
+
+			av := MulVec(a, v)
+			for i := range av {
+				if math.Abs(av[i]-values[k]*v[i]) > 1e-9 {
+					t.Fatalf("A v != lambda v for the eigenvalue %v (n=%d)", values[k], n)
+				}
+			}
+		}
+	}
+}
+
+func TestCholesky(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, n := range []int{1, 3, 10} {
+		// a = b b^T + I is positive definite.
+		b := NewMatrix(n, n)
+		for i := range b {
+			for j := range b[i] {
+				b[i][j] = rng.NormFloat64()
+			}
+		}
+		a := NewMatrix(n, n)
+		for i := range a {
+			for j := range a[i] {
+				a[i][j] = Dot(b[i], b[j])
+			}
+			a[i][i]++
+		}
+
+		l, err := Cholesky(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range a {
+			for j := range a[i] {
+				if math.Abs(Dot(l[i], l[j])-a[i][j]) > 1e-9 {
+					t.Fatalf("l l^T != a (n=%d)", n)
+				}
+			}
+		}
+
+		y := make([]float64, n)
+		for i := range y {
+			y[i] = rng.NormFloat64()
+		}
+		x := SolveCholesky(l, y)
+		ax := MulVec(a, x)
+		for i := range ax {
+			if math.Abs(ax[i]-y[i]) > 1e-9 {
+				t.Fatalf("a x != y (n=%d)", n)
+			}
+		}
+	}
+
+	if _, err := Cholesky([][]float64{{1, 2}, {2, 1}}); err != ErrorNotPositiveDefinite {
+		t.Fatalf("expected ErrorNotPositiveDefinite, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// This package provides numerical optimization routines used by the solvers.
+package optimize
+
+import (
+	"math"
+
+	"github.com/sile/kurobako-go/internal/linalg"
+)
+
+const (
+	// lbfgsMemory is the number of the recent updates used to approximate the inverse Hessian.
+	lbfgsMemory = 10
+
+	maxLineSearchSteps = 30
+	armijoCoefficient  = 1e-4
+	gradientTolerance  = 1e-8
+	valueTolerance     = 1e-12
+)
+
+// Function returns the value and the gradient of an objective function at x.
+type Function func(x []float64) (float64, []float64)
+
+// MinimizeBox minimizes f in the box [lower, upper] by the projected L-BFGS method, and returns the best point found.
+//
+// The coordinates at the bounds whose gradients push them outside are fixed in each iteration,
+// and the search direction of the other coordinates is computed by the two-loop recursion of L-BFGS.
+// The step size is determined by backtracking line search on the projected path.
+func MinimizeBox(f Function, x0 []float64, lower []float64, upper []float64, maxIterations int) ([]float64, float64) {
+	n := len(x0)
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = math.Min(math.Max(x0[i], lower[i]), upper[i])
+	}
+	fx, g := f(x)
+
+	var ss, ys [][]float64
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		free := make([]bool, n)
+		pg := make([]float64, n)
+		for i := range x {
+			free[i] = !(x[i] <= lower[i] && g[i] > 0) && !(x[i] >= upper[i] && g[i] < 0)
+			if free[i] {
+				pg[i] = g[i]
+			}
+		}
+		if linalg.Norm(pg) < gradientTolerance {
+			break
+		}
+
+		d := twoLoopRecursion(pg, ss, ys)
+		for i := range d {
+			d[i] = -d[i]
+			if !free[i] {
+				d[i] = 0
+			}
+		}
+		if linalg.Dot(d, pg) >= 0 {
+			// The approximation isn't positive definite on the free coordinates.
+			ss, ys = nil, nil
+			for i := range d {
+				d[i] = -pg[i]
+			}
+		}
+
+		step := 1.0
+		if len(ss) == 0 {
+			step = math.Min(1, 1/linalg.Norm(pg))
+		}
+
+		var xn, gn []float64
+		fn := math.NaN()
+		accepted := false
+		for k := 0; k < maxLineSearchSteps; k++ {
+			xn = make([]float64, n)
+			decrease := 0.0
+			for i := range xn {
+				xn[i] = math.Min(math.Max(x[i]+step*d[i], lower[i]), upper[i])
+				decrease += g[i] * (xn[i] - x[i])
+			}
+			fn, gn = f(xn)
+			if fn <= fx+armijoCoefficient*decrease {
+				accepted = true
+				break
+			}
+			step *= 0.5
+		}
+		if !accepted {
+			break
+		}
+
+		s := make([]float64, n)
+		y := make([]float64, n)
+		for i := range s {
+			s[i] = xn[i] - x[i]
+			y[i] = gn[i] - g[i]
+		}
+		if linalg.Dot(s, y) > 1e-10 {
+			ss = append(ss, s)
+			ys = append(ys, y)
+			if len(ss) > lbfgsMemory {
+				ss, ys = ss[1:], ys[1:]
+			}
+		}
+
+		converged := math.Abs(fx-fn) <= valueTolerance*(1+math.Abs(fx))
+		x, fx, g = xn, fn, gn
+		if converged {
+			break
+		}
+	}
+	return x, fx
+}
+
+// twoLoopRecursion returns the product of the approximated inverse Hessian and the gradient.
+func twoLoopRecursion(g []float64, ss [][]float64, ys [][]float64) []float64 {
+	q := append([]float64(nil), g...)
+	alphas := make([]float64, len(ss))
+	for k := len(ss) - 1; k >= 0; k-- {
+		alphas[k] = linalg.Dot(ss[k], q) / linalg.Dot(ys[k], ss[k])
+		for i := range q {
+			q[i] -= alphas[k] * ys[k][i]
+		}
+	}
+
+	if k := len(ss) - 1; k >= 0 {
+		gamma := linalg.Dot(ss[k], ys[k]) / linalg.Dot(ys[k], ys[k])
+		for i := range q {
+			q[i] *= gamma
+		}
+	}
+
+	for k := range ss {
+		beta := linalg.Dot(ys[k], q) / linalg.Dot(ys[k], ss[k])
+		for i := range q {
+			q[i] += ss[k][i] * (alphas[k] - beta)
+		}
+	}
+	return q
+}
@@ -0,0 +1,31 @@
+package optimize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMinimizeBoxRosenbrock(t *testing.T) {
+	rosenbrock := func(x []float64) (float64, []float64) {
+		a, b := 1-x[0], x[1]-x[0]*x[0]
+		return a*a + 100*b*b, []float64{-2*a - 400*x[0]*b, 200 * b}
+	}
+
+	x, fx := MinimizeBox(rosenbrock, []float64{-1.2, 1}, []float64{-2, -2}, []float64{2, 2}, 1000)
+	if fx > 1e-8 || math.Abs(x[0]-1) > 1e-3 || math.Abs(x[1]-1) > 1e-3 {
+		t.Fatalf("expected (1, 1), got %v (f=%v)", x, fx)
+	}
+}
+
+func TestMinimizeBoxActiveBounds(t *testing.T) {
+	// The unconstrained minimum (3, -1) is outside the box.
+	quadratic := func(x []float64) (float64, []float64) {
+		a, b := x[0]-3, x[1]+1
+		return a*a + b*b + a*b, []float64{2*a + b, 2*b + a}
+	}
+
+	x, _ := MinimizeBox(quadratic, []float64{0.5, 0.5}, []float64{0, 0}, []float64{1, 1}, 100)
+	if math.Abs(x[0]-1) > 1e-6 || math.Abs(x[1]-0) > 1e-6 {
+		t.Fatalf("expected (1, 0), got %v", x)
+	}
+}
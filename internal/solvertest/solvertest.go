@@ -0,0 +1,175 @@
+// This package provides the test scenarios shared by the solvers.
+package solvertest
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+)
+
+// ConditionalSpec returns a problem that has a categorical parameter "kind" and the parameters conditioned by it:
+// "x" is active if kind is "a", and "n" (log-scaled) and "m" (categorical) are active if kind is "b".
+//
+// The problem has the given number of the objectives.
+func ConditionalSpec(t *testing.T, objectives int) *kurobako.ProblemSpec {
+	builder := kurobako.NewProblemSpecBuilder("conditional").
+		Categorical("kind", "a", "b", "c").
+		Continuous("x", -5.0, 5.0).If(kurobako.When("kind").Eq("a")).
+		Discrete("n", 1, 1000).Log().If(kurobako.When("kind").Eq("b")).
+		Categorical("m", "p", "q").If(kurobako.When("kind").Eq("b"))
+	for i := 0; i < objectives; i++ {
+		builder = builder.Objective(fmt.Sprintf("v%d", i))
+	}
+
+	spec, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// EvaluateConditional returns the values of a trial of ConditionalSpec.
+//
+// The first value is 1 + x^2 if kind is "a", |log10(n) - 1| + m if kind is "b" (so the optimum 0 is in the subtree
+// of "b"), and the trial is unevalable if kind is "c". The other values are the conflicting objectives 10 - v.
+func EvaluateConditional(params []*float64, objectives int) []float64 {
+	var v float64
+	switch *params[0] {
+	case 0:
+		v = 1 + *params[1]**params[1]
+	case 1:
+		v = math.Abs(math.Log10(*params[2])-1) + *params[3]
+	default:
+		return nil
+	}
+
+	values := []float64{v}
+	for len(values) < objectives {
+		values = append(values, 10-v)
+	}
+	return values
+}
+
+// Evaluation is the params of an asked trial and its values.
+type Evaluation struct {
+	Params []*float64
+	Values []float64
+}
+
+// RunConditional runs a solver on ConditionalSpec, and returns the evaluated trials in the told order.
+//
+// At each iteration, the given number of trials are asked concurrently and then told. The test fails if a trial
+// isn't valid under the constraints (e.g., an inactive parameter has a value), if the solver returns an error,
+// or if the solver accepts a result of an unknown trial.
+func RunConditional(t *testing.T, factory kurobako.SolverFactory, objectives int, iterations int, concurrency int) []Evaluation {
+	spec := ConditionalSpec(t, objectives)
+	solver, err := factory.CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var evaluations []Evaluation
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < iterations; i++ {
+		var trials []kurobako.NextTrial
+		for j := 0; j < concurrency; j++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+			trials = append(trials, trial)
+		}
+
+		for _, trial := range trials {
+			values := EvaluateConditional(trial.Params, objectives)
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: values, CurrentStep: trial.NextStep}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+			evaluations = append(evaluations, Evaluation{trial.Params, values})
+		}
+	}
+
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: 1 << 32}); err == nil {
+		t.Fatal("expected an unknown trial error")
+	}
+	return evaluations
+}
+
+// Best returns the smallest first value of the evaluations (or +Inf if there are no evaluable ones).
+func Best(evaluations []Evaluation) float64 {
+	best := math.Inf(0)
+	for _, e := range evaluations {
+		if len(e.Values) > 0 {
+			best = math.Min(best, e.Values[0])
+		}
+	}
+	return best
+}
+
+// SphereSpec returns a problem that has a continuous parameter "x", a log-scaled continuous parameter "y",
+// a discrete parameter "z" and a categorical parameter "c" (whose choices are "a", "b" and "c").
+func SphereSpec(t *testing.T) *kurobako.ProblemSpec {
+	spec, err := kurobako.NewProblemSpecBuilder("sphere").
+		Continuous("x", -5.0, 5.0).
+		Continuous("y", 1e-3, 1e3).Log().
+		Discrete("z", -10, 10).
+		Categorical("c", "a", "b", "c").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// EvaluateSphere returns the value (x - 1)^2 + log10(y)^2 + (z - 3)^2 + (the choice index of c) of a trial of SphereSpec.
+//
+// The optimum 0 is at x = 1, y = 1, z = 3 and c = "a".
+func EvaluateSphere(params []*float64) float64 {
+	x, y, z := *params[0]-1, math.Log10(*params[1]), *params[2]-3
+	return x*x + y*y + z*z + *params[3]
+}
+
+// RunSphere runs a solver on SphereSpec, and returns the best value found.
+//
+// At each iteration, the given number of trials are asked concurrently and then told. The test fails if a trial
+// isn't valid or if the solver returns an error.
+func RunSphere(t *testing.T, factory kurobako.SolverFactory, iterations int, concurrency int) float64 {
+	spec := SphereSpec(t)
+	solver, err := factory.CreateSolver(1, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	best := math.Inf(0)
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < iterations; i++ {
+		var trials []kurobako.NextTrial
+		for j := 0; j < concurrency; j++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+			trials = append(trials, trial)
+		}
+
+		for _, trial := range trials {
+			value := EvaluateSphere(trial.Params)
+			best = math.Min(best, value)
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: 1}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,553 @@
+package kurobako
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// NativeConstraintEvaluator is a ConstraintEvaluator that evaluates common constraint scripts natively in Go.
+//
+// It handles scripts that consist of a single expression (optionally preceded by `return`) built from
+// variables, number, string, boolean and nil literals, comparison operators, `and`, `or`, `not`,
+// unary minus and parentheses (e.g., the scripts generated by Condition).
+// Other scripts are delegated to the fallback evaluator.
+type NativeConstraintEvaluator struct {
+	fallback ConstraintEvaluator
+	cache    *lruCache
+}
+
+// NewNativeConstraintEvaluator creates a new NativeConstraintEvaluator instance.
+//
+// If fallback is nil, LuaConstraintEvaluator is used as the fallback evaluator.
+func NewNativeConstraintEvaluator(fallback ConstraintEvaluator) *NativeConstraintEvaluator {
+	if fallback == nil {
+		fallback = NewLuaConstraintEvaluator()
+	}
+	return &NativeConstraintEvaluator{fallback, newLRUCache(constraintCacheSize)}
+}
+
+// Compile compiles the given constraint script.
+//
+// Recently compiled scripts are cached by each NativeConstraintEvaluator instance.
+func (r *NativeConstraintEvaluator) Compile(script string) (CompiledConstraint, error) {
+	if compiled, ok := r.cache.get(script); ok {
+		return compiled.(CompiledConstraint), nil
+	}
+
+	var compiled CompiledConstraint
+	if native, err := parseNativeConstraint(script); err == nil {
+		compiled = native
+	} else {
+		compiled, err = r.fallback.Compile(script)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.cache.put(script, compiled)
+	return compiled, nil
+}
+
+type nativeConstraint struct {
+	root  nativeExpr
+	names []string
+}
+
+func (r *nativeConstraint) Names() []string {
+	return r.names
+}
+
+func (r *nativeConstraint) Eval(bind func(name string) (interface{}, error)) (bool, error) {
+	values := make([]interface{}, len(r.names))
+	for i, name := range r.names {
+		value, err := bind(name)
+		if err != nil {
+			return false, err
+		}
+		values[i] = value
+	}
+
+	value, err := r.root.eval(values)
+	if err != nil {
+		return false, err
+	}
+
+	satisfied, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a bool value, got %v", value)
+	}
+	return satisfied, nil
+}
+
+// nativeExpr is an expression node. Its value is nil, a bool, a float64 or a string (like Lua values).
+type nativeExpr interface {
+	eval(values []interface{}) (interface{}, error)
+}
+
+type nativeLiteral struct {
+	value interface{}
+}
+
+func (r *nativeLiteral) eval(values []interface{}) (interface{}, error) {
+	return r.value, nil
+}
+
+type nativeVar struct {
+	index int
+}
+
+func (r *nativeVar) eval(values []interface{}) (interface{}, error) {
+	return values[r.index], nil
+}
+
+type nativeNot struct {
+	operand nativeExpr
+}
+
+func (r *nativeNot) eval(values []interface{}) (interface{}, error) {
+	v, err := r.operand.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	return !isTruthy(v), nil
+}
+
+type nativeNeg struct {
+	operand nativeExpr
+}
+
+func (r *nativeNeg) eval(values []interface{}) (interface{}, error) {
+	v, err := r.operand.eval(values)
+	if err != nil {
+		return nil, err
+	}
+
+	x, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("attempt to perform arithmetic on a %s value", luaTypeName(v))
+	}
+	return -x, nil
+}
+
+type nativeLogical struct {
+	op  string
+	lhs nativeExpr
+	rhs nativeExpr
+}
+
+func (r *nativeLogical) eval(values []interface{}) (interface{}, error) {
+	lhs, err := r.lhs.eval(values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same as Lua, `and` and `or` return one of their operands (with short-circuit evaluation).
+	if isTruthy(lhs) == (r.op == "or") {
+		return lhs, nil
+	}
+	return r.rhs.eval(values)
+}
+
+type nativeCompare struct {
+	op  string
+	lhs nativeExpr
+	rhs nativeExpr
+}
+
+func (r *nativeCompare) eval(values []interface{}) (interface{}, error) {
+	lhs, err := r.lhs.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := r.rhs.eval(values)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.op {
+	case "==":
+		return lhs == rhs, nil
+	case "~=":
+		return lhs != rhs, nil
+	}
+
+	if x, ok := lhs.(float64); ok {
+		if y, ok := rhs.(float64); ok {
+			return compareOrdered(r.op, x < y, x == y), nil
+		}
+	}
+	if x, ok := lhs.(string); ok {
+		if y, ok := rhs.(string); ok {
+			return compareOrdered(r.op, x < y, x == y), nil
+		}
+	}
+
+	if luaTypeName(lhs) == luaTypeName(rhs) {
+		return nil, fmt.Errorf("attempt to compare two %s values", luaTypeName(lhs))
+	}
+	return nil, fmt.Errorf("attempt to compare %s with %s", luaTypeName(lhs), luaTypeName(rhs))
+}
+
+func compareOrdered(op string, less bool, equal bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default:
+		return !less
+	}
+}
+
+func isTruthy(v interface{}) bool {
+	return !(v == nil || v == false)
+}
+
+func luaTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// luaGlobalNames returns the names of the global variables defined in the Lua sandbox.
+//
+// The native evaluator doesn't know their values, so scripts referring to them are delegated to Lua.
+var luaGlobalNames = func() func() map[string]bool {
+	var once sync.Once
+	var names map[string]bool
+	return func() map[string]bool {
+		once.Do(func() {
+			L, _ := newSandboxedLuaState(DefaultConstraintLimits())
+			defer L.Close()
+
+			names = map[string]bool{}
+			L.G.Global.ForEach(func(key, _ lua.LValue) {
+				names[key.String()] = true
+			})
+		})
+		return names
+	}
+}()
+
+// nativeParser is a recursive descent parser for the subset of Lua expressions supported by NativeConstraintEvaluator.
+//
+// The operator precedence is the same as Lua's: `or` < `and` < comparison < unary operators.
+type nativeParser struct {
+	tokens []nativeToken
+	pos    int
+	names  []string
+}
+
+type nativeToken struct {
+	kind  string // "name", "keyword", "number", "string", "op" or "eof"
+	text  string
+	value interface{}
+}
+
+func parseNativeConstraint(script string) (*nativeConstraint, error) {
+	tokens, err := tokenizeNative(script)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &nativeParser{tokens: tokens}
+	p.accept("keyword", "return")
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.accept("op", ";")
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unsupported token: %q", t.text)
+	}
+	return &nativeConstraint{root, p.names}, nil
+}
+
+func (r *nativeParser) peek() nativeToken {
+	return r.tokens[r.pos]
+}
+
+func (r *nativeParser) accept(kind string, text string) bool {
+	t := r.peek()
+	if t.kind == kind && t.text == text {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+func (r *nativeParser) parseOr() (nativeExpr, error) {
+	return r.parseBinary("or", r.parseAnd)
+}
+
+func (r *nativeParser) parseAnd() (nativeExpr, error) {
+	return r.parseBinary("and", r.parseCompare)
+}
+
+func (r *nativeParser) parseBinary(op string, operand func() (nativeExpr, error)) (nativeExpr, error) {
+	lhs, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for r.accept("keyword", op) {
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &nativeLogical{op, lhs, rhs}
+	}
+	return lhs, nil
+}
+
+func (r *nativeParser) parseCompare() (nativeExpr, error) {
+	lhs, err := r.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := r.peek()
+		if t.kind != "op" {
+			return lhs, nil
+		}
+		switch t.text {
+		case "==", "~=", "<", "<=", ">", ">=":
+		default:
+			return lhs, nil
+		}
+		r.pos++
+
+		rhs, err := r.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &nativeCompare{t.text, lhs, rhs}
+	}
+}
+
+func (r *nativeParser) parseUnary() (nativeExpr, error) {
+	if r.accept("keyword", "not") {
+		operand, err := r.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &nativeNot{operand}, nil
+	}
+	if r.accept("op", "-") {
+		operand, err := r.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &nativeNeg{operand}, nil
+	}
+	return r.parsePrimary()
+}
+
+func (r *nativeParser) parsePrimary() (nativeExpr, error) {
+	t := r.peek()
+	r.pos++
+
+	switch {
+	case t.kind == "number" || t.kind == "string":
+		return &nativeLiteral{t.value}, nil
+	case t.kind == "keyword" && t.text == "true":
+		return &nativeLiteral{true}, nil
+	case t.kind == "keyword" && t.text == "false":
+		return &nativeLiteral{false}, nil
+	case t.kind == "keyword" && t.text == "nil":
+		return &nativeLiteral{nil}, nil
+	case t.kind == "name":
+		if luaGlobalNames()[t.text] {
+			return nil, fmt.Errorf("unsupported reference to a Lua global: %q", t.text)
+		}
+		for i, name := range r.names {
+			if name == t.text {
+				return &nativeVar{i}, nil
+			}
+		}
+		r.names = append(r.names, t.text)
+		return &nativeVar{len(r.names) - 1}, nil
+	case t.kind == "op" && t.text == "(":
+		e, err := r.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !r.accept("op", ")") {
+			return nil, fmt.Errorf("expected ')', got %q", r.peek().text)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unsupported token: %q", t.text)
+	}
+}
+
+var nativeKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "true": true, "false": true, "nil": true, "return": true,
+}
+
+func tokenizeNative(script string) ([]nativeToken, error) {
+	var tokens []nativeToken
+	s := script
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if strings.HasPrefix(s, "--") {
+			if strings.HasPrefix(s, "--[") {
+				return nil, fmt.Errorf("unsupported block comment")
+			}
+			if i := strings.IndexByte(s, '\n'); i >= 0 {
+				s = s[i:]
+			} else {
+				s = ""
+			}
+			continue
+		}
+		if s == "" {
+			return append(tokens, nativeToken{kind: "eof"}), nil
+		}
+
+		c := s[0]
+		switch {
+		case c == '_' || isASCIILetter(c):
+			n := 1
+			for n < len(s) && (s[n] == '_' || isASCIILetter(s[n]) || isASCIIDigit(s[n])) {
+				n++
+			}
+			word := s[:n]
+			if nativeKeywords[word] {
+				tokens = append(tokens, nativeToken{kind: "keyword", text: word})
+			} else if luaKeywords[word] {
+				return nil, fmt.Errorf("unsupported keyword: %q", word)
+			} else {
+				tokens = append(tokens, nativeToken{kind: "name", text: word})
+			}
+			s = s[n:]
+		case isASCIIDigit(c) || (c == '.' && len(s) > 1 && isASCIIDigit(s[1])):
+			n := 0
+			for n < len(s) && (isASCIILetter(s[n]) || isASCIIDigit(s[n]) || s[n] == '.' ||
+				((s[n] == '+' || s[n] == '-') && n > 0 && (s[n-1] == 'e' || s[n-1] == 'E'))) {
+				n++
+			}
+			value, err := parseLuaNumber(s[:n])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, nativeToken{kind: "number", text: s[:n], value: value})
+			s = s[n:]
+		case c == '"' || c == '\'':
+			value, n, err := parseLuaString(s)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, nativeToken{kind: "string", text: s[:n], value: value})
+			s = s[n:]
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "~=", "<=", ">=", "<", ">", "(", ")", "-", ";"} {
+				if strings.HasPrefix(s, candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" || strings.HasPrefix(s, "--") {
+				return nil, fmt.Errorf("unsupported character: %q", c)
+			}
+			tokens = append(tokens, nativeToken{kind: "op", text: op})
+			s = s[len(op):]
+		}
+	}
+}
+
+func parseLuaNumber(text string) (float64, error) {
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		n, err := strconv.ParseUint(text[2:], 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("malformed number: %q", text)
+		}
+		return float64(n), nil
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed number: %q", text)
+	}
+	return value, nil
+}
+
+// parseLuaString parses a quoted Lua string literal at the beginning of s.
+//
+// It returns the string value and the length of the literal.
+func parseLuaString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("unfinished string")
+		case c != '\\':
+			b.WriteByte(c)
+		case i+1 >= len(s):
+			return "", 0, fmt.Errorf("unfinished string")
+		default:
+			i++
+			switch e := s[i]; {
+			case e == 'n':
+				b.WriteByte('\n')
+			case e == 't':
+				b.WriteByte('\t')
+			case e == 'r':
+				b.WriteByte('\r')
+			case e == 'a':
+				b.WriteByte('\a')
+			case e == 'b':
+				b.WriteByte('\b')
+			case e == 'f':
+				b.WriteByte('\f')
+			case e == 'v':
+				b.WriteByte('\v')
+			case e == '\\' || e == '"' || e == '\'' || e == '\n':
+				b.WriteByte(e)
+			case isASCIIDigit(e):
+				n := 0
+				for j := 0; j < 3 && i < len(s) && isASCIIDigit(s[i]); j++ {
+					n = n*10 + int(s[i]-'0')
+					i++
+				}
+				i--
+				if n > 255 {
+					return "", 0, fmt.Errorf("escape sequence too large")
+				}
+				b.WriteByte(byte(n))
+			default:
+				return "", 0, fmt.Errorf("unsupported escape sequence: \\%c", e)
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("unfinished string")
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,208 @@
+package kurobako
+
+import (
+	"testing"
+)
+
+func TestNativeConstraintEvaluator(t *testing.T) {
+	native := NewNativeConstraintEvaluator(nil)
+	luaEvaluator := NewLuaConstraintEvaluator()
+
+	values := map[string]interface{}{"x": 3.0, "s": "adam", "n": nil}
+	bind := func(name string) (interface{}, error) {
+		return values[name], nil
+	}
+
+	for _, script := range []string{
+		`x == 3`,
+		`return x == 3`,
+		`return x ~= 3;`,
+		`x >= 0x3 and x < 1e1`,
+		`not (x > 2) or s == "adam"`,
+		`-x < -2.5`,
+		`s == 'ad\109m'`,
+		`s < "b" and s >= "adam"`,
+		`n == nil and (n ~= nil and n > 2) == nil`,
+		`(x == 3 and s) == "adam" -- and/or return their operands`,
+		`x == "3"`,
+		`x == nil or x > 2`,
+		`n > 2`,
+		`s < x`,
+		`x`,
+		`math.floor(x) == 3`,
+		`local y = x; return y == 3`,
+	} {
+		expected, expectedErr := evalConstraintWith(luaEvaluator, script, bind)
+		actual, actualErr := evalConstraintWith(native, script, bind)
+		if actual != expected || (actualErr == nil) != (expectedErr == nil) {
+			t.Fatalf("script %q: expected %v (err=%v), got %v (err=%v)",
+				script, expected, expectedErr, actual, actualErr)
+		}
+	}
+
+	for script, expectNative := range map[string]bool{
+		`x == 3`:                     true,
+		`return x == 3`:              true,
+		`return (x < 1 or x > 2);`:   true,
+		`math.floor(x) == 3`:         false,
+		`local y = x; return y == 3`: false,
+		`x + 1 == 4`:                 false,
+		`if x then return true end`:  false,
+	} {
+		compiled, err := native.Compile(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := compiled.(*nativeConstraint); ok != expectNative {
+			t.Fatalf("script %q: unexpected backend %T", script, compiled)
+		}
+	}
+
+	for _, script := range []string{`return x ==`, `x ==`} {
+		if _, err := native.Compile(script); err == nil {
+			t.Fatalf("script %q: expected a compile error", script)
+		}
+	}
+}
+
+func TestCompileConstraintsWith(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-5, 1e-1).Log().If(When("optimizer").Eq("adam")).
+		Continuous("decay", 0.0, 1.0).If(When("lr").IsActive().And(When("lr").Lt(0.01))).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraints, err := CompileConstraintsWith(spec.Params, NewNativeConstraintEvaluator(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range constraints.constraints {
+		if c == nil {
+			continue
+		}
+		if _, ok := c.script.(*nativeConstraint); !ok {
+			t.Fatalf("expected a native constraint, got %T", c.script)
+		}
+	}
+
+	adam := 1.0
+	sgd := 0.0
+	lr := 0.001
+	for _, c := range []struct {
+		vals []*float64
+		mask []bool
+	}{
+		{[]*float64{&adam, &lr, &lr}, []bool{true, true, true}},
+		{[]*float64{&sgd, &lr, &lr}, []bool{true, false, false}},
+	} {
+		mask, err := constraints.ActiveMask(c.vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range mask {
+			if mask[i] != c.mask[i] {
+				t.Fatalf("unexpected mask: %v", mask)
+			}
+		}
+	}
+}
+
+func evalConstraintWith(evaluator ConstraintEvaluator, script string,
+	bind func(string) (interface{}, error)) (bool, error) {
+	compiled, err := evaluator.Compile(script)
+	if err != nil {
+		return false, err
+	}
+	return compiled.Eval(bind)
+}
+
+func TestDefaultConstraintEvaluatorCallSites(t *testing.T) {
+	evaluator := &countingEvaluator{inner: NewNativeConstraintEvaluator(nil)}
+	SetDefaultConstraintEvaluator(evaluator)
+	defer SetDefaultConstraintEvaluator(NewLuaConstraintEvaluator())
+
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("kind", "a", "b").
+		Continuous("x", 0.0, 1.0).If(When("kind").Eq("a")).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluator.compiled == 0 {
+		t.Fatal("ProblemSpecBuilder.Build doesn't use the default evaluator")
+	}
+
+	a, x := 0.0, 0.5
+	params := []*float64{&a, &x}
+	for name, f := range map[string]func() error{
+		"Var.IsConstraintSatisfied": func() error {
+			_, err := spec.Params[1].IsConstraintSatisfied(spec.Params, params)
+			return err
+		},
+		"ProblemSpec.ActiveMask": func() error {
+			_, err := spec.ActiveMask(params)
+			return err
+		},
+		"ProblemSpec.CheckTrialParams": func() error {
+			return spec.CheckTrialParams(params)
+		},
+		"DependencyGraph.SubSpaces": func() error {
+			graph, err := NewDependencyGraph(spec.Params)
+			if err != nil {
+				return err
+			}
+			_, err = graph.SubSpaces()
+			return err
+		},
+	} {
+		evaluated := evaluator.evaluated
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+		if evaluator.evaluated == evaluated {
+			t.Fatalf("%s doesn't use the default evaluator", name)
+		}
+	}
+
+	// An explicitly given evaluator takes precedence over the default one.
+	other := &countingEvaluator{inner: NewLuaConstraintEvaluator()}
+	graph, err := NewDependencyGraphWith(spec.Params, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := graph.SubSpaces(); err != nil || other.evaluated == 0 {
+		t.Fatalf("the given evaluator isn't used: %d (err=%v)", other.evaluated, err)
+	}
+}
+
+func BenchmarkNativeActiveMask(b *testing.B) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-5, 1e-1).Log().If(When("optimizer").Eq("adam")).
+		Continuous("momentum", 0.0, 1.0).If(When("optimizer").Eq("sgd")).
+		Objective("v").
+		Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	constraints, err := CompileConstraintsWith(spec.Params, NewNativeConstraintEvaluator(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	adam := 1.0
+	lr := 0.001
+	vals := []*float64{&adam, &lr, nil}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := constraints.ActiveMask(vals); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
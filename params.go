@@ -0,0 +1,116 @@
+package kurobako
+
+import (
+	"fmt"
+	"math"
+)
+
+// Params is a view of a parameter set that allows to access the values by their names.
+type Params struct {
+	vars    []Var
+	values  []*float64
+	indices map[string]int
+}
+
+// NewParams creates a new Params instance.
+//
+// The vars argument is the parameters domain of a problem (i.e., ProblemSpec.Params).
+// A nil element in the values argument means that the corresponding parameter is inactive.
+// It is an error if multiple parameters have the same name.
+func NewParams(vars []Var, values []*float64) (*Params, error) {
+	if len(vars) != len(values) {
+		return nil, fmt.Errorf("expected %d params, got %d", len(vars), len(values))
+	}
+
+	indices := make(map[string]int, len(vars))
+	for i, v := range vars {
+		if _, ok := indices[v.Name]; ok {
+			return nil, fmt.Errorf("duplicate param name: %q", v.Name)
+		}
+		indices[v.Name] = i
+	}
+	return &Params{vars, values, indices}, nil
+}
+
+// IsActive returns whether the parameter that has the given name is active.
+//
+// If a conditional parameter's constraint isn't satisfied, the parameter is inactive and has no value.
+func (r *Params) IsActive(name string) bool {
+	i, ok := r.indices[name]
+	return ok && r.values[i] != nil
+}
+
+// Float returns the value of the continuous parameter that has the given name.
+func (r *Params) Float(name string) (float64, error) {
+	v, value, err := r.get(name)
+	if err != nil {
+		return 0.0, err
+	}
+
+	if v.Range.AsContinuousRange() == nil {
+		return 0.0, fmt.Errorf("param %q isn't continuous", name)
+	}
+	return value, nil
+}
+
+// Int returns the value of the discrete parameter that has the given name.
+//
+// It is an error if the value isn't an integer.
+func (r *Params) Int(name string) (int64, error) {
+	v, value, err := r.get(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if v.Range.AsDiscreteRange() == nil {
+		return 0, fmt.Errorf("param %q isn't discrete", name)
+	}
+	if value != math.Trunc(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("param %q has a non-integral value: %v", name, value)
+	}
+	return int64(value), nil
+}
+
+// Category returns the chosen value of the categorical parameter that has the given name.
+func (r *Params) Category(name string) (string, error) {
+	v, value, err := r.get(name)
+	if err != nil {
+		return "", err
+	}
+
+	x := v.Range.AsCategoricalRange()
+	if x == nil {
+		return "", fmt.Errorf("param %q isn't categorical", name)
+	}
+
+	index := int(value)
+	if index < 0 || index >= len(x.Choices) {
+		return "", fmt.Errorf("param %q has an out of range choice index: %v", name, value)
+	}
+	return x.Choices[index], nil
+}
+
+func (r *Params) get(name string) (*Var, float64, error) {
+	i, ok := r.indices[name]
+	if !ok {
+		return nil, 0.0, fmt.Errorf("unknown param: %q", name)
+	}
+
+	if r.values[i] == nil {
+		return nil, 0.0, fmt.Errorf("param %q is inactive", name)
+	}
+	return &r.vars[i], *r.values[i], nil
+}
+
+// paramsToFloats converts the given values for Problem.CreateEvaluator.
+//
+// The values of inactive parameters are 0 as if the null values sent by kurobako were decoded into []float64.
+func paramsToFloats(values []*float64) []float64 {
+	xs := make([]float64, len(values))
+	for i, v := range values {
+		if v != nil {
+			xs[i] = *v
+		}
+	}
+	return xs
+}
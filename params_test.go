@@ -0,0 +1,105 @@
+package kurobako
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParamsAccessors(t *testing.T) {
+	x := NewVar("x")
+	x.Range = ContinuousRange{-10.0, 10.0}.ToRange()
+
+	y := NewVar("y")
+	y.Range = DiscreteRange{-3, 3}.ToRange()
+
+	z := NewVar("z")
+	z.Range = CategoricalRange{[]string{"foo", "bar"}}.ToRange()
+
+	w := NewVar("w")
+
+	xv := 1.5
+	yv := -2.0
+	zv := 1.0
+	params, err := NewParams([]Var{x, y, z, w}, []*float64{&xv, &yv, &zv, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := params.Float("x"); err != nil || v != 1.5 {
+		t.Fatalf("unexpected x: %v (err=%v)", v, err)
+	}
+	if v, err := params.Int("y"); err != nil || v != -2 {
+		t.Fatalf("unexpected y: %v (err=%v)", v, err)
+	}
+	if v, err := params.Category("z"); err != nil || v != "bar" {
+		t.Fatalf("unexpected z: %v (err=%v)", v, err)
+	}
+
+	if !params.IsActive("x") || params.IsActive("w") || params.IsActive("unknown") {
+		t.Fatal("unexpected activeness")
+	}
+
+	if _, err := params.Int("x"); err == nil {
+		t.Fatal("expected a type mismatch error")
+	}
+	if _, err := params.Float("w"); err == nil {
+		t.Fatal("expected an inactive param error")
+	}
+	if _, err := params.Category("unknown"); err == nil {
+		t.Fatal("expected an unknown param error")
+	}
+
+	fraction := 2.7
+	params, err = NewParams([]Var{y}, []*float64{&fraction})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := params.Int("y"); err == nil {
+		t.Fatalf("expected a non-integral value error, got %v", v)
+	}
+
+	if _, err := NewParams([]Var{x}, nil); err == nil {
+		t.Fatal("expected a length mismatch error")
+	}
+	if _, err := NewParams([]Var{x, y, x}, []*float64{&xv, &yv, &xv}); err == nil {
+		t.Fatal("expected a duplicate name error")
+	}
+}
+
+type recordingProblem struct {
+	params []float64
+}
+
+func (r *recordingProblem) CreateEvaluator(params []float64) (Evaluator, error) {
+	r.params = params
+	return nil, nil
+}
+
+func TestCreateEvaluatorWithInactiveParams(t *testing.T) {
+	kind := NewVar("kind")
+	kind.Range = CategoricalRange{[]string{"a", "b"}}.ToRange()
+	constraint := `return kind == "a"`
+	x := NewVar("x")
+	x.Constraint = &constraint
+
+	spec := NewProblemSpec("conditional")
+	spec.Params = []Var{kind, x}
+
+	// The params of inactive conditional parameters are sent as null by kurobako.
+	var message struct {
+		Params []*float64 `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(`{"params":[1,null]}`), &message); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &ProblemRunner{spec: &spec}
+	problem := &recordingProblem{}
+	if _, err := runner.createEvaluator(problem, message.Params); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(problem.params, []float64{1, 0}) {
+		t.Fatalf("inactive params should be passed as 0: %v", problem.params)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strings"
 )
 
 // ErrorUnevalableParams is an error that is used when an evaluator encounters an infeasible parameter set.
@@ -40,6 +42,50 @@ func NewProblemSpec(name string) ProblemSpec {
 	}
 }
 
+// ActiveMask returns whether each parameter of the given trial params is active.
+//
+// The constraint of each parameter is evaluated under the preceding active parameters
+// with the default ConstraintEvaluator.
+//
+// The constraints are compiled at each call, so solvers that check many trials should compile them
+// only once by CompileConstraints and use CompiledConstraints.ActiveMask instead.
+func (r *ProblemSpec) ActiveMask(params []*float64) ([]bool, error) {
+	constraints, err := CompileConstraints(r.Params)
+	if err != nil {
+		return nil, err
+	}
+	return constraints.ActiveMask(params)
+}
+
+// CheckTrialParams validates the given trial params against the parameters domain.
+//
+// It reports the parameters that are active but nil, inactive but non-nil, or out of their ranges.
+// This can be used by solvers before replying to ask calls and by problems when they receive params.
+func (r *ProblemSpec) CheckTrialParams(params []*float64) error {
+	mask, err := r.ActiveMask(params)
+	if err != nil {
+		return err
+	}
+
+	var errors []string
+	for i, v := range r.Params {
+		value := params[i]
+		switch {
+		case mask[i] && value == nil:
+			errors = append(errors, fmt.Sprintf("param %q is active but nil", v.Name))
+		case !mask[i] && value != nil:
+			errors = append(errors, fmt.Sprintf("param %q is inactive but has a value %v", v.Name, *value))
+		case value != nil && !v.Range.Contains(*value):
+			errors = append(errors, fmt.Sprintf("param %q has a value %v that is out of the range", v.Name, *value))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("invalid trial params: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
 // Evaluator allows to execute an evaluation process.
 type Evaluator interface {
 	// evaluate executes an evaluation process, at least, until the given step.
@@ -49,9 +95,20 @@ type Evaluator interface {
 // Problem allows to create a new evaluator instance.
 type Problem interface {
 	// CreateEvaluator creates a new evaluator to evaluate the given parameter set.
+	//
+	// Note that the values of inactive conditional parameters are 0.
+	// Implement ProblemWithParams to distinguish them from active parameters.
 	CreateEvaluator(params []float64) (Evaluator, error)
 }
 
+// ProblemWithParams is an optional interface that can be implemented by a Problem.
+//
+// If a problem implements this interface, ProblemRunner calls CreateEvaluatorWithParams instead of CreateEvaluator.
+type ProblemWithParams interface {
+	// CreateEvaluatorWithParams creates a new evaluator to evaluate the given parameter set.
+	CreateEvaluatorWithParams(params *Params) (Evaluator, error)
+}
+
 // ProblemFactory allows to create a new problem instance.
 type ProblemFactory interface {
 	// Specification returns the specification of the problem.
@@ -61,16 +118,41 @@ type ProblemFactory interface {
 	CreateProblem(seed int64) (Problem, error)
 }
 
+// NonFinitePolicy specifies how ProblemRunner handles NaN and infinite values returned by evaluators.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError makes ProblemRunner fail with an error.
+	NonFiniteError NonFinitePolicy = iota
+
+	// NonFiniteUnevalable makes ProblemRunner report the trial as unevalable.
+	NonFiniteUnevalable
+
+	// NonFiniteClamp makes ProblemRunner clamp infinite values into the range of the values domain.
+	//
+	// Note that NaN values can't be clamped, so they are reported as unevalable.
+	NonFiniteClamp
+)
+
 // ProblemRunner runs a black-box optimization problem.
 type ProblemRunner struct {
-	factory    ProblemFactory
-	problems   map[uint64]Problem
-	evaluators map[uint64]Evaluator
+	factory         ProblemFactory
+	spec            *ProblemSpec
+	problems        map[uint64]Problem
+	evaluators      map[uint64]Evaluator
+	nonFinitePolicy NonFinitePolicy
 }
 
 // NewProblemRunner creates a new ProblemRunner that runs the given problem.
 func NewProblemRunner(factory ProblemFactory) *ProblemRunner {
-	return &ProblemRunner{factory, nil, nil}
+	return &ProblemRunner{factory, nil, nil, nil, NonFiniteError}
+}
+
+// SetNonFinitePolicy sets the policy to handle NaN and infinite values returned by evaluators.
+//
+// The default policy is NonFiniteError.
+func (r *ProblemRunner) SetNonFinitePolicy(policy NonFinitePolicy) {
+	r.nonFinitePolicy = policy
 }
 
 // Run runs the problem.
@@ -142,7 +224,12 @@ func (r *ProblemRunner) handleEvaluateCall(input []byte) error {
 
 	evaluator := r.evaluators[message.EvaluatorID]
 	currentStep, values, err := evaluator.Evaluate(message.NextStep)
-	if err != nil {
+	if err == nil {
+		values, err = r.checkEvaluateResult(message.NextStep, currentStep, values)
+	}
+	if err == ErrorUnevalableParams {
+		return r.sendUnevalableReply()
+	} else if err != nil {
 		return err
 	}
 
@@ -154,6 +241,57 @@ func (r *ProblemRunner) handleEvaluateCall(input []byte) error {
 	return r.sendMessage(reply)
 }
 
+// checkEvaluateResult validates the result of an evaluation before it is sent to kurobako.
+func (r *ProblemRunner) checkEvaluateResult(nextStep uint64, currentStep uint64, values []float64) ([]float64, error) {
+	if currentStep < nextStep {
+		return nil, fmt.Errorf("evaluator returned a step %v that is less than the requested step %v",
+			currentStep, nextStep)
+	}
+	if !r.spec.Steps.Contains(currentStep) {
+		return nil, fmt.Errorf("evaluator returned an unknown step %v", currentStep)
+	}
+
+	if len(values) != len(r.spec.Values) {
+		return nil, fmt.Errorf("evaluator returned %d values, but the values domain has %d variables",
+			len(values), len(r.spec.Values))
+	}
+
+	checked := make([]float64, len(values))
+	for i, v := range values {
+		domain := r.spec.Values[i]
+		low := domain.Range.Low()
+		high := domain.Range.High()
+
+		if !isFinite(v) {
+			switch {
+			case r.nonFinitePolicy == NonFiniteUnevalable:
+				return nil, ErrorUnevalableParams
+			case r.nonFinitePolicy == NonFiniteClamp && math.IsNaN(v):
+				return nil, ErrorUnevalableParams
+			case r.nonFinitePolicy == NonFiniteClamp && v < 0:
+				v = low
+				if !isFinite(v) {
+					v = -math.MaxFloat64
+				}
+			case r.nonFinitePolicy == NonFiniteClamp:
+				v = math.Nextafter(high, low)
+				if !isFinite(v) {
+					v = math.MaxFloat64
+				}
+			default:
+				return nil, fmt.Errorf("evaluator returned a non-finite value %v for %q", v, domain.Name)
+			}
+		}
+
+		if v < low || v >= high {
+			return nil, fmt.Errorf("evaluator returned a value %v for %q that is out of the range [%v, %v)",
+				v, domain.Name, low, high)
+		}
+		checked[i] = v
+	}
+	return checked, nil
+}
+
 func (r *ProblemRunner) handleDropEvaluatorCast(input []byte) error {
 	var message struct {
 		EvaluatorID uint64 `json:"evaluator_id"`
@@ -169,9 +307,9 @@ func (r *ProblemRunner) handleDropEvaluatorCast(input []byte) error {
 
 func (r *ProblemRunner) handleCreateEvaluatorCall(input []byte) error {
 	var message struct {
-		ProblemID   uint64    `json:"problem_id"`
-		EvaluatorID uint64    `json:"evaluator_id"`
-		Params      []float64 `json:"params"`
+		ProblemID   uint64     `json:"problem_id"`
+		EvaluatorID uint64     `json:"evaluator_id"`
+		Params      []*float64 `json:"params"`
 	}
 
 	if err := json.Unmarshal(input, &message); err != nil {
@@ -179,9 +317,9 @@ func (r *ProblemRunner) handleCreateEvaluatorCall(input []byte) error {
 	}
 
 	problem := r.problems[message.ProblemID]
-	evaluator, err := problem.CreateEvaluator(message.Params)
+	evaluator, err := r.createEvaluator(problem, message.Params)
 	if err == ErrorUnevalableParams {
-		return r.sendMessage(map[string]interface{}{"type": "ERROR_REPLY", "kind": "UNEVALABLE_PARAMS"})
+		return r.sendUnevalableReply()
 	} else if err != nil {
 		return err
 	}
@@ -190,6 +328,19 @@ func (r *ProblemRunner) handleCreateEvaluatorCall(input []byte) error {
 	return r.sendMessage(map[string]interface{}{"type": "CREATE_EVALUATOR_REPLY"})
 }
 
+func (r *ProblemRunner) createEvaluator(problem Problem, values []*float64) (Evaluator, error) {
+	p, ok := problem.(ProblemWithParams)
+	if !ok {
+		return problem.CreateEvaluator(paramsToFloats(values))
+	}
+
+	params, err := NewParams(r.spec.Params, values)
+	if err != nil {
+		return nil, err
+	}
+	return p.CreateEvaluatorWithParams(params)
+}
+
 func (r *ProblemRunner) handleDropProblemCast(input []byte) error {
 	var message struct {
 		ProblemID uint64 `json:"problem_id"`
@@ -227,10 +378,15 @@ func (r *ProblemRunner) castProblemSpec() error {
 	if err != nil {
 		return err
 	}
+	r.spec = spec
 
 	return r.sendMessage(map[string]interface{}{"type": "PROBLEM_SPEC_CAST", "spec": spec})
 }
 
+func (r *ProblemRunner) sendUnevalableReply() error {
+	return r.sendMessage(map[string]interface{}{"type": "ERROR_REPLY", "kind": "UNEVALABLE_PARAMS"})
+}
+
 func (r *ProblemRunner) sendMessage(message map[string]interface{}) error {
 	bytes, err := json.Marshal(message)
 	if err != nil {
@@ -2,6 +2,7 @@ package kurobako
 
 import (
 	"encoding/json"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -44,3 +45,95 @@ func TestProblemSpecMarshalAndUnmarshal(t *testing.T) {
 		t.Fatalf("unexpected `ProblemSpec`: %v (JSON=%s)", spec3, text)
 	}
 }
+
+func TestCheckEvaluateResult(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Continuous("x", 0.0, 1.0).
+		Steps(1, 5, 10).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Values[0].Range = ContinuousRange{-1.0, 1.0}.ToRange()
+
+	runner := NewProblemRunner(nil)
+	runner.spec = spec
+
+	if values, err := runner.checkEvaluateResult(1, 5, []float64{0.5}); err != nil || values[0] != 0.5 {
+		t.Fatalf("unexpected result: %v (err=%v)", values, err)
+	}
+
+	for _, c := range []struct {
+		nextStep    uint64
+		currentStep uint64
+		values      []float64
+	}{
+		{5, 1, []float64{0.5}},
+		{1, 2, []float64{0.5}},
+		{1, 1, []float64{0.5, 0.5}},
+		{1, 1, []float64{1.0}},
+		{1, 1, []float64{math.Inf(0)}},
+	} {
+		if _, err := runner.checkEvaluateResult(c.nextStep, c.currentStep, c.values); err == nil {
+			t.Fatalf("expected an error: %v", c)
+		}
+	}
+
+	runner.SetNonFinitePolicy(NonFiniteUnevalable)
+	if _, err := runner.checkEvaluateResult(1, 1, []float64{math.NaN()}); err != ErrorUnevalableParams {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runner.SetNonFinitePolicy(NonFiniteClamp)
+	if _, err := runner.checkEvaluateResult(1, 1, []float64{math.NaN()}); err != ErrorUnevalableParams {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values, err := runner.checkEvaluateResult(1, 1, []float64{math.Inf(-1)}); err != nil || values[0] != -1.0 {
+		t.Fatalf("unexpected result: %v (err=%v)", values, err)
+	}
+	if values, err := runner.checkEvaluateResult(1, 1, []float64{math.Inf(0)}); err != nil || !(values[0] < 1.0) {
+		t.Fatalf("unexpected result: %v (err=%v)", values, err)
+	}
+}
+
+func TestCheckTrialParams(t *testing.T) {
+	spec, err := NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-5, 1e-1).Log().If(When("optimizer").Eq("adam")).
+		Discrete("layers", 1, 4).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := func(v float64) *float64 { return &v }
+	for _, c := range []struct {
+		params []*float64
+		valid  bool
+	}{
+		{[]*float64{f(1), f(0.01), f(2)}, true},
+		{[]*float64{f(0), nil, f(3)}, true},
+		{[]*float64{f(1), nil, f(2)}, false},          // active but nil
+		{[]*float64{f(0), f(0.01), f(2)}, false},      // inactive but non-nil
+		{[]*float64{f(1), f(0.5), f(2)}, false},       // out of the continuous range
+		{[]*float64{f(0), nil, f(1.5)}, false},        // non-integer discrete value
+		{[]*float64{f(2), nil, f(1)}, false},          // out of the choices
+		{[]*float64{f(0), nil, f(math.NaN())}, false}, // NaN
+		{[]*float64{f(0), nil}, false},                // length mismatch
+	} {
+		err := spec.CheckTrialParams(c.params)
+		if (err == nil) != c.valid {
+			t.Fatalf("unexpected result for %v: %v", c.params, err)
+		}
+	}
+
+	mask, err := spec.ActiveMask([]*float64{f(0), nil, f(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mask, []bool{true, false, true}) {
+		t.Fatalf("unexpected mask: %v", mask)
+	}
+}
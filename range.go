@@ -125,6 +125,24 @@ func (r *Range) High() float64 {
 	}
 }
 
+// IsBounded returns whether both bounds of the range are finite.
+func (r *Range) IsBounded() bool {
+	return !math.IsInf(r.Low(), 0) && !math.IsInf(r.High(), 0)
+}
+
+// Contains returns whether the given value is in the range.
+//
+// The values of discrete ranges and the indices of categorical ranges must be integers.
+func (r *Range) Contains(value float64) bool {
+	if math.IsNaN(value) || value < r.Low() || value >= r.High() {
+		return false
+	}
+	if r.AsContinuousRange() != nil {
+		return true
+	}
+	return value == math.Trunc(value)
+}
+
 // AsContinuousRange tries to return the inner object of the range as a ContinuousRange object.
 func (r *Range) AsContinuousRange() *ContinuousRange {
 	inner, ok := (r.inner).(ContinuousRange)
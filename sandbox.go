@@ -0,0 +1,453 @@
+package kurobako
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+)
+
+// ErrorConstraintTimeout is an error that is used when the evaluation of a constraint exceeds the time limit.
+var ErrorConstraintTimeout = errors.New("constraint evaluation exceeded the time limit")
+
+// ErrorConstraintHeapGrowth is an error that is used when the heap grows too much during the evaluation of a constraint
+// (see ConstraintLimits.MaxHeapGrowth).
+var ErrorConstraintHeapGrowth = errors.New("heap grew beyond the limit during constraint evaluation")
+
+// ConstraintLimits is the resource limits imposed on the evaluation of constraint scripts.
+//
+// Constraint scripts are executed in a restricted Lua environment that only provides the base
+// (without functions that access files, load code, print or bypass metatables), math and string libraries
+// (without the pattern matching functions, see below).
+// The global variables and the libraries are read-only, so a script can't affect other evaluations.
+//
+// The stack sizes of the Lua state are fixed by CallStackSize and RegistrySize, the strings created by
+// concatenations (`..`), string.rep, string.format, string.lower and string.upper are limited by MaxStringLength,
+// and the evaluation is canceled when Timeout expires or the heap grows by more than MaxHeapGrowth.
+// Note that the timeout can't interrupt a function implemented in Go (e.g., a string library function) in the middle,
+// so string.find, string.gmatch, string.gsub and string.match, whose pattern matching can take an unbounded time,
+// aren't provided.
+//
+// A zero value of a field means that the corresponding limit is disabled.
+type ConstraintLimits struct {
+	// Timeout is the maximum time to evaluate a constraint.
+	Timeout time.Duration
+
+	// MaxHeapGrowth is the maximum number of bytes that the heap of the whole process can grow by
+	// during the evaluation of a constraint.
+	//
+	// This is a best-effort guard against runaway scripts rather than a per-evaluation limit: the heap usage
+	// (runtime.MemStats.HeapAlloc) is compared with the one at the start of the evaluation every 10 milliseconds,
+	// allocations of other goroutines are also counted, and garbage collections can hide allocations.
+	// Each sample briefly stops the world (i.e., a few microseconds per evaluation).
+	MaxHeapGrowth uint64
+
+	// MaxStringLength is the maximum length of a string created by a concatenation (`..`) or a string library function.
+	//
+	// The width and the precision of a string.format specifier are also limited to two digits (as in the standard Lua).
+	MaxStringLength int
+
+	// CallStackSize is the maximum depth of the Lua call stack.
+	CallStackSize int
+
+	// RegistrySize is the maximum number of the slots of the Lua value stack.
+	RegistrySize int
+}
+
+// DefaultConstraintLimits returns the default limits of constraint evaluations.
+func DefaultConstraintLimits() ConstraintLimits {
+	return ConstraintLimits{
+		Timeout:         time.Second,
+		MaxHeapGrowth:   64 * 1024 * 1024,
+		MaxStringLength: 1024 * 1024,
+		CallStackSize:   lua.CallStackSize,
+		RegistrySize:    lua.RegistrySize,
+	}
+}
+
+var constraintLimits = struct {
+	sync.RWMutex
+	limits     ConstraintLimits
+	generation uint64
+}{limits: DefaultConstraintLimits()}
+
+// SetConstraintLimits sets the limits that are imposed on the succeeding constraint evaluations.
+func SetConstraintLimits(limits ConstraintLimits) {
+	constraintLimits.Lock()
+	defer constraintLimits.Unlock()
+	constraintLimits.limits = limits
+	constraintLimits.generation++
+}
+
+func currentConstraintLimits() (ConstraintLimits, uint64) {
+	constraintLimits.RLock()
+	defer constraintLimits.RUnlock()
+	return constraintLimits.limits, constraintLimits.generation
+}
+
+// unsafeBaseFuncs is the base library functions (and variables) that are removed from the sandbox.
+//
+// Besides the functions that access files, load code or print, the functions that can modify tables
+// without their metatables (and _G) are removed so that the read-only globals can't be bypassed.
+var unsafeBaseFuncs = []string{
+	"collectgarbage", "dofile", "getfenv", "load", "loadfile", "loadstring", "module",
+	"newproxy", "print", "_printregs", "require", "setfenv",
+	"_G", "getmetatable", "rawget", "rawset", "setmetatable",
+}
+
+// unsafeStringFuncs is the string library functions that are removed from the sandbox.
+//
+// Their pattern matching backtracks in Go code, which the timeout can't interrupt, so a crafted pattern
+// (or just a long one) can take an unbounded time.
+var unsafeStringFuncs = []string{"find", "gfind", "gmatch", "gsub", "match"}
+
+// newSandboxedLuaState creates a Lua state that only has the base, math and string libraries.
+//
+// The returned table is a read-only view of the global variables (the libraries are also read-only).
+func newSandboxedLuaState(limits ConstraintLimits) (*lua.LState, *lua.LTable) {
+	options := lua.Options{
+		SkipOpenLibs:  true,
+		CallStackSize: limits.CallStackSize,
+		RegistrySize:  limits.RegistrySize,
+	}
+	L := lua.NewState(options)
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.StringLibName, lua.OpenString},
+	} {
+		err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.open), NRet: 0, Protect: true}, lua.LString(lib.name))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	for _, name := range unsafeBaseFuncs {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	L.SetGlobal(concatFuncName, L.NewFunction(func(L *lua.LState) int {
+		lhs, rhs := L.Get(1), L.Get(2)
+		if !lua.LVCanConvToString(lhs) || !lua.LVCanConvToString(rhs) {
+			L.RaiseError("cannot perform concat operation between %v and %v", lhs.Type(), rhs.Type())
+		}
+		l, r := lua.LVAsString(lhs), lua.LVAsString(rhs)
+		if limits.MaxStringLength > 0 && len(l)+len(r) > limits.MaxStringLength {
+			L.RaiseError("concatenation: the result exceeds the maximum string length (%d)", limits.MaxStringLength)
+		}
+		L.Push(lua.LString(l + r))
+		return 1
+	}))
+
+	// The string library table is also the metatable of strings, so method calls (e.g., `s:rep(2)`) are limited as well.
+	stringLib := L.GetGlobal(lua.StringLibName).(*lua.LTable)
+	for _, name := range unsafeStringFuncs {
+		stringLib.RawSetString(name, lua.LNil)
+	}
+
+	if limits.MaxStringLength > 0 {
+		stringLib.RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+			s := L.CheckString(1)
+			n := L.CheckInt(2)
+			if n > 0 && len(s) > 0 && n > limits.MaxStringLength/len(s) {
+				L.RaiseError("string.rep: the result exceeds the maximum string length (%d)", limits.MaxStringLength)
+			}
+			if n < 0 {
+				n = 0
+			}
+			L.Push(lua.LString(strings.Repeat(s, n)))
+			return 1
+		}))
+
+		format := stringLib.RawGetString("format").(*lua.LFunction).GFunction
+		stringLib.RawSetString("format", L.NewFunction(func(L *lua.LState) int {
+			if formatLengthBound(L) > limits.MaxStringLength {
+				L.RaiseError("string.format: the result may exceed the maximum string length (%d)", limits.MaxStringLength)
+			}
+			return format(L)
+		}))
+
+		// Invalid UTF-8 sequences are replaced with U+FFFD, so the results can be three times longer than the inputs.
+		for _, name := range []string{"lower", "upper"} {
+			name, f := name, stringLib.RawGetString(name).(*lua.LFunction).GFunction
+			stringLib.RawSetString(name, L.NewFunction(func(L *lua.LState) int {
+				n := f(L)
+				if len(L.CheckString(-1)) > limits.MaxStringLength {
+					L.RaiseError("string.%s: the result exceeds the maximum string length (%d)", name, limits.MaxStringLength)
+				}
+				return n
+			}))
+		}
+	}
+
+	for _, name := range []string{lua.MathLibName, lua.StringLibName} {
+		L.SetGlobal(name, readOnlyTable(L, L.GetGlobal(name).(*lua.LTable)))
+	}
+	return L, readOnlyTable(L, L.G.Global)
+}
+
+// formatLengthBound returns an upper bound of the length of the result of string.format called with the arguments
+// on the stack.
+//
+// string.format is implemented by fmt.Sprintf, so a specifier that can't be bounded (i.e., one that has an explicit
+// argument index or a `*` width) and a width or a precision that has more than two digits raise an error.
+func formatLengthBound(L *lua.LState) int {
+	format := L.CheckString(1)
+	digits := func(i int) (int, int) {
+		j := i
+		for j < len(format) && '0' <= format[j] && format[j] <= '9' {
+			j++
+		}
+		if j-i > 2 {
+			L.RaiseError("string.format: invalid format (width or precision too long)")
+		}
+		n, _ := strconv.Atoi(format[i:j])
+		return n, j
+	}
+
+	bound, arg := 0, 2
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) || format[i+1] == '%' {
+			if format[i] == '%' {
+				i++
+			}
+			bound++
+			continue
+		}
+
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		width, precision := 0, 0
+		width, i = digits(i)
+		if i < len(format) && format[i] == '.' {
+			precision, i = digits(i + 1)
+		}
+		if i == len(format) {
+			break
+		}
+		if format[i] == '*' || format[i] == '[' {
+			L.RaiseError("string.format: invalid format (argument indexes and `*` aren't supported)")
+		}
+
+		// Numbers and the other values are formatted in a few hundreds bytes.
+		n := 512
+		if s, ok := L.Get(arg).(lua.LString); ok {
+			switch format[i] {
+			case 's', 'v':
+				n = len(s)
+			case 'x', 'X':
+				n = 2 * len(s)
+			default:
+				// E.g., `%q` escapes each byte with up to 10 bytes.
+				n = 10*len(s) + 2
+			}
+		}
+		arg++
+		bound += n + width + precision
+	}
+	return bound
+}
+
+// concatFuncName is the name of the global function that performs concatenations in the sandbox.
+//
+// It isn't a valid Lua identifier, so scripts can neither refer to nor shadow it.
+const concatFuncName = "<concat>"
+
+// sandboxConcat replaces the concatenations (`..`) in the given statements with calls to the concatFuncName function
+// so that the lengths of the resulting strings can be limited.
+//
+// The other functions that concatenate strings (e.g., table.concat) aren't available in the sandbox.
+func sandboxConcat(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			sandboxConcatExprs(s.Lhs)
+			sandboxConcatExprs(s.Rhs)
+		case *ast.LocalAssignStmt:
+			sandboxConcatExprs(s.Exprs)
+		case *ast.FuncCallStmt:
+			s.Expr = sandboxConcatExpr(s.Expr)
+		case *ast.DoBlockStmt:
+			sandboxConcat(s.Stmts)
+		case *ast.WhileStmt:
+			s.Condition = sandboxConcatExpr(s.Condition)
+			sandboxConcat(s.Stmts)
+		case *ast.RepeatStmt:
+			s.Condition = sandboxConcatExpr(s.Condition)
+			sandboxConcat(s.Stmts)
+		case *ast.IfStmt:
+			s.Condition = sandboxConcatExpr(s.Condition)
+			sandboxConcat(s.Then)
+			sandboxConcat(s.Else)
+		case *ast.NumberForStmt:
+			s.Init = sandboxConcatExpr(s.Init)
+			s.Limit = sandboxConcatExpr(s.Limit)
+			s.Step = sandboxConcatExpr(s.Step)
+			sandboxConcat(s.Stmts)
+		case *ast.GenericForStmt:
+			sandboxConcatExprs(s.Exprs)
+			sandboxConcat(s.Stmts)
+		case *ast.FuncDefStmt:
+			s.Name.Func = sandboxConcatExpr(s.Name.Func)
+			s.Name.Receiver = sandboxConcatExpr(s.Name.Receiver)
+			sandboxConcat(s.Func.Stmts)
+		case *ast.ReturnStmt:
+			sandboxConcatExprs(s.Exprs)
+		}
+	}
+}
+
+func sandboxConcatExprs(exprs []ast.Expr) {
+	for i, e := range exprs {
+		exprs[i] = sandboxConcatExpr(e)
+	}
+}
+
+func sandboxConcatExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.StringConcatOpExpr:
+		fn := &ast.IdentExpr{Value: concatFuncName}
+		call := &ast.FuncCallExpr{Func: fn, Args: []ast.Expr{sandboxConcatExpr(e.Lhs), sandboxConcatExpr(e.Rhs)}}
+		for _, node := range []ast.PositionHolder{fn, call} {
+			node.SetLine(e.Line())
+			node.SetLastLine(e.LastLine())
+		}
+		return call
+	case *ast.AttrGetExpr:
+		e.Object = sandboxConcatExpr(e.Object)
+		e.Key = sandboxConcatExpr(e.Key)
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			field.Key = sandboxConcatExpr(field.Key)
+			field.Value = sandboxConcatExpr(field.Value)
+		}
+	case *ast.FuncCallExpr:
+		e.Func = sandboxConcatExpr(e.Func)
+		e.Receiver = sandboxConcatExpr(e.Receiver)
+		sandboxConcatExprs(e.Args)
+	case *ast.LogicalOpExpr:
+		e.Lhs = sandboxConcatExpr(e.Lhs)
+		e.Rhs = sandboxConcatExpr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		e.Lhs = sandboxConcatExpr(e.Lhs)
+		e.Rhs = sandboxConcatExpr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		e.Lhs = sandboxConcatExpr(e.Lhs)
+		e.Rhs = sandboxConcatExpr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		e.Expr = sandboxConcatExpr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		e.Expr = sandboxConcatExpr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		e.Expr = sandboxConcatExpr(e.Expr)
+	case *ast.FunctionExpr:
+		sandboxConcat(e.Stmts)
+	}
+	return expr
+}
+
+// readOnlyTable returns an empty table that delegates reads to the given table and rejects writes.
+func readOnlyTable(L *lua.LState, table *lua.LTable) *lua.LTable {
+	meta := L.CreateTable(0, 3)
+	meta.RawSetString("__index", table)
+	meta.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("attempt to modify a read-only table")
+		return 0
+	}))
+	meta.RawSetString("__metatable", lua.LFalse)
+
+	proxy := L.CreateTable(0, 0)
+	proxy.Metatable = meta
+	return proxy
+}
+
+// constraintHeapCheckInterval is the interval at which the heap usage is sampled (see ConstraintLimits.MaxHeapGrowth).
+const constraintHeapCheckInterval = 10 * time.Millisecond
+
+// watchConstraint sets up the time limit and the heap guard of an evaluation on the given Lua state.
+//
+// The returned function should be called when the evaluation finishes.
+// It returns the error that indicates the exceeded limit (if any).
+func watchConstraint(L *lua.LState, limits ConstraintLimits) func() error {
+	if limits.Timeout <= 0 && limits.MaxHeapGrowth == 0 {
+		return func() error { return nil }
+	}
+
+	// The Lua VM checks the context during the execution, so the timeout needs no polling.
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	}
+	ctx, cancelHeap := context.WithCancel(ctx)
+	L.SetContext(ctx)
+
+	var exceeded atomic.Value
+	stopHeapCheck := func() bool { return false }
+	if limits.MaxHeapGrowth > 0 {
+		// The baseline is sampled before the evaluation starts so that no allocations of the script are missed.
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		baseline := memStats.HeapAlloc
+
+		// The following samples are taken only if the evaluation takes long enough.
+		timer := time.AfterFunc(constraintHeapCheckInterval, func() {
+			var memStats runtime.MemStats
+			if heapGrewTooMuch(&memStats, baseline, limits.MaxHeapGrowth) {
+				exceeded.Store(ErrorConstraintHeapGrowth)
+				cancelHeap()
+				return
+			}
+
+			// The loop exits when the evaluation finishes (i.e., the context is canceled).
+			ticker := time.NewTicker(constraintHeapCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+
+				if heapGrewTooMuch(&memStats, baseline, limits.MaxHeapGrowth) {
+					exceeded.Store(ErrorConstraintHeapGrowth)
+					cancelHeap()
+					return
+				}
+			}
+		})
+		stopHeapCheck = timer.Stop
+	}
+
+	return func() error {
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		stopHeapCheck()
+		cancelHeap()
+		cancel()
+		L.RemoveContext()
+		if err, ok := exceeded.Load().(error); ok {
+			return err
+		}
+		if timedOut {
+			return ErrorConstraintTimeout
+		}
+		return nil
+	}
+}
+
+func heapGrewTooMuch(memStats *runtime.MemStats, baseline uint64, maxGrowth uint64) bool {
+	runtime.ReadMemStats(memStats)
+	return memStats.HeapAlloc > baseline && memStats.HeapAlloc-baseline > maxGrowth
+}
@@ -0,0 +1,161 @@
+package kurobako
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func evalConstraint(t *testing.T, script string) (bool, error) {
+	t.Helper()
+	v := NewVar("x")
+	v.Constraint = &script
+	return v.IsConstraintSatisfied(nil, nil)
+}
+
+func TestConstraintSandbox(t *testing.T) {
+	for _, script := range []string{
+		"return os == nil and io == nil and print == nil and require == nil",
+		"return loadstring == nil and dofile == nil and setfenv == nil",
+		"return _G == nil and rawset == nil and rawget == nil and setmetatable == nil and getmetatable == nil",
+		"return math.floor(1.5) == 1 and string.upper('a') == 'A' and tostring(1) == '1'",
+		"return string.format('%05.1f|%s|%d%%', 1.25, 'ab', 3) == '001.2|ab|3%' and ('%x'):format(255) == 'ff'",
+		"return string.find == nil and string.gsub == nil and string.match == nil and string.gmatch == nil",
+		"return ('abc'):lower() == 'abc' and ('abc'):upper() == 'ABC'",
+	} {
+		ok, err := evalConstraint(t, script)
+		if err != nil || !ok {
+			t.Fatalf("unexpected result of %q: %v (err=%v)", script, ok, err)
+		}
+	}
+
+	for _, script := range []string{
+		"return 'a' .. 1 .. 'b' == 'a1b'",
+		"local s = 'x' for i = 1, 3 do s = s .. s end return #s == 8",
+		"local t = {k = 'v' .. 'w'} return t['k' .. ''] == 'vw'",
+		"local function f(s) return s .. '!' end return f('a') == 'a!'",
+	} {
+		ok, err := evalConstraint(t, script)
+		if err != nil || !ok {
+			t.Fatalf("unexpected result of %q: %v (err=%v)", script, ok, err)
+		}
+	}
+	if _, err := evalConstraint(t, "return 'a' .. {} == nil"); err == nil {
+		t.Fatal("expected a concat error")
+	}
+}
+
+func TestConstraintSandboxStringLength(t *testing.T) {
+	for _, script := range []string{
+		"return string.rep('x', 1e9) ~= nil",
+		`local s = "xxxxxxxxxxxxxxxx" for i = 1, 28 do s = s .. s end return #s > 0`,
+		`local s = "xxxxxxxxxxxxxxxx" for i = 1, 28 do s = (function() return s .. s end)() end return #s > 0`,
+		`return string.format("%50000000d", 1) ~= nil`,
+		`return string.format("%100d", 1) ~= nil`,
+		`return string.format("%*d", 1) ~= nil`,
+		`local s = string.rep("x", 1000000) return string.format("%s%s", s, s) ~= nil`,
+		`local s = string.rep("x", 1000000) return ("%q"):format(s) ~= nil`,
+		`local s = string.rep("\255", 1000000) return #string.upper(s) > 0`,
+		`return ("x"):rep(2000):gsub(("y"):rep(2000), "") ~= nil`,
+		`return string.find(string.rep("x", 5000), string.rep("y", 5000)) ~= nil`,
+	} {
+		start := time.Now()
+		_, err := evalConstraint(t, script)
+		if err == nil || errors.Is(err, ErrorConstraintTimeout) {
+			t.Fatalf("script %q: expected a string length error, got %v", script, err)
+		}
+		if time.Since(start) > 100*time.Millisecond {
+			t.Fatalf("script %q: the string length limit didn't work in time", script)
+		}
+	}
+}
+
+func TestConstraintSandboxIsolation(t *testing.T) {
+	// Each of these scripts tries to modify the globals shared by the pooled Lua states.
+	for _, script := range []string{
+		"_G.leak = 1; return true",
+		"rawset(string, 'leak', 1); return true",
+		"string.leak = 1; return true",
+		"math.floor = nil; return true",
+		"setmetatable(string, nil); return true",
+	} {
+		if _, err := evalConstraint(t, script); err == nil {
+			t.Fatalf("script %q: expected an error", script)
+		}
+	}
+
+	// Assigning a global variable only affects the environment of the evaluation.
+	if ok, err := evalConstraint(t, "leak = 1; tostring = nil; return leak == 1"); err != nil || !ok {
+		t.Fatalf("unexpected result: %v (err=%v)", ok, err)
+	}
+
+	// The succeeding evaluations (with the same pooled states) don't see any of the modifications.
+	for i := 0; i < 10; i++ {
+		script := "return leak == nil and string.leak == nil and math.floor(1.5) == 1 and tostring(1) == '1'"
+		if ok, err := evalConstraint(t, script); err != nil || !ok {
+			t.Fatalf("unexpected result: %v (err=%v)", ok, err)
+		}
+	}
+}
+
+func TestConstraintLimits(t *testing.T) {
+	defer SetConstraintLimits(DefaultConstraintLimits())
+
+	limits := DefaultConstraintLimits()
+	limits.Timeout = 50 * time.Millisecond
+	SetConstraintLimits(limits)
+
+	start := time.Now()
+	_, err := evalConstraint(t, "while true do end")
+	if !errors.Is(err, ErrorConstraintTimeout) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("timeout didn't work in time")
+	}
+
+	limits.Timeout = 0
+	limits.MaxHeapGrowth = 16 * 1024 * 1024
+	SetConstraintLimits(limits)
+
+	_, err = evalConstraint(t, "local t = {} local i = 0 while true do i = i + 1 t[i] = {i} end")
+	if !errors.Is(err, ErrorConstraintHeapGrowth) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The allocations just after the start of the evaluation (i.e., before the first sample) are also counted.
+	// (The garbage of the previous evaluation is collected beforehand, because collecting it would hide the growth.)
+	limits.Timeout = time.Second
+	SetConstraintLimits(limits)
+	runtime.GC()
+	_, err = evalConstraint(t, "local t = {} for i = 1, 24 do t[i] = string.rep('x', 1000000 + i) end while true do end")
+	if !errors.Is(err, ErrorConstraintHeapGrowth) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The sandbox keeps working after aborted evaluations.
+	ok, err := evalConstraint(t, "return true")
+	if err != nil || !ok {
+		t.Fatalf("unexpected result: %v (err=%v)", ok, err)
+	}
+}
+
+func TestCompiledConstraintsErrorWrapping(t *testing.T) {
+	defer SetConstraintLimits(DefaultConstraintLimits())
+
+	limits := DefaultConstraintLimits()
+	limits.Timeout = 10 * time.Millisecond
+	SetConstraintLimits(limits)
+
+	script := "while true do end"
+	v := NewVar("x")
+	v.Constraint = &script
+	constraints, err := CompileConstraints([]Var{v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constraints.IsSatisfied(0, nil); !errors.Is(err, ErrorConstraintTimeout) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -2,10 +2,17 @@ package kurobako
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 )
 
+// ErrorSolverExhausted is an error that is used when a solver has no more trials to ask (e.g., the grid search space was fully explored).
+//
+// The kurobako protocol has no way to report it, so SolverRunner stops running the solvers with this error.
+// It is intended to be handled by a solver that wraps other solvers.
+var ErrorSolverExhausted = errors.New("solver has no more trials to ask")
+
 // SolverSpec is the specification of a solver.
 type SolverSpec struct {
 	// Name is the name of the solver.
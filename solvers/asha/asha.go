@@ -0,0 +1,337 @@
+// This package provides a wrapper solver that adds asynchronous successive halving (ASHA) or
+// asynchronous Hyperband to any inner solver.
+//
+// New trials are sampled by the inner solver and evaluated up to the step of their first rung.
+// Then, each trial is paused at the rung until it is ranked in the top 1/eta of the trials of the rung,
+// and the paused trial is resumed (i.e., returned from Ask with the step of the next rung) at that time.
+// Trials that are least likely to be promoted are stopped (i.e., returned from Ask with NextStep == 0)
+// when too many trials are paused. As with the pruned trials of the Goptuna solver, a stopped trial should be told
+// once more (e.g., with empty values) after it is returned from Ask, and the solver forgets it at that time.
+//
+// The inner solver should be a single-fidelity solver: it is told the result of a trial only once,
+// when the trial reaches the last step or is stopped (in the latter case, with the latest intermediate values).
+//
+// See "A System for Massively Parallel Hyperparameter Tuning" (Li et al., 2020) for the details.
+package asha
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the ASHA solver.
+type Options struct {
+	// Eta is the reduction factor: the top 1/eta trials of each rung are promoted to the next rung.
+	Eta float64
+
+	// MinStep is the step of the first rung.
+	//
+	// If this is zero, the first step of the problem is used.
+	// The steps of the succeeding rungs are the smallest steps of the problem that are at least eta times larger than
+	// the step of the previous rung (and the last step of the problem is always the last rung).
+	MinStep uint64
+
+	// Brackets is the number of the brackets of Hyperband.
+	//
+	// If this is 1, the solver is ASHA. Otherwise, new trials are assigned to the brackets in a round-robin manner,
+	// and the trials of the i-th bracket start from the i-th rung.
+	Brackets int
+
+	// MaxPausedTrials is the maximum number of paused trials in a bracket.
+	//
+	// If more trials are paused, the trial that has the worst relative rank in its rung is stopped.
+	// If this is zero, paused trials are never stopped.
+	MaxPausedTrials int
+}
+
+// DefaultOptions returns the default options of the ASHA solver.
+func DefaultOptions() Options {
+	return Options{
+		Eta:             3,
+		Brackets:        1,
+		MaxPausedTrials: 100,
+	}
+}
+
+// SolverFactory is a SolverFactory for the ASHA solver.
+type SolverFactory struct {
+	inner   kurobako.SolverFactory
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance that wraps the given inner solver factory.
+func NewSolverFactory(inner kurobako.SolverFactory, options Options) *SolverFactory {
+	return &SolverFactory{inner, options}
+}
+
+// Specification returns the specification of the solver.
+//
+// The capabilities are the ones of the inner solver except for multi-objective optimization
+// (trials are ranked by their first values).
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	innerSpec, err := r.inner.Specification()
+	if err != nil {
+		return nil, err
+	}
+
+	name := "ASHA"
+	if r.options.Brackets > 1 {
+		name = "Hyperband"
+	}
+
+	spec := kurobako.NewSolverSpec(fmt.Sprintf("%s (%s)", name, innerSpec.Name))
+	for k, v := range innerSpec.Attrs {
+		spec.Attrs[k] = v
+	}
+	spec.Attrs["eta"] = strconv.FormatFloat(r.options.Eta, 'g', -1, 64)
+	spec.Attrs["brackets"] = strconv.Itoa(r.options.Brackets)
+	spec.Capabilities = innerSpec.Capabilities &^ kurobako.MultiObjective
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.Eta <= 1 {
+		return nil, fmt.Errorf("eta must be greater than 1: %v", r.options.Eta)
+	}
+
+	rungs, err := rungSteps(problem.Steps, r.options.MinStep, r.options.Eta)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.options.Brackets < 1 || r.options.Brackets > len(rungs) {
+		return nil, fmt.Errorf("the number of brackets must be in [1, %d]: %d", len(rungs), r.options.Brackets)
+	}
+	brackets := make([]*bracket, r.options.Brackets)
+	for i := range brackets {
+		brackets[i] = &bracket{minRung: i, rungs: make([][]record, len(rungs))}
+	}
+
+	inner, err := r.inner.CreateSolver(seed, problem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		inner:    inner,
+		options:  r.options,
+		steps:    rungs,
+		brackets: brackets,
+		trials:   map[uint64]*trialState{},
+	}, nil
+}
+
+// rungSteps selects the steps of the rungs from the steps of a problem.
+//
+// The steps are skipped by Steps.Next, so the cost doesn't depend on the number of the steps of the problem.
+func rungSteps(steps kurobako.Steps, minStep uint64, eta float64) ([]uint64, error) {
+	if minStep == 0 {
+		minStep = steps.First()
+	}
+	if !steps.Contains(minStep) {
+		return nil, fmt.Errorf("the problem doesn't have the step %d", minStep)
+	}
+
+	rungs := []uint64{minStep}
+	for {
+		last := rungs[len(rungs)-1]
+		if last == steps.Last() {
+			return rungs, nil
+		}
+
+		// The next rung is the smallest step that is at least eta times larger than the last rung.
+		target := math.Ceil(float64(last) * eta)
+		step, ok := steps.Last(), false
+		if target <= float64(steps.Last()) {
+			step, ok = steps.Next(uint64(target) - 1)
+		}
+		if !ok {
+			step = steps.Last()
+		}
+		rungs = append(rungs, step)
+	}
+}
+
+type status int
+
+const (
+	running status = iota
+	paused
+	finished
+
+	// stopping is the status of a stopped trial that hasn't been returned from Ask yet.
+	stopping
+
+	// stopped is the status of a stopped trial that has been returned from Ask but hasn't been told yet.
+	stopped
+)
+
+type trialState struct {
+	id      uint64
+	bracket *bracket
+	rung    int
+	status  status
+
+	// step and values are the latest evaluation result of the trial.
+	step   uint64
+	values []float64
+}
+
+type bracket struct {
+	minRung int
+
+	// rungs[k] is the results of the trials that have reached the k-th rung (sorted by their values).
+	rungs [][]record
+
+	// paused is the number of the paused trials in the bracket.
+	paused int
+}
+
+type record struct {
+	trial *trialState
+	value float64
+}
+
+// Solver is the ASHA solver.
+type Solver struct {
+	inner    kurobako.Solver
+	options  Options
+	steps    []uint64
+	brackets []*bracket
+	trials   map[uint64]*trialState
+
+	// stopped is the trials to be stopped by the succeeding asks.
+	stopped []*trialState
+
+	nextBracket int
+}
+
+// Ask stops a trial, resumes a promotable trial or asks the inner solver for a new trial (in this order).
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	if len(r.stopped) > 0 {
+		t := r.stopped[0]
+		r.stopped = r.stopped[1:]
+		t.status = stopped
+		return kurobako.NextTrial{TrialID: t.id, Params: []*float64{}, NextStep: 0}, nil
+	}
+
+	if t := r.findPromotable(); t != nil {
+		t.status = running
+		t.bracket.paused--
+		t.rung++
+		return kurobako.NextTrial{TrialID: t.id, Params: []*float64{}, NextStep: r.steps[t.rung]}, nil
+	}
+
+	trial, err := r.inner.Ask(idg)
+	if err != nil {
+		return trial, err
+	}
+	if _, ok := r.trials[trial.TrialID]; ok {
+		return trial, fmt.Errorf("the inner solver asked the existing trial %d again", trial.TrialID)
+	}
+
+	b := r.brackets[r.nextBracket]
+	r.nextBracket = (r.nextBracket + 1) % len(r.brackets)
+
+	r.trials[trial.TrialID] = &trialState{id: trial.TrialID, bracket: b, rung: b.minRung}
+	trial.NextStep = r.steps[b.minRung]
+	return trial, nil
+}
+
+// Tell records the result of a trial at its current rung.
+//
+// The tell that follows the stop of a trial is accepted without telling the inner solver again
+// (it has been told the latest intermediate values when the trial was stopped).
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	t, ok := r.trials[trial.TrialID]
+	if ok && t.status == stopped {
+		delete(r.trials, t.id)
+		return nil
+	}
+	if !ok || t.status != running {
+		return fmt.Errorf("unknown or not running trial: %d", trial.TrialID)
+	}
+	t.step = trial.CurrentStep
+	t.values = trial.Values
+
+	if len(trial.Values) == 0 || t.rung == len(r.steps)-1 || trial.CurrentStep >= r.steps[len(r.steps)-1] {
+		// The trial is unevalable or completed.
+		return r.finish(t)
+	}
+
+	value := trial.Values[0]
+	if math.IsNaN(value) {
+		value = math.Inf(0)
+	}
+
+	b := t.bracket
+	b.rungs[t.rung] = insertRecord(b.rungs[t.rung], record{t, value})
+	t.status = paused
+	b.paused++
+
+	if r.options.MaxPausedTrials > 0 && b.paused > r.options.MaxPausedTrials {
+		if worst := r.findWorstPaused(b); worst != nil {
+			b.paused--
+			worst.status = stopping
+			r.stopped = append(r.stopped, worst)
+			return r.tellInner(worst)
+		}
+	}
+	return nil
+}
+
+func (r *Solver) finish(t *trialState) error {
+	t.status = finished
+	delete(r.trials, t.id)
+	return r.tellInner(t)
+}
+
+func (r *Solver) tellInner(t *trialState) error {
+	return r.inner.Tell(kurobako.EvaluatedTrial{TrialID: t.id, Values: t.values, CurrentStep: t.step})
+}
+
+// findPromotable returns a paused trial in the top 1/eta of its rung (preferring higher rungs).
+func (r *Solver) findPromotable() *trialState {
+	for k := len(r.steps) - 2; k >= 0; k-- {
+		for _, b := range r.brackets {
+			candidates := b.rungs[k]
+			top := int(float64(len(candidates)) / r.options.Eta)
+			for _, c := range candidates[:top] {
+				if c.trial.status == paused && c.trial.rung == k {
+					return c.trial
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// findWorstPaused returns the paused trial that has the worst relative rank in its rung.
+func (r *Solver) findWorstPaused(b *bracket) *trialState {
+	var worst *trialState
+	worstRank := -1.0
+	for _, rung := range b.rungs {
+		for i, c := range rung {
+			rank := float64(i+1) / float64(len(rung))
+			if c.trial.status == paused && rank > worstRank {
+				worst = c.trial
+				worstRank = rank
+			}
+		}
+	}
+	return worst
+}
+
+// insertRecord inserts the record into the sorted records (after the records that have the same value).
+func insertRecord(records []record, x record) []record {
+	i := sort.Search(len(records), func(i int) bool { return records[i].value > x.value })
+	records = append(records, record{})
+	copy(records[i+1:], records[i:])
+	records[i] = x
+	return records
+}
@@ -0,0 +1,189 @@
+package asha
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/solvers/qmc"
+)
+
+func TestRungSteps(t *testing.T) {
+	steps, err := kurobako.NewSteps([]uint64{1, 2, 3, 5, 9, 10, 27, 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rungs, err := rungSteps(*steps, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint64{1, 3, 9, 27, 30}
+	if len(rungs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, rungs)
+	}
+	for i := range expected {
+		if rungs[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, rungs)
+		}
+	}
+
+	if _, err := rungSteps(*steps, 4, 3); err == nil {
+		t.Fatal("expected an unknown step error")
+	}
+
+	// A large sequential range is never enumerated.
+	var large kurobako.Steps
+	if err := json.Unmarshal([]byte("1000000000"), &large); err != nil {
+		t.Fatal(err)
+	}
+	rungs, err = rungSteps(large, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rungs) != 10 || rungs[1] != 10 || rungs[9] != 1000000000 {
+		t.Fatalf("unexpected rungs: %v", rungs)
+	}
+}
+
+func TestInsertRecord(t *testing.T) {
+	var records []record
+	for i, v := range []float64{3, 1, 2, 1, math.Inf(0), 0} {
+		records = insertRecord(records, record{&trialState{id: uint64(i)}, v})
+	}
+
+	var ids []uint64
+	for i, r := range records {
+		if i > 0 && records[i-1].value > r.value {
+			t.Fatalf("the records aren't sorted: %v", records)
+		}
+		ids = append(ids, r.trial.id)
+	}
+	if !reflect.DeepEqual(ids, []uint64{5, 1, 3, 2, 0, 4}) {
+		t.Fatalf("unexpected order: %v", ids)
+	}
+}
+
+// recordingFactory wraps a solver factory to record the trials told to its solvers.
+type recordingFactory struct {
+	kurobako.SolverFactory
+	told []kurobako.EvaluatedTrial
+}
+
+func (r *recordingFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	solver, err := r.SolverFactory.CreateSolver(seed, problem)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSolver{solver, r}, nil
+}
+
+type recordingSolver struct {
+	kurobako.Solver
+	factory *recordingFactory
+}
+
+func (r *recordingSolver) Tell(trial kurobako.EvaluatedTrial) error {
+	r.factory.told = append(r.factory.told, trial)
+	return r.Solver.Tell(trial)
+}
+
+func TestSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("curve").
+		Continuous("x", 0.0, 1.0).
+		Steps(1, 3, 9, 27).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, brackets := range []int{1, 2} {
+		inner := &recordingFactory{SolverFactory: qmc.NewSolverFactory(qmc.DefaultOptions())}
+		options := DefaultOptions()
+		options.Brackets = brackets
+		options.MaxPausedTrials = 20
+		solver, err := NewSolverFactory(inner, options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// xs and steps are the params and the latest steps of the running trials.
+		xs := map[uint64]float64{}
+		steps := map[uint64]uint64{}
+		stopped := map[uint64]bool{}
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 500; i++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if x, ok := xs[trial.TrialID]; ok {
+				if trial.NextStep == 0 {
+					stopped[trial.TrialID] = true
+					delete(xs, trial.TrialID)
+					if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{}, CurrentStep: steps[trial.TrialID]}); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if trial.NextStep <= steps[trial.TrialID] {
+					t.Fatalf("trial %d is resumed at step %d (<= %d)", trial.TrialID, trial.NextStep, steps[trial.TrialID])
+				}
+				steps[trial.TrialID] = trial.NextStep
+				value := x + 1/float64(trial.NextStep)
+				evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: trial.NextStep}
+				if err := solver.Tell(evaluated); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+			if trial.NextStep != 1 && !(brackets == 2 && trial.NextStep == 3) {
+				t.Fatalf("unexpected first step: %d", trial.NextStep)
+			}
+			x := *trial.Params[0]
+			xs[trial.TrialID] = x
+			steps[trial.TrialID] = trial.NextStep
+			value := x + 1/float64(trial.NextStep)
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: trial.NextStep}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+			if trial.NextStep == 27 {
+				delete(xs, trial.TrialID)
+			}
+		}
+
+		completed := 0
+		told := map[uint64]bool{}
+		for _, trial := range inner.told {
+			if told[trial.TrialID] {
+				t.Fatalf("trial %d is told to the inner solver twice", trial.TrialID)
+			}
+			told[trial.TrialID] = true
+			if trial.CurrentStep == 27 {
+				completed++
+			} else if !stopped[trial.TrialID] {
+				t.Fatalf("trial %d is told to the inner solver at step %d", trial.TrialID, trial.CurrentStep)
+			}
+		}
+		if completed == 0 {
+			t.Fatalf("brackets=%d: no trials have been completed", brackets)
+		}
+		if len(stopped) == 0 {
+			t.Fatalf("brackets=%d: no trials have been stopped", brackets)
+		}
+		for id := range stopped {
+			if _, ok := solver.(*Solver).trials[id]; ok {
+				t.Fatalf("the stopped trial %d isn't forgotten after the follow-up tell", id)
+			}
+		}
+	}
+}
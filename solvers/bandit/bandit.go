@@ -0,0 +1,420 @@
+// This package provides a solver that treats each categorical (or small discrete) parameter as a bandit.
+//
+// The solver is factored: every such parameter has its own arms (one for each choice), and the arm of each
+// parameter is selected independently by Thompson sampling or by an upper confidence bound.
+// The value of a trial is the reward of all the arms that are active in the trial.
+//
+// The mean of each arm has a Gaussian posterior whose prior is the mean and the variance of all the observed values,
+// and whose observation noise is the pooled variance within the arms of the parameter. So repeated evaluations of
+// a noisy objective are averaged rather than trusted as they are.
+//
+// The other parameters (continuous ones and discrete ones that have too many values) are delegated to an inner solver.
+// The inner solver is given a problem that consists only of these parameters (without their constraints),
+// so it always samples all of them, and the ones that are inactive under the selected arms are removed from the trial.
+// If the inner solver factory is nil, these parameters are sampled uniformly at random.
+package bandit
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Policy is the policy that selects the arms.
+type Policy int
+
+const (
+	// ThompsonSampling selects the arm whose value sampled from the posterior is the smallest.
+	ThompsonSampling Policy = iota
+
+	// UpperConfidenceBound selects the arm whose upper confidence bound (of the negated objective) is the largest
+	// (after every arm has been selected once).
+	UpperConfidenceBound
+)
+
+// String returns the string representation of a Policy value.
+func (r Policy) String() string {
+	switch r {
+	case ThompsonSampling:
+		return "ThompsonSampling"
+	case UpperConfidenceBound:
+		return "UCB"
+	default:
+		panic("unknown policy")
+	}
+}
+
+// Options is the options of the bandit solver.
+type Options struct {
+	// Policy is the policy that selects the arms.
+	Policy Policy
+
+	// Exploration is the coefficient c of the confidence bound -(mean - c * sd * sqrt(log n)),
+	// where sd is the posterior standard deviation of the arm and n is the number of the selections of the parameter.
+	Exploration float64
+
+	// MaxDiscreteArms is the maximum number of the values of a discrete parameter handled as a bandit.
+	//
+	// Discrete parameters that have more values are delegated to the inner solver.
+	MaxDiscreteArms int
+}
+
+// DefaultOptions returns the default options of the bandit solver.
+func DefaultOptions() Options {
+	return Options{
+		Policy:          ThompsonSampling,
+		Exploration:     math.Sqrt2,
+		MaxDiscreteArms: 16,
+	}
+}
+
+// minNoiseRatio is the lower bound of the ratio of the observation noise variance to the prior variance.
+//
+// It keeps the posteriors from collapsing when the objective happens to be deterministic.
+const minNoiseRatio = 1e-2
+
+// SolverFactory is a SolverFactory for the bandit solver.
+type SolverFactory struct {
+	inner   kurobako.SolverFactory
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+//
+// The inner solver factory optimizes the parameters that aren't handled as bandits (it can be nil).
+func NewSolverFactory(inner kurobako.SolverFactory, options Options) *SolverFactory {
+	return &SolverFactory{inner, options}
+}
+
+// Specification returns the specification of the solver.
+//
+// If there is an inner solver, the numerical and concurrency capabilities are the ones of the inner solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if r.options.Policy != ThompsonSampling && r.options.Policy != UpperConfidenceBound {
+		return nil, fmt.Errorf("unknown policy: %d", r.options.Policy)
+	}
+
+	if r.inner == nil {
+		spec := kurobako.NewSolverSpec("Factored Bandit")
+		spec.Attrs["policy"] = r.options.Policy.String()
+		spec.Attrs["max_discrete_arms"] = strconv.Itoa(r.options.MaxDiscreteArms)
+		spec.Capabilities = kurobako.AllCapabilities &^ kurobako.MultiObjective
+		return &spec, nil
+	}
+
+	innerSpec, err := r.inner.Specification()
+	if err != nil {
+		return nil, err
+	}
+
+	spec := kurobako.NewSolverSpec(fmt.Sprintf("Factored Bandit (%s)", innerSpec.Name))
+	for k, v := range innerSpec.Attrs {
+		spec.Attrs[k] = v
+	}
+	spec.Attrs["policy"] = r.options.Policy.String()
+	spec.Attrs["max_discrete_arms"] = strconv.Itoa(r.options.MaxDiscreteArms)
+	spec.Capabilities = innerSpec.Capabilities&(kurobako.UniformContinuous|
+		kurobako.UniformDiscrete|
+		kurobako.LogUniformContinuous|
+		kurobako.LogUniformDiscrete|
+		kurobako.Concurrent) |
+		kurobako.Categorical |
+		kurobako.Conditional
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.Policy != ThompsonSampling && options.Policy != UpperConfidenceBound {
+		return nil, fmt.Errorf("unknown policy: %d", options.Policy)
+	}
+	if options.Exploration < 0 {
+		return nil, fmt.Errorf("exploration must be non-negative: %v", options.Exploration)
+	}
+	if options.MaxDiscreteArms < 0 {
+		return nil, fmt.Errorf("max discrete arms must be non-negative: %d", options.MaxDiscreteArms)
+	}
+
+	var bandits []*bandit
+	var delegated []int
+	innerProblem := problem
+	innerProblem.Params = nil
+	for i, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+
+		var values []float64
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			for c := range categorical.Choices {
+				values = append(values, float64(c))
+			}
+		} else if discrete := v.Range.AsDiscreteRange(); discrete != nil && discrete.High-discrete.Low <= int64(options.MaxDiscreteArms) {
+			for x := discrete.Low; x < discrete.High; x++ {
+				values = append(values, float64(x))
+			}
+		}
+
+		if values == nil {
+			delegated = append(delegated, i)
+			v.Constraint = nil
+			innerProblem.Params = append(innerProblem.Params, v)
+			continue
+		}
+		bandits = append(bandits, &bandit{index: i, values: values, arms: make([]statistics, len(values))})
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	var inner kurobako.Solver
+	if r.inner != nil && len(delegated) > 0 {
+		inner, err = r.inner.CreateSolver(rng.Int63(), innerProblem)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rng,
+		inner:       inner,
+		delegated:   delegated,
+		bandits:     bandits,
+		pending:     map[uint64][]int{},
+	}, nil
+}
+
+// statistics is the sufficient statistics of observed values.
+//
+// Unevalable trials are counted separately, and regarded as having the worst value observed so far.
+type statistics struct {
+	count    int
+	failures int
+	sum      float64
+	squared  float64
+
+	// pending is the number of the trials that have been asked but not told yet.
+	pending int
+}
+
+func (r *statistics) add(value float64, failed bool) {
+	if failed {
+		r.failures++
+		return
+	}
+	r.count++
+	r.sum += value
+	r.squared += value * value
+}
+
+// moments returns the number of the observations, their sum and their sum of squares.
+func (r *statistics) moments(worst float64) (float64, float64, float64) {
+	f := float64(r.failures)
+	return float64(r.count) + f, r.sum + f*worst, r.squared + f*worst*worst
+}
+
+// bandit is the arms of a parameter.
+type bandit struct {
+	index int
+
+	// values is the parameter value of each arm.
+	values []float64
+	arms   []statistics
+}
+
+// Solver is the bandit solver.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	// inner is the solver of the delegated parameters (nil if there is no inner solver).
+	inner     kurobako.Solver
+	delegated []int
+	bandits   []*bandit
+
+	global statistics
+	worst  float64
+
+	// pending is the selected arm of each bandit (-1 if the parameter is inactive) of the trials
+	// that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64][]int
+}
+
+// Ask selects an arm of each bandit, and combines them with the parameters suggested by the inner solver.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	params := make([]*float64, len(r.problem.Params))
+	if r.inner != nil {
+		innerTrial, err := r.inner.Ask(idg)
+		if err != nil {
+			return trial, err
+		}
+		if _, ok := r.pending[innerTrial.TrialID]; ok {
+			return trial, fmt.Errorf("the inner solver asked a pending trial: %d", innerTrial.TrialID)
+		}
+		if len(innerTrial.Params) != len(r.delegated) {
+			return trial, fmt.Errorf("the inner solver asked %d params (expected %d)", len(innerTrial.Params), len(r.delegated))
+		}
+		trial.TrialID = innerTrial.TrialID
+		for j, i := range r.delegated {
+			params[i] = innerTrial.Params[j]
+		}
+	} else {
+		trial.TrialID = idg.Generate()
+	}
+	for _, i := range r.delegated {
+		if params[i] == nil {
+			value := r.problem.Params[i].FromUnit(r.rng.Float64())
+			params[i] = &value
+		}
+	}
+
+	selected := make([]int, len(r.bandits))
+	for k, b := range r.bandits {
+		selected[k] = r.selectArm(b)
+		value := b.values[selected[k]]
+		params[b.index] = &value
+	}
+
+	if _, err := r.constraints.DropInactive(params); err != nil {
+		return trial, err
+	}
+	for k, b := range r.bandits {
+		if params[b.index] == nil {
+			selected[k] = -1
+		} else {
+			b.arms[selected[k]].pending++
+		}
+	}
+
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = selected
+	return trial, nil
+}
+
+// Tell updates the arms that were active in the trial, and tells the result to the inner solver.
+//
+// Unevalable trials (and non-finite values) are regarded as having the worst value observed so far.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	selected, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	value, failed := 0.0, true
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) && !math.IsInf(trial.Values[0], 0) {
+		value, failed = trial.Values[0], false
+		if r.global.count == 0 || value > r.worst {
+			r.worst = value
+		}
+	}
+
+	r.global.add(value, failed)
+	for k, b := range r.bandits {
+		if selected[k] >= 0 {
+			b.arms[selected[k]].pending--
+			b.arms[selected[k]].add(value, failed)
+		}
+	}
+
+	if r.inner != nil {
+		return r.inner.Tell(trial)
+	}
+	return nil
+}
+
+// selectArm returns the index of the arm selected by the policy.
+func (r *Solver) selectArm(b *bandit) int {
+	if r.global.count == 0 {
+		// There is no information about the scale of the objective yet.
+		return r.rng.Intn(len(b.arms))
+	}
+
+	var unselected []int
+	selections := 0.0
+	for i := range b.arms {
+		n, _, _ := b.arms[i].moments(r.worst)
+		if n+float64(b.arms[i].pending) == 0 {
+			unselected = append(unselected, i)
+		}
+		selections += n + float64(b.arms[i].pending)
+	}
+	if r.options.Policy == UpperConfidenceBound && len(unselected) > 0 {
+		return unselected[r.rng.Intn(len(unselected))]
+	}
+
+	means, sds := r.posterior(b)
+	best, bestScore := 0, math.Inf(0)
+	for i := range b.arms {
+		var score float64
+		switch r.options.Policy {
+		case ThompsonSampling:
+			score = means[i] + sds[i]*r.rng.NormFloat64()
+		case UpperConfidenceBound:
+			score = means[i] - r.options.Exploration*sds[i]*math.Sqrt(math.Log(selections))
+		}
+		if score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// posterior returns the posterior means and standard deviations of the means of the arms.
+//
+// Each mean is shrunk towards the prior mean by the ratio of the observation noise to the number of the observations.
+func (r *Solver) posterior(b *bandit) ([]float64, []float64) {
+	// The prior of the means of the arms.
+	n, sum, squared := r.global.moments(r.worst)
+	priorMean := sum / n
+	priorVariance := squared/n - priorMean*priorMean
+	if priorVariance <= 0 {
+		priorVariance = 1
+	}
+
+	// The observation noise is the pooled variance within the arms.
+	sse, dof := 0.0, 0.0
+	for i := range b.arms {
+		n, sum, squared := b.arms[i].moments(r.worst)
+		if n > 0 {
+			sse += math.Max(squared-sum*sum/n, 0)
+			dof += n - 1
+		}
+	}
+	noiseVariance := priorVariance
+	if dof >= 1 {
+		noiseVariance = math.Max(sse/dof, priorVariance*minNoiseRatio)
+	}
+
+	means := make([]float64, len(b.arms))
+	sds := make([]float64, len(b.arms))
+	for i := range b.arms {
+		// The pending trials narrow the posterior (as if they had the observed mean), so that concurrent
+		// asks are spread over the arms.
+		n, sum, _ := b.arms[i].moments(r.worst)
+		mean := priorMean
+		if n > 0 {
+			mean = sum / n
+		}
+		n += float64(b.arms[i].pending)
+		precision := 1/priorVariance + n/noiseVariance
+		means[i] = (priorMean/priorVariance + n*mean/noiseVariance) / precision
+		sds[i] = math.Sqrt(1 / precision)
+	}
+	return means, sds
+}
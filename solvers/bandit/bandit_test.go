@@ -0,0 +1,150 @@
+package bandit
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+	"github.com/sile/kurobako-go/solvers/qmc"
+)
+
+func TestSolverNoisy(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("noisy").
+		Categorical("model", "a", "b", "c", "d").
+		Discrete("flag", 0, 2).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The best arms are "c" and 1, but the noise is larger than the differences between the arms.
+	means := []float64{1, 0.8, 0, 0.5}
+	noise := rand.New(rand.NewSource(0))
+	f := func(params []*float64) []float64 {
+		return []float64{means[int(*params[0])] - 0.5**params[1] + noise.NormFloat64()}
+	}
+
+	for _, policy := range []Policy{ThompsonSampling, UpperConfidenceBound} {
+		options := DefaultOptions()
+		options.Policy = policy
+		solver, err := NewSolverFactory(nil, options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts := [][]int{make([]int, 4), make([]int, 2)}
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 100; i++ {
+			// Asks four trials concurrently.
+			var trials []kurobako.NextTrial
+			for j := 0; j < 4; j++ {
+				trial, err := solver.Ask(&idg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := spec.CheckTrialParams(trial.Params); err != nil {
+					t.Fatal(err)
+				}
+				trials = append(trials, trial)
+			}
+
+			for _, trial := range trials {
+				if i >= 50 {
+					counts[0][int(*trial.Params[0])]++
+					counts[1][int(*trial.Params[1])]++
+				}
+				evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: f(trial.Params), CurrentStep: trial.NextStep}
+				if err := solver.Tell(evaluated); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		for k, best := range []int{2, 1} {
+			for c, count := range counts[k] {
+				if c != best && count >= counts[k][best] {
+					t.Errorf("%v: the arm %d of the param %d is selected more often than the best one: %v", policy, c, k, counts[k])
+				}
+			}
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	for _, inner := range []kurobako.SolverFactory{nil, qmc.NewSolverFactory(qmc.DefaultOptions())} {
+		evaluations := solvertest.RunConditional(t, NewSolverFactory(inner, DefaultOptions()), 1, 50, 2)
+
+		// The arm of the unevalable choice is rarely selected once it has been observed.
+		unevalable := 0
+		for _, e := range evaluations[50:] {
+			if e.Values == nil {
+				unevalable++
+			}
+		}
+		if unevalable > 10 {
+			t.Errorf("the unevalable choice is selected too often: %d", unevalable)
+		}
+	}
+}
+
+func TestPosteriorShrinkage(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("arms").
+		Categorical("c", "a", "b").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// posterior returns the posterior of the arms that have n0 observations around -1 and n1 observations around 1
+	// (with the given noise).
+	posterior := func(n0 int, n1 int, noise float64) ([]float64, []float64) {
+		s, err := NewSolverFactory(nil, DefaultOptions()).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		solver := s.(*Solver)
+		b := solver.bandits[0]
+		for i, n := range []int{n0, n1} {
+			for k := 0; k < n; k++ {
+				value := float64(2*i-1) + noise*float64(2*(k%2)-1)
+				b.arms[i].add(value, false)
+				solver.global.add(value, false)
+			}
+		}
+		return solver.posterior(b)
+	}
+
+	// The prior mean is 0 for balanced observations, and each sample mean is -1 or 1.
+	means, sds := posterior(4, 4, 0.5)
+	if !(-1 < means[0] && means[0] < 0 && 0 < means[1] && means[1] < 1) {
+		t.Fatalf("the means should be shrunk towards the prior mean: %v", means)
+	}
+
+	// Noisier observations are shrunk more.
+	noisy, noisySDs := posterior(4, 4, 3)
+	if !(means[0] < noisy[0] && noisy[0] < 0) || noisySDs[0] <= sds[0] {
+		t.Fatalf("noisy observations should be shrunk more: %v (sd=%v) vs %v (sd=%v)", noisy, noisySDs, means, sds)
+	}
+
+	// More observations are shrunk less (relative to the distance between the sample mean and the prior mean).
+	many, manySDs := posterior(4, 40, 3)
+	priorMean := (-4.0 + 40.0) / 44.0
+	if (1-many[1])/(1-priorMean) >= (1-noisy[1]) || manySDs[1] >= noisySDs[1] {
+		t.Fatalf("more observations should be shrunk less: %v (sd=%v) vs %v (sd=%v)", many, manySDs, noisy, noisySDs)
+	}
+}
+
+func TestSolverInvalidPolicy(t *testing.T) {
+	options := DefaultOptions()
+	options.Policy = UpperConfidenceBound + 1
+	factory := NewSolverFactory(nil, options)
+	if _, err := factory.Specification(); err == nil {
+		t.Fatal("expected an unknown policy error")
+	}
+	if _, err := factory.CreateSolver(1, kurobako.NewProblemSpec("foo")); err == nil {
+		t.Fatal("expected an unknown policy error")
+	}
+}
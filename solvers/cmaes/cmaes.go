@@ -0,0 +1,293 @@
+// This package provides a solver based on CMA-ES (Covariance Matrix Adaptation Evolution Strategy).
+//
+// Numerical parameters are optimized in the unit box, and mapped to their ranges by kurobako.Var.FromUnit.
+// So log-scale parameters are optimized in the log space, and discrete parameters are rounded to integers.
+//
+// CMA-ES can't handle categorical and conditional parameters natively, so the following fallback is used:
+//
+//   - Categorical parameters aren't optimized: their values are sampled uniformly at random.
+//   - Conditional numerical parameters are optimized as if they were always active.
+//     If a parameter turns out to be inactive, its value is just dropped (i.e., the coordinate doesn't affect the fitness).
+package cmaes
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Restart is the restart strategy of CMA-ES.
+//
+// A run is terminated when its step size or the variation of the fitness becomes negligible, or when
+// the covariance matrix becomes ill-conditioned. The next run starts from a random point in the unit box.
+type Restart int
+
+const (
+	// PlainRestart indicates that each restart uses the same population size and initial step size.
+	PlainRestart Restart = iota
+
+	// IPOP indicates that the population size is doubled at each restart.
+	IPOP
+
+	// BIPOP indicates that restarts alternate between a regime with increasing large populations and
+	// a regime with small populations and small initial step sizes.
+	BIPOP
+)
+
+func (r Restart) validate() error {
+	if r < PlainRestart || r > BIPOP {
+		return fmt.Errorf("unknown restart strategy: %d", r)
+	}
+	return nil
+}
+
+// Options is the options of the CMA-ES solver.
+type Options struct {
+	// Sigma0 is the initial step size in the unit box.
+	Sigma0 float64
+
+	// PopulationSize is the population size of the first run.
+	//
+	// If this is zero, the default size 4 + floor(3 ln n) is used (where n is the number of numerical parameters).
+	PopulationSize int
+
+	// Restart is the restart strategy used after a run converges.
+	Restart Restart
+}
+
+// DefaultOptions returns the default options of the CMA-ES solver.
+func DefaultOptions() Options {
+	return Options{
+		Sigma0:  0.3,
+		Restart: BIPOP,
+	}
+}
+
+// SolverFactory is a SolverFactory for the CMA-ES solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if err := r.options.Restart.validate(); err != nil {
+		return nil, err
+	}
+
+	spec := kurobako.NewSolverSpec("CMA-ES")
+	spec.Attrs["sigma0"] = strconv.FormatFloat(r.options.Sigma0, 'g', -1, 64)
+	spec.Attrs["restart"] = [...]string{"plain", "IPOP", "BIPOP"}[r.options.Restart]
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.Sigma0 <= 0 {
+		return nil, fmt.Errorf("sigma0 must be positive: %v", r.options.Sigma0)
+	}
+	if err := r.options.Restart.validate(); err != nil {
+		return nil, err
+	}
+
+	var dims []int
+	var tolX []float64
+	for i, v := range problem.Params {
+		if v.Range.AsCategoricalRange() != nil {
+			continue
+		}
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+
+		dims = append(dims, i)
+		if x := v.Range.AsDiscreteRange(); x != nil {
+			// A discrete parameter is converged if the step size is much smaller than the width of a cell.
+			tolX = append(tolX, 0.1/float64(x.High-x.Low))
+		} else {
+			tolX = append(tolX, 1e-12)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultSize := defaultPopulationSize(len(dims))
+	if r.options.PopulationSize > 0 {
+		defaultSize = r.options.PopulationSize
+	}
+	if defaultSize < 2 {
+		defaultSize = 2
+	}
+
+	return &Solver{
+		options:     r.options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		dims:        dims,
+		tolX:        tolX,
+		defaultSize: defaultSize,
+		largeSize:   defaultSize,
+		pending:     map[uint64]candidate{},
+	}, nil
+}
+
+// Solver is the CMA-ES solver.
+//
+// Concurrent asks are supported: a generation keeps sampling candidates until lambda of them have been told,
+// and the results of the candidates that are told after the generation has been updated are discarded.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	// dims is the indices of the parameters optimized by CMA-ES.
+	dims []int
+	tolX []float64
+
+	current *strategy
+
+	// run identifies the current run of CMA-ES.
+	run int
+
+	// pending is the candidates that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64]candidate
+
+	// Bookkeeping of restarts.
+	defaultSize     int
+	largeSize       int
+	largeEvals      int
+	smallEvals      int
+	isSmallRegime   bool
+	evalsInRun      int
+	hasStartedFirst bool
+}
+
+type candidate struct {
+	run        int
+	generation int
+	x          []float64
+}
+
+// Ask samples the next candidate from the current distribution.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	if r.current == nil {
+		r.restart()
+	}
+	x := r.current.sample()
+
+	trial.Params = make([]*float64, len(r.problem.Params))
+	for i, p := range r.problem.Params {
+		if categorical := p.Range.AsCategoricalRange(); categorical != nil {
+			value := float64(r.rng.Intn(len(categorical.Choices)))
+			trial.Params[i] = &value
+		}
+	}
+	for j, i := range r.dims {
+		value := r.problem.Params[i].FromUnit(x[j])
+		trial.Params[i] = &value
+	}
+
+	if _, err := r.constraints.DropInactive(trial.Params); err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = candidate{r.run, r.current.generation, x}
+	return trial, nil
+}
+
+// Tell updates the distribution once lambda candidates of the current generation have been evaluated.
+//
+// Unevalable trials are regarded as the worst candidates.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	c, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	if r.current == nil || c.run != r.run || c.generation != r.current.generation {
+		// The candidate belongs to an already updated generation (or a terminated run).
+		return nil
+	}
+
+	fitness := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		fitness = trial.Values[0]
+	}
+
+	r.evalsInRun++
+	if r.current.tell(c.x, fitness) {
+		r.current = nil
+	}
+	return nil
+}
+
+// restart starts a new run of CMA-ES according to the restart strategy.
+func (r *Solver) restart() {
+	if r.isSmallRegime {
+		r.smallEvals += r.evalsInRun
+	} else {
+		r.largeEvals += r.evalsInRun
+	}
+	r.evalsInRun = 0
+	r.run++
+
+	mean := make([]float64, len(r.dims))
+	for j := range mean {
+		mean[j] = 0.5
+		if r.hasStartedFirst {
+			mean[j] = r.rng.Float64()
+		}
+	}
+
+	lambda := r.defaultSize
+	sigma := r.options.Sigma0
+	r.isSmallRegime = false
+	if r.hasStartedFirst {
+		switch r.options.Restart {
+		case IPOP:
+			r.largeSize *= 2
+			lambda = r.largeSize
+		case BIPOP:
+			if r.largeEvals <= r.smallEvals {
+				r.largeSize *= 2
+				lambda = r.largeSize
+			} else {
+				u := r.rng.Float64()
+				ratio := math.Max(0.5*float64(r.largeSize)/float64(r.defaultSize), 1)
+				lambda = int(float64(r.defaultSize) * math.Pow(ratio, u*u))
+				sigma = r.options.Sigma0 * math.Pow(10, -2*u)
+				r.isSmallRegime = true
+			}
+		}
+	}
+	r.hasStartedFirst = true
+
+	if lambda < 2 {
+		lambda = 2
+	}
+	r.current = newStrategy(mean, sigma, lambda, r.tolX, r.rng)
+}
@@ -0,0 +1,67 @@
+package cmaes
+
+import (
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+)
+
+func TestSolverMinimizesSphere(t *testing.T) {
+	for _, restart := range []Restart{PlainRestart, IPOP, BIPOP} {
+		options := DefaultOptions()
+		options.Restart = restart
+		if best := solvertest.RunSphere(t, NewSolverFactory(options), 150, 4); best > 1e-3 {
+			t.Fatalf("restart=%v: CMA-ES didn't converge: %v", restart, best)
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	solvertest.RunConditional(t, NewSolverFactory(DefaultOptions()), 1, 50, 1)
+}
+
+func TestSolverInvalidRestart(t *testing.T) {
+	options := DefaultOptions()
+	options.Restart = BIPOP + 1
+	factory := NewSolverFactory(options)
+	if _, err := factory.Specification(); err == nil {
+		t.Fatal("expected an unknown restart strategy error")
+	}
+	if _, err := factory.CreateSolver(1, kurobako.NewProblemSpec("foo")); err == nil {
+		t.Fatal("expected an unknown restart strategy error")
+	}
+}
+
+func TestSolverWithoutNumericalParams(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("categorical").
+		Categorical("c", "a", "b", "c").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defaultPopulationSize(0) != 4 {
+		t.Fatalf("unexpected population size: %d", defaultPopulationSize(0))
+	}
+
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(1, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 20; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.CheckTrialParams(trial.Params); err != nil {
+			t.Fatal(err)
+		}
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{*trial.Params[0]}, CurrentStep: 1}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package cmaes
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sile/kurobako-go/internal/linalg"
+)
+
+const (
+	// maxResampling is the maximum number of the resamplings of a candidate that is out of the unit box.
+	//
+	// If all the resamplings fail, the candidate is clipped to the box.
+	maxResampling = 10
+
+	tolFun       = 1e-12
+	maxCondition = 1e14
+)
+
+// strategy is the state of a single CMA-ES run in the unit box [0, 1]^n.
+//
+// See "The CMA Evolution Strategy: A Tutorial" (Hansen, 2016) for the notations.
+type strategy struct {
+	rng *rand.Rand
+	n   int
+
+	lambda  int
+	mu      int
+	weights []float64
+	muEff   float64
+	cc      float64
+	cSigma  float64
+	c1      float64
+	cMu     float64
+	dSigma  float64
+	chiN    float64
+
+	mean   []float64
+	sigma  float64
+	c      [][]float64
+	b      [][]float64
+	d      []float64
+	pc     []float64
+	pSigma []float64
+
+	// tolX is the per-coordinate tolerance of the standard deviation.
+	tolX []float64
+
+	generation int
+	history    []float64
+	results    []result
+}
+
+type result struct {
+	x       []float64
+	fitness float64
+}
+
+func newStrategy(mean []float64, sigma float64, lambda int, tolX []float64, rng *rand.Rand) *strategy {
+	n := len(mean)
+	mu := lambda / 2
+
+	weights := make([]float64, mu)
+	sum := 0.0
+	for i := range weights {
+		weights[i] = math.Log(float64(lambda+1)/2) - math.Log(float64(i+1))
+		sum += weights[i]
+	}
+	sumSquared := 0.0
+	for i := range weights {
+		weights[i] /= sum
+		sumSquared += weights[i] * weights[i]
+	}
+	muEff := 1 / sumSquared
+
+	fn := float64(n)
+	cc := (4 + muEff/fn) / (fn + 4 + 2*muEff/fn)
+	cSigma := (muEff + 2) / (fn + muEff + 5)
+	c1 := 2 / ((fn+1.3)*(fn+1.3) + muEff)
+	cMu := math.Min(1-c1, 2*(muEff-2+1/muEff)/((fn+2)*(fn+2)+muEff))
+	dSigma := 1 + 2*math.Max(0, math.Sqrt((muEff-1)/(fn+1))-1) + cSigma
+	chiN := math.Sqrt(fn) * (1 - 1/(4*fn) + 1/(21*fn*fn))
+
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = 1
+	}
+
+	return &strategy{
+		rng:     rng,
+		n:       n,
+		lambda:  lambda,
+		mu:      mu,
+		weights: weights,
+		muEff:   muEff,
+		cc:      cc,
+		cSigma:  cSigma,
+		c1:      c1,
+		cMu:     cMu,
+		dSigma:  dSigma,
+		chiN:    chiN,
+		mean:    append([]float64(nil), mean...),
+		sigma:   sigma,
+		c:       linalg.Identity(n),
+		b:       linalg.Identity(n),
+		d:       d,
+		pc:      make([]float64, n),
+		pSigma:  make([]float64, n),
+		tolX:    tolX,
+	}
+}
+
+// sample draws a candidate from the current distribution (repaired into the unit box).
+func (r *strategy) sample() []float64 {
+	var x []float64
+	for i := 0; i <= maxResampling; i++ {
+		z := make([]float64, r.n)
+		for j := range z {
+			z[j] = r.rng.NormFloat64() * r.d[j]
+		}
+		y := linalg.MulVec(r.b, z)
+
+		x = make([]float64, r.n)
+		inside := true
+		for j := range x {
+			x[j] = r.mean[j] + r.sigma*y[j]
+			inside = inside && x[j] >= 0 && x[j] <= 1
+		}
+		if inside {
+			return x
+		}
+	}
+
+	for j := range x {
+		x[j] = math.Min(math.Max(x[j], 0), 1)
+	}
+	return x
+}
+
+// tell adds the evaluation result of a candidate sampled in the current generation.
+//
+// Once lambda results have been collected, the distribution is updated.
+// It returns true if the run should be terminated.
+func (r *strategy) tell(x []float64, fitness float64) bool {
+	r.results = append(r.results, result{x, fitness})
+	if r.n == 0 {
+		// There is nothing to optimize (e.g., all the parameters are categorical).
+		r.results = nil
+		return false
+	}
+	if len(r.results) < r.lambda {
+		return false
+	}
+
+	sort.SliceStable(r.results, func(i, j int) bool {
+		return r.results[i].fitness < r.results[j].fitness
+	})
+	terminate := r.update()
+	r.results = nil
+	return terminate
+}
+
+func (r *strategy) update() bool {
+	n := r.n
+	ys := make([][]float64, r.mu)
+	yw := make([]float64, n)
+	for i := range ys {
+		ys[i] = make([]float64, n)
+		for j := range ys[i] {
+			ys[i][j] = (r.results[i].x[j] - r.mean[j]) / r.sigma
+			yw[j] += r.weights[i] * ys[i][j]
+		}
+	}
+
+	for j := range r.mean {
+		r.mean[j] += r.sigma * yw[j]
+	}
+
+	// C^(-1/2) y_w = B D^(-1) B^T y_w
+	bty := make([]float64, n)
+	for k := 0; k < n; k++ {
+		for j := 0; j < n; j++ {
+			bty[k] += r.b[j][k] * yw[j]
+		}
+		bty[k] /= r.d[k]
+	}
+	invSqrtCyw := linalg.MulVec(r.b, bty)
+
+	for j := range r.pSigma {
+		r.pSigma[j] = (1-r.cSigma)*r.pSigma[j] + math.Sqrt(r.cSigma*(2-r.cSigma)*r.muEff)*invSqrtCyw[j]
+	}
+	pSigmaNorm := linalg.Norm(r.pSigma)
+
+	r.generation++
+	hSigma := 0.0
+	threshold := (1.4 + 2/float64(n+1)) * r.chiN
+	if pSigmaNorm/math.Sqrt(1-math.Pow(1-r.cSigma, 2*float64(r.generation))) < threshold {
+		hSigma = 1
+	}
+
+	for j := range r.pc {
+		r.pc[j] = (1-r.cc)*r.pc[j] + hSigma*math.Sqrt(r.cc*(2-r.cc)*r.muEff)*yw[j]
+	}
+
+	decay := 1 - r.c1 - r.cMu + (1-hSigma)*r.c1*r.cc*(2-r.cc)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			rankMu := 0.0
+			for k, y := range ys {
+				rankMu += r.weights[k] * y[i] * y[j]
+			}
+			r.c[i][j] = decay*r.c[i][j] + r.c1*r.pc[i]*r.pc[j] + r.cMu*rankMu
+			r.c[j][i] = r.c[i][j]
+		}
+	}
+
+	r.sigma *= math.Exp((r.cSigma / r.dSigma) * (pSigmaNorm/r.chiN - 1))
+
+	values, vectors, err := linalg.SymmetricEigen(r.c)
+	if err != nil || math.IsNaN(r.sigma) || math.IsInf(r.sigma, 0) {
+		return true
+	}
+	minValue, maxValue := math.Inf(0), 0.0
+	for k, v := range values {
+		if v <= 0 {
+			return true
+		}
+		r.d[k] = math.Sqrt(v)
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+	r.b = vectors
+
+	return r.shouldTerminate(maxValue / minValue)
+}
+
+func (r *strategy) shouldTerminate(condition float64) bool {
+	if condition > maxCondition {
+		return true
+	}
+
+	converged := true
+	for j := 0; j < r.n; j++ {
+		if r.sigma*math.Sqrt(r.c[j][j]) >= r.tolX[j] {
+			converged = false
+			break
+		}
+	}
+	if converged {
+		return true
+	}
+
+	// The fitness hasn't changed over the recent generations.
+	r.history = append(r.history, r.results[0].fitness)
+	historySize := 10 + int(math.Ceil(30*float64(r.n)/float64(r.lambda)))
+	if len(r.history) > historySize {
+		r.history = r.history[1:]
+	}
+	if len(r.history) == historySize {
+		low, high := math.Inf(0), math.Inf(-1)
+		for _, f := range r.history {
+			low = math.Min(low, f)
+			high = math.Max(high, f)
+		}
+		for _, res := range r.results {
+			low = math.Min(low, res.fitness)
+			high = math.Max(high, res.fitness)
+		}
+		if high-low < tolFun || math.IsInf(low, 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPopulationSize returns the default population size of the given dimension.
+func defaultPopulationSize(n int) int {
+	if n < 1 {
+		return 4
+	}
+	return 4 + int(3*math.Log(float64(n)))
+}
@@ -0,0 +1,455 @@
+// This package provides a solver based on differential evolution (DE).
+//
+// Numerical parameters are optimized in the unit box, and mapped to their ranges by kurobako.Var.FromUnit
+// (so discrete parameters are rounded to integers). Mutant coordinates outside the box are moved to the middle
+// between the target coordinate and the violated bound.
+//
+// Categorical parameters are handled as choice indices: a trial vector inherits the choice of the base vector of
+// the mutation where the crossover selects the mutant (and the choice of the target vector otherwise),
+// and it switches to a choice selected uniformly at random with the probability Options.SwitchProb.
+//
+// Every vector has the coordinates of all the parameters, including the ones that are inactive in its trial.
+// They are still mutated and crossed over (so a subtree keeps evolving while its categorical parent has another
+// choice), but they are removed from the asked params and don't affect the fitness.
+//
+// The solver is steady-state: each trial vector competes with its target vector as soon as it is evaluated,
+// so any number of trials can be asked concurrently.
+package de
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Strategy is the mutation strategy of DE.
+type Strategy int
+
+const (
+	// RandOneBin indicates DE/rand/1/bin: v = x_r1 + F (x_r2 - x_r3).
+	RandOneBin Strategy = iota
+
+	// CurrentToBest indicates DE/current-to-best/1/bin: v = x_i + F (x_best - x_i) + F (x_r1 - x_r2).
+	CurrentToBest
+
+	// JADE indicates DE/current-to-pbest/1/bin with an archive and adaptive F and CR.
+	//
+	// See "JADE: Adaptive Differential Evolution With Optional External Archive" (Zhang and Sanderson, 2009).
+	JADE
+)
+
+// String returns the string representation of a Strategy value.
+func (r Strategy) String() string {
+	switch r {
+	case RandOneBin:
+		return "rand/1/bin"
+	case CurrentToBest:
+		return "current-to-best/1/bin"
+	case JADE:
+		return "JADE"
+	default:
+		panic("unknown strategy")
+	}
+}
+
+// Options is the options of the DE solver.
+type Options struct {
+	// Strategy is the mutation strategy.
+	Strategy Strategy
+
+	// PopulationSize is the number of the individuals.
+	PopulationSize int
+
+	// F is the scale factor of the difference vectors (the initial mean for JADE).
+	F float64
+
+	// CR is the crossover rate (the initial mean for JADE).
+	CR float64
+
+	// SwitchProb is the probability that a categorical parameter switches to a random choice.
+	SwitchProb float64
+}
+
+// DefaultOptions returns the default options of the DE solver.
+func DefaultOptions() Options {
+	return Options{
+		Strategy:       RandOneBin,
+		PopulationSize: 20,
+		F:              0.5,
+		CR:             0.9,
+		SwitchProb:     0.1,
+	}
+}
+
+const (
+	// jadeLearningRate is the learning rate of the means of F and CR (c in the paper).
+	jadeLearningRate = 0.1
+
+	// jadeGreediness is the fraction of the top individuals from which x_pbest is selected (p in the paper).
+	jadeGreediness = 0.05
+)
+
+// SolverFactory is a SolverFactory for the DE solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if r.options.Strategy != RandOneBin && r.options.Strategy != CurrentToBest && r.options.Strategy != JADE {
+		return nil, fmt.Errorf("unknown strategy: %d", r.options.Strategy)
+	}
+
+	spec := kurobako.NewSolverSpec("Differential Evolution")
+	spec.Attrs["strategy"] = r.options.Strategy.String()
+	spec.Attrs["population_size"] = strconv.Itoa(r.options.PopulationSize)
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.PopulationSize < 4 {
+		return nil, fmt.Errorf("population size must be at least 4: %d", options.PopulationSize)
+	}
+	if options.F <= 0 || options.CR < 0 || options.CR > 1 || options.SwitchProb < 0 || options.SwitchProb > 1 {
+		return nil, fmt.Errorf("invalid F (%v), CR (%v) or switch probability (%v)", options.F, options.CR, options.SwitchProb)
+	}
+	if options.Strategy != RandOneBin && options.Strategy != CurrentToBest && options.Strategy != JADE {
+		return nil, fmt.Errorf("unknown strategy: %d", options.Strategy)
+	}
+
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		meanF:       options.F,
+		meanCR:      options.CR,
+		pending:     map[uint64]*trialVector{},
+	}, nil
+}
+
+type individual struct {
+	// x is the coordinates in the unit box (numerical parameters) or the choice indices (categorical parameters).
+	x       []float64
+	fitness float64
+}
+
+type trialVector struct {
+	x []float64
+
+	// target is the index of the target vector (or -1 for an individual of the initial population).
+	target int
+
+	f  float64
+	cr float64
+}
+
+// Solver is the DE solver.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	population  []individual
+	randomAsked int
+	nextTarget  int
+
+	// pending is the trial vectors that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64]*trialVector
+
+	// The state of JADE.
+	archive   [][]float64
+	meanF     float64
+	meanCR    float64
+	successF  []float64
+	successCR []float64
+	told      int
+}
+
+// Ask returns a random individual (to fill the initial population) or a trial vector of the next target.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	var v *trialVector
+	size := r.options.PopulationSize
+	if (len(r.population) < size && r.randomAsked < size) || len(r.population) < 4 {
+		v = &trialVector{x: r.randomVector(), target: -1}
+		r.randomAsked++
+	} else {
+		v = r.trialVector(r.nextTarget % len(r.population))
+		r.nextTarget = (r.nextTarget + 1) % len(r.population)
+	}
+
+	params, err := r.decode(v.x)
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = v
+	return trial, nil
+}
+
+// Tell replaces the target vector with the trial vector if the trial vector is not worse.
+//
+// An unevalable trial (or a NaN value) has the fitness +Inf, so it replaces its target vector only if the target
+// is also unevalable.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	v, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	fitness := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		fitness = trial.Values[0]
+	}
+
+	target := v.target
+	if target < 0 {
+		if len(r.population) < r.options.PopulationSize {
+			r.population = append(r.population, individual{v.x, fitness})
+			return nil
+		}
+		// The initial population has already been filled (by concurrent asks): competes with the worst one.
+		target = r.worst()
+	}
+
+	if fitness <= r.population[target].fitness {
+		if r.options.Strategy == JADE && v.target >= 0 {
+			r.archive = append(r.archive, r.population[target].x)
+			if len(r.archive) > r.options.PopulationSize {
+				i := r.rng.Intn(len(r.archive))
+				r.archive[i] = r.archive[len(r.archive)-1]
+				r.archive = r.archive[:len(r.archive)-1]
+			}
+			r.successF = append(r.successF, v.f)
+			r.successCR = append(r.successCR, v.cr)
+		}
+		r.population[target] = individual{v.x, fitness}
+	}
+
+	if r.options.Strategy == JADE && v.target >= 0 {
+		r.told++
+		if r.told%r.options.PopulationSize == 0 {
+			r.adapt()
+		}
+	}
+	return nil
+}
+
+// adapt updates the means of F and CR by the successful values (once per PopulationSize trials).
+func (r *Solver) adapt() {
+	if len(r.successF) == 0 {
+		return
+	}
+
+	sumF, sumF2, sumCR := 0.0, 0.0, 0.0
+	for i := range r.successF {
+		sumF += r.successF[i]
+		sumF2 += r.successF[i] * r.successF[i]
+		sumCR += r.successCR[i]
+	}
+	c := jadeLearningRate
+	r.meanCR = (1-c)*r.meanCR + c*sumCR/float64(len(r.successCR))
+	r.meanF = (1-c)*r.meanF + c*sumF2/sumF
+	r.successF = nil
+	r.successCR = nil
+}
+
+func (r *Solver) worst() int {
+	worst := 0
+	for i, x := range r.population {
+		if x.fitness > r.population[worst].fitness {
+			worst = i
+		}
+	}
+	return worst
+}
+
+func (r *Solver) randomVector() []float64 {
+	x := make([]float64, len(r.problem.Params))
+	for i, v := range r.problem.Params {
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			x[i] = float64(r.rng.Intn(len(categorical.Choices)))
+		} else {
+			x[i] = r.rng.Float64()
+		}
+	}
+	return x
+}
+
+// trialVector creates a trial vector of the given target by mutation and binomial crossover.
+func (r *Solver) trialVector(target int) *trialVector {
+	f, cr := r.options.F, r.options.CR
+	if r.options.Strategy == JADE {
+		f, cr = r.sampleF(), r.sampleCR()
+	}
+
+	xi := r.population[target].x
+	picked := r.pickDistinct(target, 3)
+	var base []float64
+	mutant := make([]float64, len(xi))
+	switch r.options.Strategy {
+	case RandOneBin:
+		x1, x2, x3 := r.population[picked[0]].x, r.population[picked[1]].x, r.population[picked[2]].x
+		base = x1
+		for j := range mutant {
+			mutant[j] = x1[j] + f*(x2[j]-x3[j])
+		}
+	case CurrentToBest:
+		best := r.population[r.best()].x
+		x1, x2 := r.population[picked[0]].x, r.population[picked[1]].x
+		base = best
+		for j := range mutant {
+			mutant[j] = xi[j] + f*(best[j]-xi[j]) + f*(x1[j]-x2[j])
+		}
+	case JADE:
+		pbest := r.population[r.pbest()].x
+		x1 := r.population[picked[0]].x
+		x2 := r.population[picked[1]].x
+		if k := r.rng.Intn(len(r.population) + len(r.archive)); k >= len(r.population) {
+			x2 = r.archive[k-len(r.population)]
+		}
+		base = pbest
+		for j := range mutant {
+			mutant[j] = xi[j] + f*(pbest[j]-xi[j]) + f*(x1[j]-x2[j])
+		}
+	}
+
+	x := make([]float64, len(xi))
+	// At least one coordinate is taken from the mutant.
+	forced := -1
+	if len(xi) > 0 {
+		forced = r.rng.Intn(len(xi))
+	}
+	for j, v := range r.problem.Params {
+		crossed := j == forced || r.rng.Float64() < cr
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			x[j] = xi[j]
+			if crossed {
+				x[j] = base[j]
+			}
+			if r.rng.Float64() < r.options.SwitchProb {
+				x[j] = float64(r.rng.Intn(len(categorical.Choices)))
+			}
+			continue
+		}
+
+		x[j] = xi[j]
+		if crossed {
+			x[j] = mutant[j]
+			if x[j] < 0 {
+				x[j] = xi[j] / 2
+			} else if x[j] > 1 {
+				x[j] = (xi[j] + 1) / 2
+			}
+		}
+	}
+	return &trialVector{x: x, target: target, f: f, cr: cr}
+}
+
+// pickDistinct picks n distinct indices of the population other than the given one
+// (indices may be repeated if the population is too small).
+func (r *Solver) pickDistinct(exclude int, n int) []int {
+	var picked []int
+	for _, i := range r.rng.Perm(len(r.population)) {
+		if i != exclude && len(picked) < n {
+			picked = append(picked, i)
+		}
+	}
+	for len(picked) < n {
+		picked = append(picked, r.rng.Intn(len(r.population)))
+	}
+	return picked
+}
+
+func (r *Solver) best() int {
+	best := 0
+	for i, x := range r.population {
+		if x.fitness < r.population[best].fitness {
+			best = i
+		}
+	}
+	return best
+}
+
+// pbest returns one of the top 100p% individuals selected uniformly at random.
+func (r *Solver) pbest() int {
+	indices := r.rng.Perm(len(r.population))
+	top := int(math.Max(math.Ceil(jadeGreediness*float64(len(r.population))), 1))
+
+	// Partial selection of the top individuals.
+	for k := 0; k < top; k++ {
+		for m := k + 1; m < len(indices); m++ {
+			if r.population[indices[m]].fitness < r.population[indices[k]].fitness {
+				indices[k], indices[m] = indices[m], indices[k]
+			}
+		}
+	}
+	return indices[r.rng.Intn(top)]
+}
+
+// sampleF samples F from the Cauchy distribution (regenerated if non-positive, and truncated to 1).
+func (r *Solver) sampleF() float64 {
+	for {
+		f := r.meanF + 0.1*math.Tan(math.Pi*(r.rng.Float64()-0.5))
+		if f > 0 {
+			return math.Min(f, 1)
+		}
+	}
+}
+
+// sampleCR samples CR from the normal distribution (truncated to [0, 1]).
+func (r *Solver) sampleCR() float64 {
+	return math.Min(math.Max(r.meanCR+0.1*r.rng.NormFloat64(), 0), 1)
+}
+
+// decode maps a vector to the parameters (inactive ones are nil).
+func (r *Solver) decode(x []float64) ([]*float64, error) {
+	params := make([]*float64, len(x))
+	for i, v := range r.problem.Params {
+		value := x[i]
+		if v.Range.AsCategoricalRange() == nil {
+			value = v.FromUnit(x[i])
+		}
+		params[i] = &value
+	}
+
+	if _, err := r.constraints.DropInactive(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,138 @@
+package de
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+)
+
+func TestSolverMinimizesSphere(t *testing.T) {
+	for _, strategy := range []Strategy{RandOneBin, CurrentToBest, JADE} {
+		options := DefaultOptions()
+		options.Strategy = strategy
+		if best := solvertest.RunSphere(t, NewSolverFactory(options), 250, 4); best > 1e-2 {
+			t.Fatalf("strategy=%v: the solver didn't converge: %v", strategy, best)
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	evaluations := solvertest.RunConditional(t, NewSolverFactory(DefaultOptions()), 1, 50, 4)
+	if best := solvertest.Best(evaluations); best > 0.1 {
+		t.Fatalf("the solver didn't find a good point: %v", best)
+	}
+}
+
+func TestJADEAdaptation(t *testing.T) {
+	solver := &Solver{options: DefaultOptions(), meanF: 0.5, meanCR: 0.9}
+
+	// No successful trial vectors: the means are kept.
+	solver.adapt()
+	if solver.meanF != 0.5 || solver.meanCR != 0.9 {
+		t.Fatalf("unexpected means: F=%v, CR=%v", solver.meanF, solver.meanCR)
+	}
+
+	// The mean of CR moves towards the arithmetic mean, and the mean of F moves towards the Lehmer mean.
+	solver.successF = []float64{0.6, 0.8}
+	solver.successCR = []float64{0.2, 0.4}
+	solver.adapt()
+	if expected := 0.9*0.9 + 0.1*0.3; math.Abs(solver.meanCR-expected) > 1e-12 {
+		t.Errorf("expected meanCR %v, got %v", expected, solver.meanCR)
+	}
+	if expected := 0.9*0.5 + 0.1*(0.36+0.64)/1.4; math.Abs(solver.meanF-expected) > 1e-12 {
+		t.Errorf("expected meanF %v, got %v", expected, solver.meanF)
+	}
+	if solver.successF != nil || solver.successCR != nil {
+		t.Error("the successful values should be cleared")
+	}
+}
+
+func TestJADEAdaptsMeans(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("sphere").
+		Continuous("x", -5.0, 5.0).
+		Continuous("y", -5.0, 5.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultOptions()
+	options.Strategy = JADE
+	options.CR = 0.5
+	s, err := NewSolverFactory(options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 10*options.PopulationSize; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := *trial.Params[0], *trial.Params[1]
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{x*x + y*y}, CurrentStep: 1}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if solver.meanF == options.F || solver.meanCR == options.CR {
+		t.Fatalf("the means aren't adapted: F=%v, CR=%v", solver.meanF, solver.meanCR)
+	}
+	if solver.meanF <= 0 || solver.meanF > 1 || solver.meanCR < 0 || solver.meanCR > 1 {
+		t.Fatalf("the means are out of range: F=%v, CR=%v", solver.meanF, solver.meanCR)
+	}
+	if len(solver.archive) == 0 || len(solver.archive) > options.PopulationSize {
+		t.Fatalf("unexpected archive size: %d", len(solver.archive))
+	}
+}
+
+func TestBoundHandling(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("line").
+		Continuous("x", 0.0, 1.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any mutant x_r1 + F (x_r2 - x_r3) of the population is far outside the box.
+	options := DefaultOptions()
+	options.F = 10
+	options.CR = 1
+	s, err := NewSolverFactory(options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+	for _, x := range []float64{0.4, 0.2, 0.5, 0.8} {
+		solver.population = append(solver.population, individual{x: []float64{x}})
+	}
+
+	// The coordinate is moved to the middle between the target coordinate and the violated bound.
+	counts := map[float64]int{}
+	for i := 0; i < 100; i++ {
+		v := solver.trialVector(0)
+		counts[v.x[0]]++
+	}
+	if len(counts) != 2 || counts[0.2] == 0 || counts[0.7] == 0 {
+		t.Fatalf("unexpected coordinates: %v", counts)
+	}
+}
+
+func TestSolverInvalidStrategy(t *testing.T) {
+	options := DefaultOptions()
+	options.Strategy = JADE + 1
+	factory := NewSolverFactory(options)
+	if _, err := factory.Specification(); err == nil {
+		t.Fatal("expected an unknown strategy error")
+	}
+	if _, err := factory.CreateSolver(1, *solvertest.SphereSpec(t)); err == nil {
+		t.Fatal("expected an unknown strategy error")
+	}
+}
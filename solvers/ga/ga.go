@@ -0,0 +1,286 @@
+// This package provides a steady-state genetic algorithm whose operators respect the conditional parameters.
+//
+// An individual is a trial params: the values of the active parameters and nil for the inactive ones.
+// After each operator, the child is repaired along the constraint graph: the activity of each parameter is
+// determined by the preceding parameters of the child, the parameters that become active without values
+// (e.g., the dependents of a categorical parameter flipped by mutation) are sampled at random, and the ones
+// that become inactive are removed. So every asked trial has the activity pattern of its own values.
+//
+// Crossover is uniform: each parameter is inherited from one of the parents, or from the other parent if
+// the parameter is inactive in the selected one (so the subtree of a categorical choice tends to be inherited
+// from the parent that has it). Numerical parameters are mutated by Gaussian perturbations in the unit interval
+// (see kurobako.Var.ToUnit), and categorical parameters are mutated to different choices.
+//
+// Parents are selected by tournaments, and a child replaces the worst individual of the population if it isn't worse.
+package ga
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the GA solver.
+type Options struct {
+	// PopulationSize is the number of the individuals.
+	PopulationSize int
+
+	// TournamentSize is the number of the individuals compared in a tournament selection.
+	TournamentSize int
+
+	// CrossoverProb is the probability that a child is generated by crossover (otherwise, it is a copy of a parent).
+	CrossoverProb float64
+
+	// MutationProb is the probability that each active parameter of a child is mutated.
+	//
+	// If this is zero, 1 / (the number of the parameters) is used.
+	MutationProb float64
+
+	// MutationScale is the standard deviation of the perturbation of a numerical parameter in the unit interval.
+	MutationScale float64
+}
+
+// DefaultOptions returns the default options of the GA solver.
+func DefaultOptions() Options {
+	return Options{
+		PopulationSize: 30,
+		TournamentSize: 2,
+		CrossoverProb:  0.9,
+		MutationScale:  0.1,
+	}
+}
+
+// SolverFactory is a SolverFactory for the GA solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Genetic Algorithm")
+	spec.Attrs["population_size"] = strconv.Itoa(r.options.PopulationSize)
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.PopulationSize < 2 || options.TournamentSize < 1 {
+		return nil, fmt.Errorf("invalid population size (%d) or tournament size (%d)", options.PopulationSize, options.TournamentSize)
+	}
+	if options.CrossoverProb < 0 || options.CrossoverProb > 1 {
+		return nil, fmt.Errorf("crossover probability must be in [0, 1]: %v", options.CrossoverProb)
+	}
+	if options.MutationProb < 0 || options.MutationProb > 1 {
+		return nil, fmt.Errorf("mutation probability must be in [0, 1]: %v", options.MutationProb)
+	}
+	if options.MutationScale <= 0 {
+		return nil, fmt.Errorf("mutation scale must be positive: %v", options.MutationScale)
+	}
+	if options.MutationProb == 0 && len(problem.Params) > 0 {
+		options.MutationProb = 1 / float64(len(problem.Params))
+	}
+
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		pending:     map[uint64][]*float64{},
+	}, nil
+}
+
+type individual struct {
+	params  []*float64
+	fitness float64
+}
+
+// Solver is the GA solver.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	population []individual
+
+	// pending is the params of the trials that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64][]*float64
+}
+
+// Ask returns a random individual (until the population is filled) or a child of the population.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	var params []*float64
+	var err error
+	if len(r.population) < 2 || len(r.population)+len(r.pending) < r.options.PopulationSize {
+		params, err = r.repair(make([]*float64, len(r.problem.Params)))
+	} else {
+		params, err = r.offspring()
+	}
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = params
+	return trial, nil
+}
+
+// Tell adds the evaluated individual to the population, or replaces the worst individual with it.
+//
+// An unevalable individual (or a NaN value) has the fitness +Inf: it still fills the population while the population
+// is growing, but it loses every tournament against evaluable ones and is the first to be replaced.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	params, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	fitness := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		fitness = trial.Values[0]
+	}
+
+	if len(r.population) < r.options.PopulationSize {
+		r.population = append(r.population, individual{params, fitness})
+		return nil
+	}
+
+	worst := 0
+	for i, x := range r.population {
+		if x.fitness > r.population[worst].fitness {
+			worst = i
+		}
+	}
+	if fitness <= r.population[worst].fitness {
+		r.population[worst] = individual{params, fitness}
+	}
+	return nil
+}
+
+// offspring generates a child by crossover and mutation.
+func (r *Solver) offspring() ([]*float64, error) {
+	p1 := r.tournament()
+	child := p1.params
+	if r.rng.Float64() < r.options.CrossoverProb {
+		child = r.crossover(p1.params, r.tournament().params)
+	}
+
+	child, err := r.repair(child)
+	if err != nil {
+		return nil, err
+	}
+	r.mutate(child)
+	return r.repair(child)
+}
+
+func (r *Solver) tournament() individual {
+	best := r.population[r.rng.Intn(len(r.population))]
+	for k := 1; k < r.options.TournamentSize; k++ {
+		x := r.population[r.rng.Intn(len(r.population))]
+		if x.fitness < best.fitness {
+			best = x
+		}
+	}
+	return best
+}
+
+// crossover returns the uniform crossover of the parents.
+//
+// A parameter inactive in the selected parent is inherited from the other parent (it is nil if inactive in both).
+func (r *Solver) crossover(p1, p2 []*float64) []*float64 {
+	child := make([]*float64, len(p1))
+	for i := range child {
+		a, b := p1[i], p2[i]
+		if r.rng.Intn(2) == 0 {
+			a, b = b, a
+		}
+		if a != nil {
+			child[i] = a
+		} else {
+			child[i] = b
+		}
+	}
+	return child
+}
+
+// mutate perturbs the active parameters in place (the result needs to be repaired).
+func (r *Solver) mutate(params []*float64) {
+	for i, v := range r.problem.Params {
+		if params[i] == nil || r.rng.Float64() >= r.options.MutationProb {
+			continue
+		}
+
+		var value float64
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			if len(categorical.Choices) < 2 {
+				continue
+			}
+			c := r.rng.Intn(len(categorical.Choices) - 1)
+			if float64(c) >= *params[i] {
+				c++
+			}
+			value = float64(c)
+		} else {
+			value = v.FromUnit(v.ToUnit(*params[i]) + r.options.MutationScale*r.rng.NormFloat64())
+		}
+		params[i] = &value
+	}
+}
+
+// repair returns the copy of the params whose activity is consistent with the constraints.
+//
+// The activity of each parameter is determined by the preceding parameters of the result, so the parameters that
+// become active get random values if they don't have ones, and the parameters that become inactive are removed.
+func (r *Solver) repair(params []*float64) ([]*float64, error) {
+	repaired := make([]*float64, len(params))
+	for i, v := range r.problem.Params {
+		active, err := r.constraints.IsSatisfied(i, repaired[:i])
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			continue
+		}
+
+		var value float64
+		if params[i] != nil {
+			value = *params[i]
+		} else {
+			value = v.FromUnit(r.rng.Float64())
+		}
+		repaired[i] = &value
+	}
+	return repaired, nil
+}
@@ -0,0 +1,116 @@
+package ga
+
+import (
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+)
+
+func nestedSpec(t *testing.T) *kurobako.ProblemSpec {
+	spec, err := kurobako.NewProblemSpecBuilder("nested").
+		Categorical("kind", "a", "b", "c").
+		Continuous("x", -5.0, 5.0).If(kurobako.When("kind").Eq("a")).
+		Categorical("sub", "p", "q").If(kurobako.When("kind").Eq("b")).
+		Continuous("y", 1e-3, 1.0).Log().If(kurobako.When("sub").Eq("q")).
+		Discrete("n", 0, 10).If(kurobako.When("sub").Eq("p")).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestRepair(t *testing.T) {
+	spec := nestedSpec(t)
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := func(x float64) *float64 { return &x }
+
+	// "kind" has been flipped from "a" to "b": "x" becomes inactive, and "sub" and its dependent are sampled.
+	params, err := solver.(*Solver).repair([]*float64{f(1), f(2.5), nil, nil, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.CheckTrialParams(params); err != nil {
+		t.Fatal(err)
+	}
+	if *params[0] != 1 || params[1] != nil || params[2] == nil {
+		t.Errorf("unexpected repaired params: %v", params)
+	}
+
+	// The active values are kept as they are.
+	params, err = solver.(*Solver).repair([]*float64{f(1), nil, f(0), f(0.5), f(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params[3] != nil || *params[4] != 3 {
+		t.Errorf("unexpected repaired params: %v", params)
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	evaluations := solvertest.RunConditional(t, NewSolverFactory(DefaultOptions()), 1, 50, 4)
+	if best := solvertest.Best(evaluations); best > 0.1 {
+		t.Fatalf("the solver didn't find a good point: %v", best)
+	}
+}
+
+func TestSolverNestedActivity(t *testing.T) {
+	spec := nestedSpec(t)
+	for _, crossoverProb := range []float64{0, 1} {
+		options := DefaultOptions()
+		options.CrossoverProb = crossoverProb
+		options.MutationProb = 1
+		solver, err := NewSolverFactory(options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every parameter (including "kind" and "sub") of every child is mutated, so the activity patterns flip
+		// all the time and every trial has to be repaired.
+		patterns := map[string]bool{}
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 20*options.PopulationSize; i++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatalf("crossoverProb=%v: %v", crossoverProb, err)
+			}
+
+			if i >= options.PopulationSize {
+				pattern := ""
+				for _, p := range trial.Params {
+					if p == nil {
+						pattern += "-"
+					} else {
+						pattern += "+"
+					}
+				}
+				patterns[pattern] = true
+			}
+
+			value := 0.0
+			for _, p := range trial.Params {
+				if p != nil {
+					value += *p
+				}
+			}
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: 1}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// kind=a, kind=b (sub=p), kind=b (sub=q) and kind=c.
+		if len(patterns) != 4 {
+			t.Errorf("crossoverProb=%v: unexpected activity patterns of the children: %v", crossoverProb, patterns)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package gp
+
+import (
+	"math"
+)
+
+// acquisition is an acquisition function (to be maximized) built from the fitted models.
+type acquisition struct {
+	kind      Acquisition
+	beta      float64
+	objective *gaussianProcess
+
+	// best is the best (standardized) objective value observed so far.
+	best float64
+
+	// feasibility is the model of the feasibility (+1 for evaluable trials and -1 for unevalable ones).
+	//
+	// This is nil if all the trials have been evaluable.
+	feasibility *gaussianProcess
+}
+
+// evaluate returns the value of the acquisition function at x and its gradient.
+func (r *acquisition) evaluate(x []float64) (float64, []float64) {
+	mean, variance, dMean, dVariance := r.objective.predict(x)
+	sd := math.Sqrt(variance)
+
+	var value float64
+	grad := make([]float64, len(x))
+	switch r.kind {
+	case UpperConfidenceBound:
+		// The objective is minimized, so this is the negated lower confidence bound.
+		value = -mean + r.beta*sd
+		for i := range grad {
+			grad[i] = -dMean[i] + r.beta*dVariance[i]/(2*sd)
+		}
+	default:
+		z := (r.best - mean) / sd
+		cdf, pdf := normalCDF(z), normalPDF(z)
+		value = sd*pdf + (r.best-mean)*cdf
+		for i := range grad {
+			grad[i] = -cdf*dMean[i] + pdf*dVariance[i]/(2*sd)
+		}
+	}
+
+	if r.feasibility == nil {
+		return value, grad
+	}
+
+	// The probability of feasibility.
+	fMean, fVariance, fdMean, fdVariance := r.feasibility.predict(x)
+	fsd := math.Sqrt(fVariance)
+	z := fMean / fsd
+	p := math.Max(normalCDF(z), 1e-300)
+	dp := make([]float64, len(x))
+	for i := range dp {
+		dz := fdMean[i]/fsd - fMean*fdVariance[i]/(2*fVariance*fsd)
+		dp[i] = normalPDF(z) * dz
+	}
+
+	if r.kind == UpperConfidenceBound {
+		// The confidence bound can be negative, so the log probability is added instead.
+		for i := range grad {
+			grad[i] += dp[i] / p
+		}
+		return value + math.Log(p), grad
+	}
+	for i := range grad {
+		grad[i] = grad[i]*p + value*dp[i]
+	}
+	return value * p, grad
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+func normalPDF(z float64) float64 {
+	return math.Exp(-0.5*z*z) / math.Sqrt(2*math.Pi)
+}
@@ -0,0 +1,82 @@
+package gp
+
+import (
+	"github.com/sile/kurobako-go"
+)
+
+// inactiveCoordinate is the coordinate of an inactive numerical parameter.
+//
+// It is outside the unit interval, so the points where a parameter is inactive are distinguished
+// from the points where the parameter is active.
+const inactiveCoordinate = -1
+
+// encoder maps parameters to the points of a unit cube (the inputs of Gaussian processes).
+//
+// A numerical parameter is mapped to a coordinate by kurobako.Var.ToUnit, and a categorical parameter
+// is one-hot encoded (all the coordinates are zero if it is inactive).
+type encoder struct {
+	params      []kurobako.Var
+	constraints *kurobako.CompiledConstraints
+
+	// offsets[i] is the index of the first coordinate of the i-th parameter.
+	offsets []int
+	dim     int
+}
+
+func newEncoder(params []kurobako.Var, constraints *kurobako.CompiledConstraints) *encoder {
+	offsets := make([]int, len(params))
+	dim := 0
+	for i, v := range params {
+		offsets[i] = dim
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			dim += len(categorical.Choices)
+		} else {
+			dim++
+		}
+	}
+	return &encoder{params, constraints, offsets, dim}
+}
+
+func (r *encoder) encode(params []*float64) []float64 {
+	x := make([]float64, r.dim)
+	for i, v := range r.params {
+		offset := r.offsets[i]
+		switch {
+		case v.Range.AsCategoricalRange() != nil:
+			if params[i] != nil {
+				x[offset+int(*params[i])] = 1
+			}
+		case params[i] == nil:
+			x[offset] = inactiveCoordinate
+		default:
+			x[offset] = v.ToUnit(*params[i])
+		}
+	}
+	return x
+}
+
+// decode maps a point of the relaxed unit cube to parameters (inactive ones are nil).
+//
+// A categorical parameter takes the choice that has the largest coordinate.
+func (r *encoder) decode(x []float64) ([]*float64, error) {
+	params := make([]*float64, len(r.params))
+	for i, v := range r.params {
+		offset := r.offsets[i]
+		var value float64
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			for c := range categorical.Choices {
+				if x[offset+c] > x[offset+int(value)] {
+					value = float64(c)
+				}
+			}
+		} else {
+			value = v.FromUnit(x[offset])
+		}
+		params[i] = &value
+	}
+
+	if _, err := r.constraints.DropInactive(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,370 @@
+// This package provides a Bayesian optimization solver based on Gaussian process regression.
+//
+// Parameters are encoded into a unit cube: numerical parameters are mapped by kurobako.Var.ToUnit
+// (so log-scale parameters are modeled in the log space), and categorical parameters are one-hot encoded.
+// The objective is modeled by a Gaussian process with the Matérn 5/2 kernel whose length scales
+// (one for each coordinate), signal variance and noise variance are fitted by maximizing the marginal likelihood.
+//
+// The next trial maximizes the acquisition function (expected improvement or upper confidence bound).
+// The acquisition function is optimized on the continuous relaxation of the cube by L-BFGS started
+// from the best of random candidates, and the result is rounded to valid parameters.
+//
+// Unevalable trials are handled as (unknown) constraints: the feasibility is modeled by another Gaussian process,
+// and the acquisition function is weighted by the probability of feasibility.
+// Concurrent asks are supported by the constant liar strategy (the pending trials are regarded as
+// having the best value observed so far).
+//
+// Everything is implemented in pure Go, so the solver has neither cgo nor BLAS dependencies.
+package gp
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/optimize"
+)
+
+// Acquisition is the kind of the acquisition function.
+type Acquisition int
+
+const (
+	// ExpectedImprovement indicates the expected improvement.
+	ExpectedImprovement Acquisition = iota
+
+	// UpperConfidenceBound indicates the upper confidence bound (of the negated objective).
+	UpperConfidenceBound
+)
+
+// String returns the string representation of an Acquisition value.
+func (r Acquisition) String() string {
+	switch r {
+	case ExpectedImprovement:
+		return "EI"
+	case UpperConfidenceBound:
+		return "UCB"
+	default:
+		panic("unknown acquisition")
+	}
+}
+
+// Options is the options of the GP solver.
+type Options struct {
+	// Acquisition is the kind of the acquisition function.
+	Acquisition Acquisition
+
+	// Beta is the coefficient of the standard deviation of UpperConfidenceBound.
+	Beta float64
+
+	// InitialTrials is the number of the random trials evaluated before the models are used.
+	InitialTrials int
+
+	// Candidates is the number of the random candidates of the acquisition optimization.
+	Candidates int
+
+	// Restarts is the number of the best candidates from which L-BFGS is started.
+	Restarts int
+}
+
+// DefaultOptions returns the default options of the GP solver.
+func DefaultOptions() Options {
+	return Options{
+		Acquisition:   ExpectedImprovement,
+		Beta:          2,
+		InitialTrials: 10,
+		Candidates:    1000,
+		Restarts:      5,
+	}
+}
+
+const maxAcquisitionIterations = 50
+
+// SolverFactory is a SolverFactory for the GP solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if r.options.Acquisition != ExpectedImprovement && r.options.Acquisition != UpperConfidenceBound {
+		return nil, fmt.Errorf("unknown acquisition: %d", r.options.Acquisition)
+	}
+
+	spec := kurobako.NewSolverSpec("GP")
+	spec.Attrs["acquisition"] = r.options.Acquisition.String()
+	if r.options.Acquisition == UpperConfidenceBound {
+		spec.Attrs["beta"] = strconv.FormatFloat(r.options.Beta, 'g', -1, 64)
+	}
+	spec.Attrs["kernel"] = "Matern52"
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.Candidates < 1 || r.options.Restarts < 0 {
+		return nil, fmt.Errorf("invalid number of candidates (%d) or restarts (%d)", r.options.Candidates, r.options.Restarts)
+	}
+	if r.options.Acquisition != ExpectedImprovement && r.options.Acquisition != UpperConfidenceBound {
+		return nil, fmt.Errorf("unknown acquisition: %d", r.options.Acquisition)
+	}
+
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		options: r.options,
+		problem: problem,
+		encoder: newEncoder(problem.Params, constraints),
+		rng:     rand.New(rand.NewSource(seed)),
+		pending: map[uint64][]float64{},
+	}, nil
+}
+
+type observation struct {
+	x        []float64
+	value    float64
+	feasible bool
+}
+
+// Solver is the GP solver.
+type Solver struct {
+	options Options
+	problem kurobako.ProblemSpec
+	encoder *encoder
+	rng     *rand.Rand
+
+	observations []observation
+
+	// pending is the encoded points of the trials that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64][]float64
+
+	// The fitted hyperparameters used as the warm starts of the next fitting.
+	objectiveTheta   []float64
+	feasibilityTheta []float64
+}
+
+// Ask returns a random trial (for the initial trials) or the maximizer of the acquisition function.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	feasible := 0
+	for _, o := range r.observations {
+		if o.feasible {
+			feasible++
+		}
+	}
+
+	var params []*float64
+	var err error
+	if len(r.observations)+len(r.pending) < r.options.InitialTrials || feasible < 2 {
+		params, err = r.sample()
+	} else {
+		params, err = r.suggest()
+	}
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = r.encoder.encode(params)
+	return trial, nil
+}
+
+// Tell records the result of a trial.
+//
+// Unevalable trials (and non-finite values) are regarded as infeasible.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	x, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	r.observe(x, trial)
+	return nil
+}
+
+// Observe records the result of a trial that has been asked by another solver (e.g., a sibling in a portfolio).
+func (r *Solver) Observe(params []*float64, trial kurobako.EvaluatedTrial) error {
+	if len(params) != len(r.problem.Params) {
+		return fmt.Errorf("expected %d params, but got %d", len(r.problem.Params), len(params))
+	}
+	r.observe(r.encoder.encode(params), trial)
+	return nil
+}
+
+func (r *Solver) observe(x []float64, trial kurobako.EvaluatedTrial) {
+	o := observation{x: x}
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) && !math.IsInf(trial.Values[0], 0) {
+		o.value = trial.Values[0]
+		o.feasible = true
+	}
+	r.observations = append(r.observations, o)
+}
+
+// sample returns random parameters.
+func (r *Solver) sample() ([]*float64, error) {
+	x := make([]float64, r.encoder.dim)
+	for i := range x {
+		x[i] = r.rng.Float64()
+	}
+	return r.encoder.decode(x)
+}
+
+// suggest fits the models and returns the parameters that maximize the acquisition function.
+func (r *Solver) suggest() ([]*float64, error) {
+	acq, err := r.fit()
+	if err != nil {
+		return nil, err
+	}
+	f := func(x []float64) (float64, []float64) {
+		value, grad := acq.evaluate(x)
+		for i := range grad {
+			grad[i] = -grad[i]
+		}
+		return -value, grad
+	}
+
+	type candidate struct {
+		x     []float64
+		value float64
+	}
+	candidates := make([]candidate, r.options.Candidates)
+	for k := range candidates {
+		x := make([]float64, r.encoder.dim)
+		for i := range x {
+			x[i] = r.rng.Float64()
+		}
+		value, _ := acq.evaluate(x)
+		candidates[k] = candidate{x, value}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].value > candidates[j].value
+	})
+
+	lower := make([]float64, r.encoder.dim)
+	upper := make([]float64, r.encoder.dim)
+	for i := range upper {
+		upper[i] = 1
+	}
+
+	var best []*float64
+	bestValue := math.Inf(-1)
+	for k, c := range candidates {
+		if k >= r.options.Restarts && best != nil {
+			break
+		}
+
+		x := c.x
+		if k < r.options.Restarts {
+			x, _ = optimize.MinimizeBox(f, c.x, lower, upper, maxAcquisitionIterations)
+		}
+
+		// The acquisition value is evaluated at the rounded point.
+		params, err := r.encoder.decode(x)
+		if err != nil {
+			return nil, err
+		}
+		value, _ := acq.evaluate(r.encoder.encode(params))
+		if best == nil || value > bestValue {
+			best, bestValue = params, value
+		}
+	}
+	return best, nil
+}
+
+// fit fits the objective model (and the feasibility model if there are unevalable trials).
+func (r *Solver) fit() (*acquisition, error) {
+	var xs [][]float64
+	var ys []float64
+	var all [][]float64
+	var feasibilities []float64
+	infeasible := false
+	for _, o := range r.observations {
+		all = append(all, o.x)
+		if o.feasible {
+			xs = append(xs, o.x)
+			ys = append(ys, o.value)
+			feasibilities = append(feasibilities, 1)
+		} else {
+			feasibilities = append(feasibilities, -1)
+			infeasible = true
+		}
+	}
+
+	// Standardization.
+	mean, sd := 0.0, 0.0
+	for _, y := range ys {
+		mean += y / float64(len(ys))
+	}
+	for _, y := range ys {
+		sd += (y - mean) * (y - mean) / float64(len(ys))
+	}
+	sd = math.Sqrt(sd)
+	if sd == 0 {
+		sd = 1
+	}
+	best := math.Inf(0)
+	for i := range ys {
+		ys[i] = (ys[i] - mean) / sd
+		best = math.Min(best, ys[i])
+	}
+
+	// Constant liar.
+	ids := make([]uint64, 0, len(r.pending))
+	for id := range r.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		xs = append(xs, r.pending[id])
+		ys = append(ys, best)
+	}
+
+	objective, err := fitGaussianProcess(xs, ys, r.objectiveTheta)
+	if err != nil {
+		return nil, err
+	}
+	r.objectiveTheta = append([]float64(nil), objective.theta...)
+	acq := &acquisition{
+		kind:      r.options.Acquisition,
+		beta:      r.options.Beta,
+		objective: objective,
+		best:      best,
+	}
+
+	if infeasible {
+		feasibility, err := fitGaussianProcess(all, feasibilities, r.feasibilityTheta)
+		if err != nil {
+			return nil, err
+		}
+		acq.feasibility = feasibility
+		r.feasibilityTheta = append([]float64(nil), feasibility.theta...)
+	}
+	return acq, nil
+}
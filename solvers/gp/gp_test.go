@@ -0,0 +1,243 @@
+package gp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/solvers/portfolio"
+)
+
+var _ portfolio.Observer = (*Solver)(nil)
+
+// checkGradient compares the gradient of f at x with the central finite differences.
+func checkGradient(t *testing.T, name string, f func([]float64) (float64, []float64), x []float64) {
+	_, grad := f(x)
+	for i := range x {
+		h := 1e-6
+		xp := append([]float64(nil), x...)
+		xm := append([]float64(nil), x...)
+		xp[i] += h
+		xm[i] -= h
+		fp, _ := f(xp)
+		fm, _ := f(xm)
+		numerical := (fp - fm) / (2 * h)
+		if math.Abs(numerical-grad[i]) > 1e-4*math.Max(1, math.Abs(numerical)) {
+			t.Fatalf("%s: the gradient of the %d-th coordinate is %v, but the numerical one is %v", name, i, grad[i], numerical)
+		}
+	}
+}
+
+func TestGradients(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	var xs [][]float64
+	var ys []float64
+	for i := 0; i < 15; i++ {
+		x := []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+		xs = append(xs, x)
+		ys = append(ys, math.Sin(5*x[0])+x[1]*x[1]+0.1*rng.NormFloat64())
+	}
+
+	theta := []float64{math.Log(0.3), math.Log(0.7), math.Log(2), math.Log(1.5), math.Log(0.01)}
+	checkGradient(t, "likelihood", func(theta []float64) (float64, []float64) {
+		return negativeLogLikelihood(xs, ys, theta)
+	}, theta)
+
+	model := &gaussianProcess{xs: xs, ys: ys, theta: theta}
+	if err := model.factorize(); err != nil {
+		t.Fatal(err)
+	}
+	x := []float64{0.3, 0.6, 0.2}
+	checkGradient(t, "mean", func(x []float64) (float64, []float64) {
+		mean, _, dMean, _ := model.predict(x)
+		return mean, dMean
+	}, x)
+	checkGradient(t, "variance", func(x []float64) (float64, []float64) {
+		_, variance, _, dVariance := model.predict(x)
+		return variance, dVariance
+	}, x)
+
+	feasibilities := make([]float64, len(ys))
+	for i, y := range ys {
+		feasibilities[i] = math.Copysign(1, y)
+	}
+	feasibility := &gaussianProcess{xs: xs, ys: feasibilities, theta: append([]float64(nil), theta...)}
+	if err := feasibility.factorize(); err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []Acquisition{ExpectedImprovement, UpperConfidenceBound} {
+		acq := &acquisition{kind: kind, beta: 2, objective: model, best: -0.5, feasibility: feasibility}
+		checkGradient(t, kind.String(), acq.evaluate, x)
+	}
+}
+
+func TestSolverMinimizesQuadratic(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("quadratic").
+		Continuous("x", -5.0, 5.0).
+		Continuous("y", 1e-3, 1e3).Log().
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	best := math.Inf(0)
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 30; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := *trial.Params[0]-1, math.Log10(*trial.Params[1])
+		value := x*x + y*y
+		best = math.Min(best, value)
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: 1}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if best > 0.05 {
+		t.Fatalf("GP didn't find a good point: %v", best)
+	}
+}
+
+func TestSolverMixedSpace(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("mixed").
+		Categorical("kind", "a", "b", "c").
+		Continuous("x", 0.0, 1.0).If(kurobako.When("kind").Eq("a")).
+		Discrete("n", 1, 100).Log().
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultOptions()
+	options.Acquisition = UpperConfidenceBound
+	solver, err := NewSolverFactory(options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 10; i++ {
+		// Asks two trials concurrently.
+		var trials []kurobako.NextTrial
+		for j := 0; j < 2; j++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+			trials = append(trials, trial)
+		}
+
+		for _, trial := range trials {
+			var values []float64
+			if *trial.Params[0] != 2 {
+				// The choice "c" is unevalable.
+				values = []float64{*trial.Params[2]}
+				if trial.Params[1] != nil {
+					values[0] += *trial.Params[1]
+				}
+			}
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: values, CurrentStep: 1}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: 1000}); err == nil {
+		t.Fatal("expected an unknown trial error")
+	}
+}
+
+func TestSolverObserve(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("quadratic").
+		Continuous("x", -5.0, 5.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	for i, value := range [][]float64{{1}, nil} {
+		x := float64(i)
+		if err := solver.Observe([]*float64{&x}, kurobako.EvaluatedTrial{TrialID: 100, Values: value, CurrentStep: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(solver.observations) != 2 || !solver.observations[0].feasible || solver.observations[1].feasible {
+		t.Fatalf("unexpected observations: %v", solver.observations)
+	}
+	if len(solver.pending) != 0 {
+		t.Fatal("observed trials shouldn't be pending")
+	}
+
+	if err := solver.Observe([]*float64{}, kurobako.EvaluatedTrial{}); err == nil {
+		t.Fatal("params of a different problem should be rejected")
+	}
+}
+
+func TestFactorizeNaN(t *testing.T) {
+	xs := [][]float64{{0.1}, {math.NaN()}}
+	model := &gaussianProcess{xs: xs, ys: []float64{1, 2}, theta: defaultTheta(1)}
+	if err := model.factorize(); err == nil {
+		t.Fatal("a kernel matrix with NaN should be rejected")
+	}
+
+	spec, err := kurobako.NewProblemSpecBuilder("quadratic").
+		Continuous("x", -5.0, 5.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < DefaultOptions().InitialTrials; i++ {
+		x := float64(i)
+		if i == 0 {
+			x = math.NaN()
+		}
+		if err := solver.(*Solver).Observe([]*float64{&x}, kurobako.EvaluatedTrial{Values: []float64{x}, CurrentStep: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The error is returned instead of retrying forever.
+	var idg kurobako.TrialIDGenerator
+	if _, err := solver.Ask(&idg); err == nil {
+		t.Fatal("expected a factorization error")
+	}
+}
+
+func TestSolverInvalidAcquisition(t *testing.T) {
+	options := DefaultOptions()
+	options.Acquisition = UpperConfidenceBound + 1
+	factory := NewSolverFactory(options)
+	if _, err := factory.Specification(); err == nil {
+		t.Fatal("expected an unknown acquisition error")
+	}
+	if _, err := factory.CreateSolver(1, kurobako.NewProblemSpec("foo")); err == nil {
+		t.Fatal("expected an unknown acquisition error")
+	}
+}
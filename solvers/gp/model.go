@@ -0,0 +1,251 @@
+package gp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sile/kurobako-go/internal/linalg"
+	"github.com/sile/kurobako-go/internal/optimize"
+)
+
+const (
+	minLengthScale = 1e-2
+	maxLengthScale = 1e2
+	minVariance    = 1e-2
+	maxVariance    = 1e2
+	minNoise       = 1e-6
+	maxNoise       = 1.0
+
+	// jitter is added to the diagonal of a kernel matrix for numerical stability.
+	jitter = 1e-8
+
+	maxFittingIterations = 100
+
+	// maxFactorizationTries is the maximum number of the increases of the noise variance in a factorization.
+	maxFactorizationTries = 10
+)
+
+var sqrt5 = math.Sqrt(5)
+
+// gaussianProcess is a Gaussian process regression model with a zero mean and the Matérn 5/2 kernel
+// with automatic relevance determination (i.e., a length scale for each coordinate).
+type gaussianProcess struct {
+	xs [][]float64
+	ys []float64
+
+	// theta is the log-transformed hyperparameters: the length scales, the signal variance and the noise variance.
+	theta []float64
+
+	l     [][]float64
+	alpha []float64
+}
+
+// defaultTheta returns the initial hyperparameters used for fitting.
+func defaultTheta(dim int) []float64 {
+	theta := make([]float64, dim+2)
+	for i := 0; i < dim; i++ {
+		theta[i] = math.Log(0.5)
+	}
+	theta[dim] = 0
+	theta[dim+1] = math.Log(1e-3)
+	return theta
+}
+
+// fitGaussianProcess fits the hyperparameters by maximizing the marginal likelihood.
+//
+// The optimization starts from the default hyperparameters and the given ones (e.g., the previous result),
+// and the better result is used.
+func fitGaussianProcess(xs [][]float64, ys []float64, warmStart []float64) (*gaussianProcess, error) {
+	dim := len(xs[0])
+	lower := make([]float64, dim+2)
+	upper := make([]float64, dim+2)
+	for i := 0; i < dim; i++ {
+		lower[i], upper[i] = math.Log(minLengthScale), math.Log(maxLengthScale)
+	}
+	lower[dim], upper[dim] = math.Log(minVariance), math.Log(maxVariance)
+	lower[dim+1], upper[dim+1] = math.Log(minNoise), math.Log(maxNoise)
+
+	objective := func(theta []float64) (float64, []float64) {
+		return negativeLogLikelihood(xs, ys, theta)
+	}
+
+	starts := [][]float64{defaultTheta(dim)}
+	if len(warmStart) == dim+2 {
+		starts = append(starts, warmStart)
+	}
+
+	var best []float64
+	bestValue := math.Inf(0)
+	for _, start := range starts {
+		theta, value := optimize.MinimizeBox(objective, start, lower, upper, maxFittingIterations)
+		if best == nil || value < bestValue {
+			best, bestValue = theta, value
+		}
+	}
+
+	model := &gaussianProcess{xs: xs, ys: ys, theta: best}
+	if err := model.factorize(); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (r *gaussianProcess) lengthScales() []float64 {
+	dim := len(r.theta) - 2
+	scales := make([]float64, dim)
+	for i := range scales {
+		scales[i] = math.Exp(r.theta[i])
+	}
+	return scales
+}
+
+func (r *gaussianProcess) variance() float64 {
+	return math.Exp(r.theta[len(r.theta)-2])
+}
+
+// factorize computes the Cholesky factor of the kernel matrix.
+//
+// If the matrix isn't numerically positive definite, the noise variance is increased (up to maxFactorizationTries
+// times). This returns an error if the matrix can't be factorized even then (e.g., the inputs contain NaN).
+func (r *gaussianProcess) factorize() error {
+	for i := 0; ; i++ {
+		k := kernelMatrix(r.xs, r.theta)
+		l, err := linalg.Cholesky(k)
+		if err == nil {
+			r.l = l
+			r.alpha = linalg.SolveCholesky(l, r.ys)
+			return nil
+		}
+		if i == maxFactorizationTries {
+			return fmt.Errorf("failed to factorize the kernel matrix (noise variance: %g): %w",
+				math.Exp(r.theta[len(r.theta)-1]), err)
+		}
+		r.theta[len(r.theta)-1] += math.Log(10)
+	}
+}
+
+// predict returns the posterior mean and variance (of the latent function) at x and their gradients.
+func (r *gaussianProcess) predict(x []float64) (float64, float64, []float64, []float64) {
+	scales := r.lengthScales()
+	variance := r.variance()
+
+	k := make([]float64, len(r.xs))
+	dk := linalg.NewMatrix(len(r.xs), len(x))
+	for j, xj := range r.xs {
+		d := scaledDistance(x, xj, scales)
+		e := math.Exp(-sqrt5 * d)
+		k[j] = variance * (1 + sqrt5*d + 5*d*d/3) * e
+		c := -variance * 5 / 3 * (1 + sqrt5*d) * e
+		for i := range x {
+			dk[j][i] = c * (x[i] - xj[i]) / (scales[i] * scales[i])
+		}
+	}
+
+	mean := linalg.Dot(k, r.alpha)
+	w := linalg.SolveCholesky(r.l, k)
+	v := variance - linalg.Dot(k, w)
+
+	dMean := make([]float64, len(x))
+	dVariance := make([]float64, len(x))
+	for j := range r.xs {
+		for i := range x {
+			dMean[i] += dk[j][i] * r.alpha[j]
+			dVariance[i] -= 2 * dk[j][i] * w[j]
+		}
+	}
+	if v < 1e-12 {
+		v = 1e-12
+		for i := range dVariance {
+			dVariance[i] = 0
+		}
+	}
+	return mean, v, dMean, dVariance
+}
+
+func scaledDistance(a []float64, b []float64, scales []float64) float64 {
+	s := 0.0
+	for i := range a {
+		d := (a[i] - b[i]) / scales[i]
+		s += d * d
+	}
+	return math.Sqrt(s)
+}
+
+// kernelMatrix returns the kernel matrix (with the noise variance on the diagonal).
+func kernelMatrix(xs [][]float64, theta []float64) [][]float64 {
+	dim := len(theta) - 2
+	scales := make([]float64, dim)
+	for i := range scales {
+		scales[i] = math.Exp(theta[i])
+	}
+	variance := math.Exp(theta[dim])
+	noise := math.Exp(theta[dim+1])
+
+	n := len(xs)
+	k := linalg.NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			d := scaledDistance(xs[i], xs[j], scales)
+			k[i][j] = variance * (1 + sqrt5*d + 5*d*d/3) * math.Exp(-sqrt5*d)
+			k[j][i] = k[i][j]
+		}
+		k[i][i] = variance + noise + jitter
+	}
+	return k
+}
+
+// negativeLogLikelihood returns the negative log marginal likelihood and its gradient with respect to theta.
+func negativeLogLikelihood(xs [][]float64, ys []float64, theta []float64) (float64, []float64) {
+	n := len(xs)
+	dim := len(theta) - 2
+	grad := make([]float64, len(theta))
+
+	k := kernelMatrix(xs, theta)
+	l, err := linalg.Cholesky(k)
+	if err != nil {
+		return math.Inf(0), grad
+	}
+	alpha := linalg.SolveCholesky(l, ys)
+
+	value := 0.5*linalg.Dot(ys, alpha) + 0.5*float64(n)*math.Log(2*math.Pi)
+	for i := 0; i < n; i++ {
+		value += math.Log(l[i][i])
+	}
+
+	// W = alpha alpha^T - K^(-1)
+	w := make([][]float64, n)
+	for j := 0; j < n; j++ {
+		e := make([]float64, n)
+		e[j] = 1
+		w[j] = linalg.SolveCholesky(l, e)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			w[i][j] = alpha[i]*alpha[j] - w[i][j]
+		}
+	}
+
+	scales := make([]float64, dim)
+	for i := range scales {
+		scales[i] = math.Exp(theta[i])
+	}
+	variance := math.Exp(theta[dim])
+	noise := math.Exp(theta[dim+1])
+
+	// The gradient is -0.5 tr(W dK/dtheta).
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			d := scaledDistance(xs[i], xs[j], scales)
+			e := math.Exp(-sqrt5 * d)
+			c := variance * 5 / 3 * (1 + sqrt5*d) * e
+			for m := 0; m < dim; m++ {
+				delta := (xs[i][m] - xs[j][m]) / scales[m]
+				grad[m] -= w[i][j] * c * delta * delta
+			}
+			grad[dim] -= w[i][j] * variance * (1 + sqrt5*d + 5*d*d/3) * e
+		}
+		grad[dim] -= 0.5 * w[i][i] * variance
+		grad[dim+1] -= 0.5 * w[i][i] * noise
+	}
+	return value, grad
+}
@@ -0,0 +1,238 @@
+// This package provides a grid search solver.
+//
+// The solver enumerates the Cartesian product of the grid points of the parameters.
+// It doesn't use the evaluation results at all, so it is useful as a reference baseline.
+package grid
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the grid search solver.
+type Options struct {
+	// Points is the number of the grid points of each continuous parameter.
+	//
+	// The points are the centers of the cells that equally divide the range (in the log scale for LogUniform).
+	// Discrete parameters that have more values than MaxDiscreteValues are also divided into this number of points.
+	Points int
+
+	// MaxDiscreteValues is the maximum number of the values of a discrete parameter that are enumerated one by one.
+	MaxDiscreteValues int
+
+	// MaxGridSize is the maximum number of the grid points (i.e., trials) in the whole search space.
+	//
+	// CreateSolver returns an error if the grid has more points. Zero means no limit.
+	MaxGridSize int
+
+	// Shuffle indicates whether the grid points are asked in a random order determined by the seed.
+	//
+	// If false, the grid points are asked in the lexicographic order of the parameters.
+	Shuffle bool
+
+	// Cycle indicates whether the grid is asked again after all the grid points have been asked.
+	//
+	// If false, Ask returns kurobako.ErrorSolverExhausted once the grid has been exhausted.
+	// kurobako.SolverRunner can't report the exhaustion to kurobako (it stops with the error),
+	// so this should be false only if the solver is used by another solver that handles the error (e.g., portfolio).
+	Cycle bool
+}
+
+// DefaultOptions returns the default options of the grid search solver.
+func DefaultOptions() Options {
+	return Options{
+		Points:            10,
+		MaxDiscreteValues: 32,
+		MaxGridSize:       1 << 20,
+		Shuffle:           true,
+		Cycle:             true,
+	}
+}
+
+// SolverFactory is a SolverFactory for the grid search solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Grid Search")
+	spec.Attrs["points"] = strconv.Itoa(r.options.Points)
+	spec.Capabilities = kurobako.AllCapabilities
+	return &spec, nil
+}
+
+// CreateSolver enumerates the grid of the given problem and creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.Points <= 0 {
+		return nil, fmt.Errorf("the number of grid points must be positive: %d", r.options.Points)
+	}
+	if r.options.MaxGridSize < 0 {
+		return nil, fmt.Errorf("the maximum grid size must not be negative: %d", r.options.MaxGridSize)
+	}
+
+	points, err := enumerateGrid(problem.Params, r.options)
+	if err != nil {
+		return nil, err
+	}
+
+	solver := &Solver{
+		options: r.options,
+		rng:     rand.New(rand.NewSource(seed)),
+		steps:   problem.Steps,
+		points:  points,
+	}
+	solver.shuffle()
+	return solver, nil
+}
+
+// Solver is the grid search solver.
+type Solver struct {
+	options Options
+	rng     *rand.Rand
+	steps   kurobako.Steps
+	points  [][]*float64
+	next    int
+}
+
+// Len returns the number of the grid points.
+func (r *Solver) Len() int {
+	return len(r.points)
+}
+
+// Ask returns the next grid point.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	if r.next == len(r.points) {
+		if !r.options.Cycle || len(r.points) == 0 {
+			return trial, fmt.Errorf("%w: all %d grid points have been asked", kurobako.ErrorSolverExhausted,
+				len(r.points))
+		}
+		r.next = 0
+		r.shuffle()
+	}
+
+	for _, p := range r.points[r.next] {
+		if p == nil {
+			trial.Params = append(trial.Params, nil)
+		} else {
+			value := *p
+			trial.Params = append(trial.Params, &value)
+		}
+	}
+	r.next++
+
+	trial.TrialID = idg.Generate()
+	trial.NextStep = r.steps.Last()
+	return trial, nil
+}
+
+// Tell does nothing because grid search doesn't use the evaluation results.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	return nil
+}
+
+func (r *Solver) shuffle() {
+	if r.options.Shuffle {
+		r.rng.Shuffle(len(r.points), func(i, j int) {
+			r.points[i], r.points[j] = r.points[j], r.points[i]
+		})
+	}
+}
+
+// enumerateGrid enumerates the grid points that are reachable under the constraints of the parameters.
+//
+// The values of inactive parameters are nil and never branched, so each distinct trial appears only once.
+func enumerateGrid(params []kurobako.Var, options Options) ([][]*float64, error) {
+	constraints, err := kurobako.CompileConstraints(params)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]float64, len(params))
+	for i, v := range params {
+		if values[i], err = gridValues(v, options); err != nil {
+			return nil, err
+		}
+	}
+
+	var points [][]*float64
+	bound := make([]*float64, len(params))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		if i == len(params) {
+			if options.MaxGridSize > 0 && len(points) == options.MaxGridSize {
+				return fmt.Errorf("the grid has more than %d points", options.MaxGridSize)
+			}
+			points = append(points, append([]*float64(nil), bound...))
+			return nil
+		}
+
+		satisfied, err := constraints.IsSatisfied(i, bound[:i])
+		if err != nil {
+			return err
+		}
+		if !satisfied {
+			bound[i] = nil
+			return visit(i + 1)
+		}
+
+		for j := range values[i] {
+			bound[i] = &values[i][j]
+			if err := visit(i + 1); err != nil {
+				return err
+			}
+		}
+		bound[i] = nil
+		return nil
+	}
+
+	if err := visit(0); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// gridValues returns the grid points of the given parameter.
+func gridValues(v kurobako.Var, options Options) ([]float64, error) {
+	if x := v.Range.AsCategoricalRange(); x != nil {
+		values := make([]float64, len(x.Choices))
+		for i := range values {
+			values[i] = float64(i)
+		}
+		return values, nil
+	}
+
+	if !v.Range.IsBounded() {
+		return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+	}
+
+	if x := v.Range.AsDiscreteRange(); x != nil && x.High-x.Low <= int64(options.MaxDiscreteValues) {
+		var values []float64
+		for i := x.Low; i < x.High; i++ {
+			values = append(values, float64(i))
+		}
+		return values, nil
+	}
+
+	var values []float64
+	for i := 0; i < options.Points; i++ {
+		value := v.FromUnit((float64(i) + 0.5) / float64(options.Points))
+
+		// Rounded discrete values may coincide.
+		if len(values) == 0 || values[len(values)-1] != value {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
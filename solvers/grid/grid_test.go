@@ -0,0 +1,135 @@
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+)
+
+func TestGridSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-4, 1.0).Log().If(kurobako.When("optimizer").Eq("adam")).
+		Discrete("layers", 1, 4).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultOptions()
+	options.Points = 4
+	options.Cycle = false
+	factory := NewSolverFactory(options)
+
+	asked := func(seed int64) []string {
+		solver, err := factory.CreateSolver(seed, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// sgd: 3 (layers), adam: 4 (lr) * 3 (layers).
+		if n := solver.(*Solver).Len(); n != 15 {
+			t.Fatalf("unexpected grid size: %d", n)
+		}
+
+		var keys []string
+		seen := map[string]bool{}
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 15; i++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+
+			key := ""
+			for _, p := range trial.Params {
+				if p == nil {
+					key += "nil,"
+				} else {
+					key += fmt.Sprintf("%v,", *p)
+				}
+			}
+			if seen[key] {
+				t.Fatalf("duplicate grid point: %s", key)
+			}
+			keys = append(keys, key)
+			seen[key] = true
+		}
+
+		if _, err := solver.Ask(&idg); !errors.Is(err, kurobako.ErrorSolverExhausted) {
+			t.Fatalf("expected an exhaustion error, got %v", err)
+		}
+		return keys
+	}
+
+	keys := asked(1)
+	again := asked(1)
+	for i := range keys {
+		if keys[i] != again[i] {
+			t.Fatal("the order should be determined by the seed")
+		}
+	}
+
+	// The default options cycle the grid.
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i <= 2*solver.(*Solver).Len(); i++ {
+		if _, err := solver.Ask(&idg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options.Points = 2
+	options.MaxGridSize = 5
+	if _, err := NewSolverFactory(options).CreateSolver(0, *spec); err == nil {
+		t.Fatal("expected a grid size error")
+	}
+
+	// Zero means no limit.
+	options.MaxGridSize = 0
+	if _, err := NewSolverFactory(options).CreateSolver(0, *spec); err != nil {
+		t.Fatal(err)
+	}
+	options.MaxGridSize = -1
+	if _, err := NewSolverFactory(options).CreateSolver(0, *spec); err == nil {
+		t.Fatal("a negative grid size should be rejected")
+	}
+}
+
+func TestGridValues(t *testing.T) {
+	v := kurobako.NewVar("x")
+	v.Range = kurobako.ContinuousRange{Low: 1.0, High: 100.0}.ToRange()
+	v.Distribution = kurobako.LogUniform
+
+	values, err := gridValues(v, Options{Points: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] < 3.16 || values[0] > 3.17 || values[1] < 31.6 || values[1] > 31.7 {
+		t.Fatalf("unexpected grid values: %v", values)
+	}
+
+	v.Range = kurobako.DiscreteRange{Low: 0, High: 100}.ToRange()
+	v.Distribution = kurobako.Uniform
+	values, err = gridValues(v, Options{Points: 4, MaxDiscreteValues: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 || values[0] != 12 || values[3] != 87 {
+		t.Fatalf("unexpected grid values: %v", values)
+	}
+
+	v.Range = kurobako.NewVar("y").Range
+	if _, err := gridValues(v, Options{Points: 4}); err == nil {
+		t.Fatal("expected an unbounded range error")
+	}
+}
@@ -0,0 +1,346 @@
+// This package provides a solver based on the Nelder-Mead simplex method with adaptive parameters.
+//
+// Numerical parameters are optimized in the unit box, and mapped to their ranges by kurobako.Var.FromUnit
+// (so discrete parameters are rounded to integers). Vertices outside the box are clipped to the box.
+// Once the simplex converges, the method restarts from a random point.
+//
+// The simplex spans all the parameters, including the ones that are inactive at a vertex. The coordinates of
+// the inactive parameters are still reflected and contracted with the others, but they are removed from the asked
+// params, so the function value of the vertex doesn't depend on them.
+//
+// See "Implementing the Nelder-Mead simplex algorithm with adaptive parameters" (Gao and Han, 2012) for the parameters.
+package neldermead
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the Nelder-Mead solver.
+type Options struct {
+	// InitialStep is the distance between the initial vertex and the other vertices of an initial simplex in the unit box.
+	InitialStep float64
+
+	// XTol is the tolerance of the size of the simplex.
+	//
+	// The simplex is converged if all the vertices are within this distance (in each coordinate) from the best vertex.
+	XTol float64
+
+	// FTol is the tolerance of the difference between the objective values of the best and the worst vertices.
+	FTol float64
+}
+
+// DefaultOptions returns the default options of the Nelder-Mead solver.
+func DefaultOptions() Options {
+	return Options{
+		InitialStep: 0.1,
+		XTol:        1e-8,
+		FTol:        1e-12,
+	}
+}
+
+// SolverFactory is a SolverFactory for the Nelder-Mead solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Nelder-Mead")
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Conditional
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.InitialStep <= 0 || r.options.InitialStep > 1 {
+		return nil, fmt.Errorf("initial step must be in (0, 1]: %v", r.options.InitialStep)
+	}
+
+	for _, v := range problem.Params {
+		if v.Range.AsCategoricalRange() != nil {
+			return nil, fmt.Errorf("categorical param %q isn't supported", v.Name)
+		}
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(problem.Params)
+	fn := math.Max(float64(n), 2)
+	solver := &Solver{
+		options:     r.options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		reflection:  1,
+		expansion:   1 + 2/fn,
+		contraction: 0.75 - 1/(2*fn),
+		shrinkage:   1 - 1/fn,
+		asked:       map[uint64]int{},
+	}
+	solver.restart(false)
+	return solver, nil
+}
+
+type phase int
+
+const (
+	phaseInitial phase = iota
+	phaseReflection
+	phaseExpansion
+	phaseOutsideContraction
+	phaseInsideContraction
+	phaseShrinkage
+)
+
+type vertex struct {
+	x []float64
+	f float64
+}
+
+// Solver is the Nelder-Mead solver.
+//
+// The points to be evaluated next are handled as a batch (e.g., the vertices of an initial simplex).
+// The points of a batch can be asked at once, but the next batch can't be asked until the current one has been told.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	reflection  float64
+	expansion   float64
+	contraction float64
+	shrinkage   float64
+
+	phase     phase
+	simplex   []vertex
+	centroid  []float64
+	reflected vertex
+
+	batch []vertex
+	next  int
+	told  int
+	asked map[uint64]int
+}
+
+// Ask returns the next point of the current batch.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+	if r.next == len(r.batch) {
+		return trial, fmt.Errorf("concurrent asks exceeding the batch size (%d) aren't supported", len(r.batch))
+	}
+
+	x := r.batch[r.next].x
+	for i, p := range r.problem.Params {
+		value := p.FromUnit(x[i])
+		trial.Params = append(trial.Params, &value)
+	}
+
+	if _, err := r.constraints.DropInactive(trial.Params); err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.NextStep = r.problem.Steps.Last()
+	r.asked[trial.TrialID] = r.next
+	r.next++
+	return trial, nil
+}
+
+// Tell records the result of a point, and proceeds the method once the whole batch has been evaluated.
+//
+// An unevalable vertex (or a NaN value) has the function value +Inf, so it ranks last in the simplex and is the first
+// vertex to be replaced.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	index, ok := r.asked[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.asked, trial.TrialID)
+
+	f := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		f = trial.Values[0]
+	}
+	r.batch[index].f = f
+
+	r.told++
+	if r.told == len(r.batch) {
+		r.proceed()
+	}
+	return nil
+}
+
+// proceed updates the simplex by the results of the current batch and prepares the next batch.
+func (r *Solver) proceed() {
+	batch := r.batch
+	worst := len(r.simplex) - 1
+
+	switch r.phase {
+	case phaseInitial, phaseShrinkage:
+		if r.phase == phaseInitial {
+			r.simplex = batch
+		} else {
+			r.simplex = append(r.simplex[:1], batch...)
+		}
+		r.reflect()
+	case phaseReflection:
+		fr := batch[0].f
+		switch {
+		case fr < r.simplex[0].f:
+			r.reflected = batch[0]
+			r.setBatch(phaseExpansion, r.towards(r.expansion*r.reflection))
+		case fr < r.simplex[worst-1].f:
+			r.accept(batch[0])
+		case fr < r.simplex[worst].f:
+			r.reflected = batch[0]
+			r.setBatch(phaseOutsideContraction, r.towards(r.contraction*r.reflection))
+		default:
+			r.reflected = batch[0]
+			r.setBatch(phaseInsideContraction, r.towards(-r.contraction))
+		}
+	case phaseExpansion:
+		if batch[0].f < r.reflected.f {
+			r.accept(batch[0])
+		} else {
+			r.accept(r.reflected)
+		}
+	case phaseOutsideContraction:
+		if batch[0].f <= r.reflected.f {
+			r.accept(batch[0])
+		} else {
+			r.shrink()
+		}
+	case phaseInsideContraction:
+		if batch[0].f < r.simplex[worst].f {
+			r.accept(batch[0])
+		} else {
+			r.shrink()
+		}
+	}
+}
+
+// accept replaces the worst vertex with the given one.
+func (r *Solver) accept(v vertex) {
+	r.simplex[len(r.simplex)-1] = v
+	r.reflect()
+}
+
+// reflect sorts the simplex and, unless it has converged, prepares the reflection of the worst vertex.
+func (r *Solver) reflect() {
+	sort.SliceStable(r.simplex, func(i, j int) bool {
+		return r.simplex[i].f < r.simplex[j].f
+	})
+	if r.hasConverged() {
+		r.restart(true)
+		return
+	}
+
+	n := len(r.simplex) - 1
+	r.centroid = make([]float64, n)
+	for _, v := range r.simplex[:n] {
+		for j := range r.centroid {
+			r.centroid[j] += v.x[j] / float64(n)
+		}
+	}
+	r.setBatch(phaseReflection, r.towards(r.reflection))
+}
+
+// towards returns the point centroid + coef * (centroid - worst) clipped to the unit box.
+func (r *Solver) towards(coef float64) []vertex {
+	worst := r.simplex[len(r.simplex)-1].x
+	x := make([]float64, len(worst))
+	for j := range x {
+		x[j] = clip(r.centroid[j] + coef*(r.centroid[j]-worst[j]))
+	}
+	return []vertex{{x: x}}
+}
+
+func (r *Solver) shrink() {
+	best := r.simplex[0].x
+	var batch []vertex
+	for _, v := range r.simplex[1:] {
+		x := make([]float64, len(best))
+		for j := range x {
+			x[j] = best[j] + r.shrinkage*(v.x[j]-best[j])
+		}
+		batch = append(batch, vertex{x: x})
+	}
+	r.setBatch(phaseShrinkage, batch)
+}
+
+func (r *Solver) hasConverged() bool {
+	best := r.simplex[0]
+	worst := r.simplex[len(r.simplex)-1]
+	if worst.f-best.f <= r.options.FTol {
+		return true
+	}
+	if math.IsInf(best.f, 1) {
+		// All the vertices are unevalable.
+		return true
+	}
+
+	for _, v := range r.simplex[1:] {
+		for j := range v.x {
+			if math.Abs(v.x[j]-best.x[j]) > r.options.XTol {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// restart prepares an initial simplex around the center of the box (for the first run) or a random point.
+func (r *Solver) restart(random bool) {
+	n := len(r.problem.Params)
+	x0 := make([]float64, n)
+	for j := range x0 {
+		x0[j] = 0.5
+		if random {
+			x0[j] = r.rng.Float64()
+		}
+	}
+
+	batch := []vertex{{x: x0}}
+	for i := 0; i < n; i++ {
+		x := append([]float64(nil), x0...)
+		if x[i]+r.options.InitialStep <= 1 {
+			x[i] += r.options.InitialStep
+		} else {
+			x[i] -= r.options.InitialStep
+		}
+		batch = append(batch, vertex{x: x})
+	}
+	r.setBatch(phaseInitial, batch)
+}
+
+func (r *Solver) setBatch(phase phase, batch []vertex) {
+	r.phase = phase
+	r.batch = batch
+	r.next = 0
+	r.told = 0
+}
+
+func clip(u float64) float64 {
+	return math.Min(math.Max(u, 0), 1)
+}
@@ -0,0 +1,76 @@
+package neldermead
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+)
+
+func TestSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("rosenbrock").
+		Continuous("x", -2.0, 2.0).
+		Continuous("y", -1.0, 3.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := func(params []*float64) float64 {
+		x, y := *params[0], *params[1]
+		return (1-x)*(1-x) + 100*(y-x*x)*(y-x*x)
+	}
+
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	best := math.Inf(0)
+	restarts := 0
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 1000; i++ {
+		if solver.(*Solver).phase == phaseInitial && solver.(*Solver).next == 0 {
+			restarts++
+		}
+
+		// Asks all the points of the current batch at once.
+		var trials []kurobako.NextTrial
+		for solver.(*Solver).next < len(solver.(*Solver).batch) {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trials = append(trials, trial)
+		}
+		if _, err := solver.Ask(&idg); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		for _, trial := range trials {
+			value := f(trial.Params)
+			best = math.Min(best, value)
+			if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if best > 1e-8 {
+		t.Fatalf("Nelder-Mead didn't converge: %v", best)
+	}
+	if restarts < 2 {
+		t.Fatalf("Nelder-Mead didn't restart: %d", restarts)
+	}
+}
+
+func TestCategoricalIsUnsupported(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("categorical").Categorical("c", "a", "b").Objective("v").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,287 @@
+// This package provides a multi-objective solver based on NSGA-II (Non-dominated Sorting Genetic Algorithm II).
+//
+// Each individual has a gene for every parameter, which is a coordinate in the unit interval
+// mapped to the parameter by kurobako.Var.FromUnit. The genes are varied as follows:
+//
+//   - Continuous parameters: simulated binary crossover (SBX) and polynomial mutation.
+//   - Discrete parameters: SBX and polynomial mutation followed by rounding to a value.
+//     A mutation always changes the value (to an adjacent one if the perturbation is too small).
+//   - Categorical parameters: uniform crossover, and mutation to another choice selected uniformly at random.
+//
+// The genes of inactive conditional parameters carry no information. A gene that is active in only one parent
+// is inherited from that parent, and a gene that is inactive in both parents is sampled uniformly at random
+// (so a parameter that becomes active in a child never inherits a stale value).
+//
+// See "A Fast and Elitist Multiobjective Genetic Algorithm: NSGA-II" (Deb et al., 2002) for the details.
+package nsga2
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the NSGA-II solver.
+type Options struct {
+	// PopulationSize is the number of the individuals that survive each generation.
+	PopulationSize int
+
+	// CrossoverProb is the probability that an offspring is created by crossover (rather than by copying a parent).
+	CrossoverProb float64
+
+	// CrossoverEta is the distribution index of SBX.
+	CrossoverEta float64
+
+	// MutationProb is the probability that each gene is mutated.
+	//
+	// If this is zero, 1/n is used (where n is the number of the parameters).
+	MutationProb float64
+
+	// MutationEta is the distribution index of the polynomial mutation.
+	MutationEta float64
+
+	// SteadyState indicates whether the asynchronous steady-state mode is used.
+	//
+	// In this mode, each evaluated offspring is inserted into the population immediately and the worst individual
+	// is removed. Otherwise, the population is updated once PopulationSize offspring have been evaluated.
+	// Both modes support concurrent asks, but the steady-state mode doesn't wait for slow trials.
+	SteadyState bool
+}
+
+// DefaultOptions returns the default options of the NSGA-II solver.
+func DefaultOptions() Options {
+	return Options{
+		PopulationSize: 50,
+		CrossoverProb:  0.9,
+		CrossoverEta:   20,
+		MutationEta:    20,
+	}
+}
+
+// SolverFactory is a SolverFactory for the NSGA-II solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("NSGA-II")
+	spec.Attrs["population_size"] = strconv.Itoa(r.options.PopulationSize)
+	if r.options.SteadyState {
+		spec.Attrs["mode"] = "steady-state"
+	} else {
+		spec.Attrs["mode"] = "generational"
+	}
+	spec.Capabilities = kurobako.AllCapabilities
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.PopulationSize < 2 {
+		return nil, fmt.Errorf("population size must be at least 2: %d", options.PopulationSize)
+	}
+	if options.CrossoverProb < 0 || options.CrossoverProb > 1 || options.MutationProb < 0 || options.MutationProb > 1 {
+		return nil, fmt.Errorf("invalid crossover (%v) or mutation (%v) probability", options.CrossoverProb, options.MutationProb)
+	}
+	if options.MutationProb == 0 && len(problem.Params) > 0 {
+		options.MutationProb = 1 / float64(len(problem.Params))
+	}
+	if len(problem.Values) == 0 {
+		return nil, fmt.Errorf("the problem has no objectives")
+	}
+
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		pending:     map[uint64]*individual{},
+	}, nil
+}
+
+type individual struct {
+	genes  []float64
+	active []bool
+	values []float64
+
+	rank     int
+	crowding float64
+}
+
+// Solver is the NSGA-II solver.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	// population is the current parents (sorted by the crowded-comparison operator).
+	population []*individual
+
+	// offspring is the evaluated offspring of the current generation (only used in the generational mode).
+	offspring []*individual
+
+	// randomAsked is the number of the individuals of the initial population that have been asked.
+	randomAsked int
+
+	// pending is the individuals that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64]*individual
+}
+
+// Ask returns a random individual (to fill the initial population) or an offspring of the current population.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	var genes []float64
+	size := r.options.PopulationSize
+	if (len(r.population) < size && r.randomAsked < size) || len(r.population) < 2 {
+		genes = r.randomGenes()
+		r.randomAsked++
+	} else {
+		genes = r.offspringGenes()
+	}
+
+	params, mask, err := r.decode(genes)
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = &individual{genes: genes, active: mask}
+	return trial, nil
+}
+
+// Tell adds an evaluated individual to the population (or the offspring of the current generation).
+//
+// Unevalable trials (and NaN values) are regarded as infinitely bad in all the objectives.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	x, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	if len(trial.Values) != 0 && len(trial.Values) != len(r.problem.Values) {
+		return fmt.Errorf("expected %d values, but got %d", len(r.problem.Values), len(trial.Values))
+	}
+	x.values = make([]float64, len(r.problem.Values))
+	for i := range x.values {
+		x.values[i] = math.Inf(0)
+		if len(trial.Values) > 0 && !math.IsNaN(trial.Values[i]) {
+			x.values[i] = trial.Values[i]
+		}
+	}
+
+	size := r.options.PopulationSize
+	switch {
+	case r.options.SteadyState || len(r.population) < size:
+		r.population = selectSurvivors(append(r.population, x), size)
+	default:
+		r.offspring = append(r.offspring, x)
+		if len(r.offspring) >= size {
+			r.population = selectSurvivors(append(r.population, r.offspring...), size)
+			r.offspring = nil
+		}
+	}
+	return nil
+}
+
+func (r *Solver) randomGenes() []float64 {
+	genes := make([]float64, len(r.problem.Params))
+	for i, v := range r.problem.Params {
+		genes[i] = snap(v, r.rng.Float64())
+	}
+	return genes
+}
+
+// offspringGenes creates an offspring of two parents selected by binary tournaments.
+func (r *Solver) offspringGenes() []float64 {
+	p1, p2 := r.tournament(), r.tournament()
+	genes := make([]float64, len(r.problem.Params))
+	crossover := r.rng.Float64() < r.options.CrossoverProb
+	for i, v := range r.problem.Params {
+		active1, active2 := p1.active[i], p2.active[i] && crossover
+		switch {
+		case active1 && active2:
+			if v.Range.AsCategoricalRange() != nil || r.rng.Float64() < 0.5 {
+				// Uniform crossover (numerical genes are crossed over by SBX with the probability 0.5).
+				genes[i] = p1.genes[i]
+				if r.rng.Float64() < 0.5 {
+					genes[i] = p2.genes[i]
+				}
+			} else {
+				c, _ := sbx(r.rng, p1.genes[i], p2.genes[i], r.options.CrossoverEta)
+				genes[i] = snap(v, c)
+			}
+		case active1:
+			genes[i] = p1.genes[i]
+		case active2:
+			genes[i] = p2.genes[i]
+		default:
+			genes[i] = snap(v, r.rng.Float64())
+		}
+	}
+
+	for i, v := range r.problem.Params {
+		if r.rng.Float64() >= r.options.MutationProb {
+			continue
+		}
+		switch {
+		case v.Range.AsCategoricalRange() != nil:
+			genes[i] = mutateCategorical(r.rng, v, genes[i])
+		case v.Range.AsDiscreteRange() != nil:
+			genes[i] = mutateDiscrete(r.rng, v, genes[i], r.options.MutationEta)
+		default:
+			genes[i] = polynomialMutation(r.rng, genes[i], r.options.MutationEta)
+		}
+	}
+	return genes
+}
+
+// tournament selects the better of two individuals sampled from the population.
+func (r *Solver) tournament() *individual {
+	a := r.population[r.rng.Intn(len(r.population))]
+	b := r.population[r.rng.Intn(len(r.population))]
+	if crowdedLess(b, a) {
+		return b
+	}
+	return a
+}
+
+// decode maps genes to the parameters (inactive ones are nil).
+func (r *Solver) decode(genes []float64) ([]*float64, []bool, error) {
+	params := make([]*float64, len(genes))
+	for i, v := range r.problem.Params {
+		value := v.FromUnit(genes[i])
+		params[i] = &value
+	}
+
+	mask, err := r.constraints.DropInactive(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, mask, nil
+}
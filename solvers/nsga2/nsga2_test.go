@@ -0,0 +1,139 @@
+package nsga2
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+)
+
+func TestNondominatedSort(t *testing.T) {
+	var individuals []*individual
+	for _, values := range [][]float64{{1, 4}, {2, 2}, {4, 1}, {2, 3}, {3, 2.5}, {5, 5}} {
+		individuals = append(individuals, &individual{values: values})
+	}
+
+	fronts := nondominatedSort(individuals)
+	expected := []int{0, 0, 0, 1, 1, 2}
+	for i, x := range individuals {
+		if x.rank != expected[i] {
+			t.Errorf("individual %d: expected rank %d, got %d", i, expected[i], x.rank)
+		}
+	}
+	if len(fronts) != 3 {
+		t.Fatalf("expected 3 fronts, got %d", len(fronts))
+	}
+
+	assignCrowdingDistances(fronts[0])
+	if !math.IsInf(individuals[0].crowding, 1) || !math.IsInf(individuals[2].crowding, 1) {
+		t.Error("the boundary individuals should have infinite crowding distances")
+	}
+	if individuals[1].crowding != 2 {
+		t.Errorf("expected the crowding distance 2, got %v", individuals[1].crowding)
+	}
+
+	survivors := selectSurvivors(individuals, 4)
+	if len(survivors) != 4 || survivors[3].rank != 1 {
+		t.Fatalf("unexpected survivors: %v", survivors)
+	}
+}
+
+func TestMutateDiscrete(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("discrete").
+		Discrete("x", 0, 5).
+		Discrete("y", 1, 1000).Log().
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(0))
+	for _, v := range spec.Params {
+		for i := 0; i < 1000; i++ {
+			x := v.ToUnit(v.FromUnit(rng.Float64()))
+			y := mutateDiscrete(rng, v, x, 20)
+			if v.FromUnit(x) == v.FromUnit(y) {
+				t.Fatalf("%s: the value %v isn't changed", v.Name, v.FromUnit(x))
+			}
+			if y != v.ToUnit(v.FromUnit(y)) {
+				t.Fatalf("%s: %v isn't the center of a cell", v.Name, y)
+			}
+		}
+	}
+}
+
+func TestSolverZDT1(t *testing.T) {
+	builder := kurobako.NewProblemSpecBuilder("zdt1")
+	for _, name := range []string{"x1", "x2", "x3", "x4", "x5"} {
+		builder.Continuous(name, 0.0, 1.0)
+	}
+	spec, err := builder.
+		Discrete("d", 0, 10).
+		Categorical("c", "good", "bad").
+		Objective("f1").
+		Objective("f2").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluate := func(params []*float64) []float64 {
+		g := 0.0
+		for _, p := range params[1:5] {
+			g += *p
+		}
+		g = 1 + 9*g/4 + math.Abs(*params[5]-3) + *params[6]
+		f1 := *params[0]
+		return []float64{f1, g * (1 - math.Sqrt(f1/g))}
+	}
+
+	for _, steadyState := range []bool{false, true} {
+		options := DefaultOptions()
+		options.SteadyState = steadyState
+		solver, err := NewSolverFactory(options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 1000; i++ {
+			// Asks four trials concurrently.
+			var trials []kurobako.NextTrial
+			for j := 0; j < 4; j++ {
+				trial, err := solver.Ask(&idg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				trials = append(trials, trial)
+			}
+			for _, trial := range trials {
+				evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: evaluate(trial.Params), CurrentStep: 1}
+				if err := solver.Tell(evaluated); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		// On the Pareto front, f2 = 1 - sqrt(f1).
+		population := solver.(*Solver).population
+		var gaps []float64
+		for _, x := range population {
+			gaps = append(gaps, x.values[1]-(1-math.Sqrt(x.values[0])))
+		}
+		sort.Float64s(gaps)
+		if median := gaps[len(gaps)/2]; median > 0.1 {
+			t.Errorf("steadyState=%v: the population is far from the Pareto front: %v", steadyState, median)
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	options := DefaultOptions()
+	options.PopulationSize = 10
+	options.SteadyState = true
+	solvertest.RunConditional(t, NewSolverFactory(options), 2, 500, 1)
+}
@@ -0,0 +1,110 @@
+package nsga2
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/sile/kurobako-go"
+)
+
+// sbx is the simulated binary crossover bounded to [0, 1].
+//
+// See "Simulated Binary Crossover for Continuous Search Space" (Deb and Agrawal, 1995).
+func sbx(rng *rand.Rand, x1, x2, eta float64) (float64, float64) {
+	if math.Abs(x1-x2) < 1e-14 {
+		return x1, x2
+	}
+
+	y1, y2 := math.Min(x1, x2), math.Max(x1, x2)
+	u := rng.Float64()
+
+	beta := 1 + 2*y1/(y2-y1)
+	c1 := 0.5 * ((y1 + y2) - sbxBeta(u, beta, eta)*(y2-y1))
+
+	beta = 1 + 2*(1-y2)/(y2-y1)
+	c2 := 0.5 * ((y1 + y2) + sbxBeta(u, beta, eta)*(y2-y1))
+
+	c1, c2 = clip(c1), clip(c2)
+	if rng.Float64() < 0.5 {
+		c1, c2 = c2, c1
+	}
+	return c1, c2
+}
+
+func sbxBeta(u, beta, eta float64) float64 {
+	alpha := 2 - math.Pow(beta, -(eta+1))
+	if u <= 1/alpha {
+		return math.Pow(u*alpha, 1/(eta+1))
+	}
+	return math.Pow(1/(2-u*alpha), 1/(eta+1))
+}
+
+// polynomialMutation is the polynomial mutation bounded to [0, 1].
+//
+// See "A Combined Genetic Adaptive Search (GeneAS) for Engineering Design" (Deb and Goyal, 1996).
+func polynomialMutation(rng *rand.Rand, x, eta float64) float64 {
+	u := rng.Float64()
+	power := 1 / (eta + 1)
+
+	var delta float64
+	if u < 0.5 {
+		xy := 1 - x
+		value := 2*u + (1-2*u)*math.Pow(xy, eta+1)
+		delta = math.Pow(value, power) - 1
+	} else {
+		xy := x
+		value := 2*(1-u) + 2*(u-0.5)*math.Pow(xy, eta+1)
+		delta = 1 - math.Pow(value, power)
+	}
+	return clip(x + delta)
+}
+
+// mutateDiscrete mutates the unit coordinate of a discrete parameter by the polynomial mutation.
+//
+// If the mutated coordinate falls in the same cell (i.e., the value doesn't change),
+// the value is moved to the adjacent one in the direction of the mutation.
+// The result is always the center of a cell.
+func mutateDiscrete(rng *rand.Rand, v kurobako.Var, x, eta float64) float64 {
+	old := v.FromUnit(x)
+	y := polynomialMutation(rng, x, eta)
+	value := v.FromUnit(y)
+	if value == old {
+		low, high := v.Range.Low(), v.Range.High()-1
+		if y < x {
+			value--
+		} else {
+			value++
+		}
+		if value < low || value > high {
+			value = 2*old - value
+		}
+		value = math.Max(math.Min(value, high), low)
+	}
+	return v.ToUnit(value)
+}
+
+// mutateCategorical returns the unit coordinate of a choice other than the current one (selected uniformly at random).
+func mutateCategorical(rng *rand.Rand, v kurobako.Var, x float64) float64 {
+	n := len(v.Range.AsCategoricalRange().Choices)
+	if n < 2 {
+		return x
+	}
+	old := int(v.FromUnit(x))
+	choice := rng.Intn(n - 1)
+	if choice >= old {
+		choice++
+	}
+	return v.ToUnit(float64(choice))
+}
+
+// snap moves the unit coordinate of a discrete or categorical parameter to the center of its cell.
+func snap(v kurobako.Var, x float64) float64 {
+	if v.Range.AsContinuousRange() != nil {
+		return x
+	}
+	return v.ToUnit(v.FromUnit(x))
+}
+
+func clip(u float64) float64 {
+	return math.Min(math.Max(u, 0), 1)
+}
@@ -0,0 +1,122 @@
+package nsga2
+
+import (
+	"math"
+	"sort"
+)
+
+// dominates returns whether the objective values a Pareto-dominate b (all the objectives are minimized).
+func dominates(a, b []float64) bool {
+	better := false
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+		if a[i] < b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// nondominatedSort sorts the individuals into Pareto fronts, and sets their ranks.
+func nondominatedSort(individuals []*individual) [][]*individual {
+	n := len(individuals)
+	dominated := make([][]int, n)
+	counts := make([]int, n)
+	var front []int
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			a, b := individuals[i].values, individuals[j].values
+			switch {
+			case dominates(a, b):
+				dominated[i] = append(dominated[i], j)
+				counts[j]++
+			case dominates(b, a):
+				dominated[j] = append(dominated[j], i)
+				counts[i]++
+			}
+		}
+		if counts[i] == 0 {
+			front = append(front, i)
+		}
+	}
+
+	var fronts [][]*individual
+	for rank := 0; len(front) > 0; rank++ {
+		var members []*individual
+		var next []int
+		for _, i := range front {
+			individuals[i].rank = rank
+			members = append(members, individuals[i])
+			for _, j := range dominated[i] {
+				counts[j]--
+				if counts[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		fronts = append(fronts, members)
+		front = next
+	}
+	return fronts
+}
+
+// assignCrowdingDistances sets the crowding distances of the individuals in a front.
+func assignCrowdingDistances(front []*individual) {
+	for _, x := range front {
+		x.crowding = 0
+	}
+	if len(front) == 0 {
+		return
+	}
+
+	sorted := append([]*individual(nil), front...)
+	for k := range front[0].values {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].values[k] < sorted[j].values[k]
+		})
+
+		first, last := sorted[0], sorted[len(sorted)-1]
+		first.crowding = math.Inf(0)
+		last.crowding = math.Inf(0)
+
+		width := last.values[k] - first.values[k]
+		if width == 0 || math.IsInf(width, 0) || math.IsNaN(width) {
+			continue
+		}
+		for i := 1; i < len(sorted)-1; i++ {
+			d := (sorted[i+1].values[k] - sorted[i-1].values[k]) / width
+			if !math.IsNaN(d) {
+				sorted[i].crowding += d
+			}
+		}
+	}
+}
+
+// selectSurvivors selects n individuals by the ranks and the crowding distances (the better ones come first).
+func selectSurvivors(individuals []*individual, n int) []*individual {
+	var survivors []*individual
+	for _, front := range nondominatedSort(individuals) {
+		assignCrowdingDistances(front)
+		if len(survivors)+len(front) <= n {
+			survivors = append(survivors, front...)
+			continue
+		}
+
+		sort.SliceStable(front, func(i, j int) bool {
+			return front[i].crowding > front[j].crowding
+		})
+		survivors = append(survivors, front[:n-len(survivors)]...)
+		break
+	}
+	return survivors
+}
+
+// crowdedLess is the crowded-comparison operator: a lower rank is better, and then a larger crowding distance is better.
+func crowdedLess(a, b *individual) bool {
+	if a.rank != b.rank {
+		return a.rank < b.rank
+	}
+	return a.crowding > b.crowding
+}
@@ -0,0 +1,319 @@
+// This package provides a solver based on generalized pattern search (a.k.a. compass or coordinate search).
+//
+// Continuous parameters are optimized in the unit box, and mapped to their ranges by kurobako.Var.FromUnit.
+// Discrete and categorical parameters are optimized directly on their values (and choice indices).
+// At each iteration, the solver polls the points that move the current center by the step size
+// along each coordinate (in both directions). If a poll point improves the center, it becomes the new center
+// and the step size is expanded. Otherwise, the step size is contracted.
+//
+// Discrete parameters are moved by the step size multiplied by the number of their values (rounded, at least one),
+// and categorical parameters are polled by all the other choices, so the search also works on discrete spaces.
+// Once the step size reaches the minimum (and no poll point improves the center), the search restarts from a random point.
+//
+// Conditional parameters that are inactive at the center aren't polled.
+package patternsearch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the pattern search solver.
+type Options struct {
+	// InitialStep is the initial step size in the unit box.
+	InitialStep float64
+
+	// MinStep is the minimum step size of continuous parameters.
+	MinStep float64
+
+	// Expansion is the factor by which the step size is multiplied after a successful poll.
+	Expansion float64
+
+	// Contraction is the factor by which the step size is multiplied after an unsuccessful poll.
+	Contraction float64
+}
+
+// DefaultOptions returns the default options of the pattern search solver.
+func DefaultOptions() Options {
+	return Options{
+		InitialStep: 0.25,
+		MinStep:     1e-8,
+		Expansion:   2.0,
+		Contraction: 0.5,
+	}
+}
+
+// SolverFactory is a SolverFactory for the pattern search solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Pattern Search")
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.InitialStep <= 0 || options.MinStep <= 0 {
+		return nil, fmt.Errorf("step sizes must be positive: initial=%v, min=%v", options.InitialStep, options.MinStep)
+	}
+	if options.Expansion < 1 || options.Contraction <= 0 || options.Contraction >= 1 {
+		return nil, fmt.Errorf("invalid expansion (%v) or contraction (%v) factor", options.Expansion, options.Contraction)
+	}
+
+	cells := make([]int, len(problem.Params))
+	for i, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+		if v.Range.AsContinuousRange() == nil {
+			cells[i] = int(v.Range.High() - v.Range.Low())
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	solver := &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		cells:       cells,
+		asked:       map[uint64]int{},
+	}
+	solver.restart(false)
+	return solver, nil
+}
+
+type point struct {
+	x []float64
+	f float64
+}
+
+// Solver is the pattern search solver.
+//
+// The poll points of an iteration can be asked at once, but the next iteration can't be asked until
+// all of them have been told.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+
+	// cells is the number of the values of each discrete or categorical parameter (zero for continuous ones).
+	cells []int
+
+	center    point
+	hasCenter bool
+	step      float64
+
+	batch []point
+	next  int
+	told  int
+	asked map[uint64]int
+}
+
+// Ask returns the next poll point.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+	if r.next == len(r.batch) {
+		return trial, fmt.Errorf("concurrent asks exceeding the poll size (%d) aren't supported", len(r.batch))
+	}
+
+	params, _, err := r.decode(r.batch[r.next].x)
+	if err != nil {
+		return trial, err
+	}
+	trial.Params = params
+
+	trial.TrialID = idg.Generate()
+	trial.NextStep = r.problem.Steps.Last()
+	r.asked[trial.TrialID] = r.next
+	r.next++
+	return trial, nil
+}
+
+// Tell records the result of a poll point, and moves to the next iteration once all the poll points have been evaluated.
+//
+// An unevalable poll point (or a NaN value) never improves the center, and the search restarts from a random point
+// if the center itself is unevalable.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	index, ok := r.asked[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.asked, trial.TrialID)
+
+	f := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		f = trial.Values[0]
+	}
+	r.batch[index].f = f
+
+	r.told++
+	if r.told == len(r.batch) {
+		return r.proceed()
+	}
+	return nil
+}
+
+func (r *Solver) proceed() error {
+	if !r.hasCenter {
+		r.center = r.batch[0]
+		r.hasCenter = true
+		return r.poll()
+	}
+
+	best := r.center
+	for _, p := range r.batch {
+		if p.f < best.f {
+			best = p
+		}
+	}
+
+	if best.f < r.center.f {
+		r.center = best
+		r.step = math.Min(r.step*r.options.Expansion, 1)
+		return r.poll()
+	}
+
+	if r.isMinimalStep() || math.IsInf(r.center.f, 1) {
+		r.restart(true)
+		return nil
+	}
+	r.step *= r.options.Contraction
+	return r.poll()
+}
+
+// isMinimalStep returns whether the step size can't be contracted anymore.
+func (r *Solver) isMinimalStep() bool {
+	for _, n := range r.cells {
+		if n == 0 {
+			return r.step <= r.options.MinStep
+		}
+	}
+
+	// All the parameters are discrete or categorical: they move at least a cell.
+	for _, n := range r.cells {
+		if r.step*float64(n) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// poll prepares the poll points around the center.
+func (r *Solver) poll() error {
+	_, mask, err := r.decode(r.center.x)
+	if err != nil {
+		return err
+	}
+
+	var batch []point
+	for i, v := range r.problem.Params {
+		if !mask[i] {
+			continue
+		}
+
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			for c := range categorical.Choices {
+				if float64(c) != r.center.x[i] {
+					batch = append(batch, r.moved(i, float64(c)))
+				}
+			}
+			continue
+		}
+
+		delta := r.step
+		low, high := 0.0, 1.0
+		if n := r.cells[i]; n > 0 {
+			// Moves by a multiple of the number of the values (at least one value).
+			delta = math.Max(math.Round(r.step*float64(n)), 1)
+			low, high = v.Range.Low(), v.Range.High()-1
+		}
+		for _, sign := range []float64{1, -1} {
+			x := r.center.x[i] + sign*delta
+			if x >= low && x <= high {
+				batch = append(batch, r.moved(i, x))
+			}
+		}
+	}
+
+	if len(batch) == 0 {
+		// There is nothing to poll (e.g., the search space has a single point).
+		r.restart(true)
+		return nil
+	}
+	r.setBatch(batch)
+	return nil
+}
+
+func (r *Solver) moved(i int, value float64) point {
+	x := append([]float64(nil), r.center.x...)
+	x[i] = value
+	return point{x: x}
+}
+
+// restart evaluates the center of the box (for the first run) or a random point as the new center.
+func (r *Solver) restart(random bool) {
+	x := make([]float64, len(r.problem.Params))
+	for i, v := range r.problem.Params {
+		x[i] = 0.5
+		if random {
+			x[i] = r.rng.Float64()
+		}
+		if r.cells[i] > 0 {
+			x[i] = v.FromUnit(x[i])
+		}
+	}
+
+	r.hasCenter = false
+	r.step = r.options.InitialStep
+	r.setBatch([]point{{x: x}})
+}
+
+func (r *Solver) setBatch(batch []point) {
+	r.batch = batch
+	r.next = 0
+	r.told = 0
+}
+
+// decode maps a point to the parameters (inactive ones are nil).
+//
+// The coordinates of continuous parameters are in the unit box, and the others are the values themselves.
+func (r *Solver) decode(x []float64) ([]*float64, []bool, error) {
+	params := make([]*float64, len(x))
+	for i, v := range r.problem.Params {
+		value := x[i]
+		if r.cells[i] == 0 {
+			value = v.FromUnit(x[i])
+		}
+		params[i] = &value
+	}
+
+	mask, err := r.constraints.DropInactive(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, mask, nil
+}
@@ -0,0 +1,91 @@
+package patternsearch
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+)
+
+func TestSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("mixed").
+		Continuous("x", -5.0, 5.0).
+		Discrete("n", 1, 1000).Log().
+		Categorical("c", "a", "b", "c").
+		Continuous("y", 0.0, 1.0).If(kurobako.When("c").Eq("c")).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := func(params []*float64) float64 {
+		x, n := *params[0]-1, math.Log(*params[1]/30)
+		v := x*x + n*n
+		if *params[2] != 2 {
+			return v + 1
+		}
+		return v + (*params[3]-0.25)*(*params[3]-0.25)
+	}
+
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	best := math.Inf(0)
+	restarts := 0
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 2000; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.CheckTrialParams(trial.Params); err != nil {
+			t.Fatal(err)
+		}
+		if !solver.(*Solver).hasCenter {
+			restarts++
+		}
+
+		value := f(trial.Params)
+		best = math.Min(best, value)
+		if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if best > 1e-6 {
+		t.Fatalf("pattern search didn't converge: %v", best)
+	}
+	if restarts < 2 {
+		t.Fatalf("pattern search didn't restart: %d", restarts)
+	}
+}
+
+func TestDiscreteOnly(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("discrete").
+		Discrete("a", 0, 3).
+		Categorical("b", "x").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solver, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 20; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{*trial.Params[0]}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// This package provides a meta-solver that routes trials among several child solvers.
+//
+// Each Ask is answered by a child selected by the selection strategy, and the solver remembers which child owns
+// each trial so that the result is told to the owner. Optionally, the finished results are also shared with
+// the other children that implement Observer.
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Selection is the strategy to select the child that answers an Ask.
+type Selection int
+
+const (
+	// RoundRobin indicates that the children answer in turn.
+	RoundRobin Selection = iota
+
+	// Weighted indicates that a child is selected at random with the probability proportional to its weight.
+	Weighted
+
+	// Bandit indicates that a child is selected by UCB1 on the recent improvements of the best value.
+	//
+	// The reward of a trial is 1 if it improves the best (first) value found so far, and 0 otherwise.
+	Bandit
+)
+
+// String returns the string representation of a Selection value.
+func (r Selection) String() string {
+	switch r {
+	case RoundRobin:
+		return "round-robin"
+	case Weighted:
+		return "weighted"
+	case Bandit:
+		return "bandit"
+	default:
+		panic("unknown selection")
+	}
+}
+
+// Observer is implemented by solvers that can learn from the trials asked by the other solvers.
+type Observer interface {
+	// Observe records the result of a trial with the given params that has been asked by another solver.
+	Observe(params []*float64, trial kurobako.EvaluatedTrial) error
+}
+
+// Options is the options of the portfolio solver.
+type Options struct {
+	// Selection is the strategy to select the child that answers an Ask.
+	Selection Selection
+
+	// Weights is the weights of the children (only used by Weighted).
+	Weights []float64
+
+	// Window is the number of the recent rewards of each child used by Bandit.
+	Window int
+
+	// Exploration is the coefficient of the exploration term of UCB1 (only used by Bandit).
+	Exploration float64
+
+	// ShareResults indicates whether the finished results are shared with all the children that implement Observer.
+	ShareResults bool
+}
+
+// DefaultOptions returns the default options of the portfolio solver.
+func DefaultOptions() Options {
+	return Options{
+		Selection:   RoundRobin,
+		Window:      20,
+		Exploration: math.Sqrt2,
+	}
+}
+
+// SolverFactory is a SolverFactory for the portfolio solver.
+type SolverFactory struct {
+	children []kurobako.SolverFactory
+	options  Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance that consists of the given child solver factories.
+func NewSolverFactory(children []kurobako.SolverFactory, options Options) *SolverFactory {
+	return &SolverFactory{children, options}
+}
+
+// Specification returns the specification of the solver.
+//
+// The capabilities are the intersection of the ones of the children.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if r.options.Selection < RoundRobin || r.options.Selection > Bandit {
+		return nil, fmt.Errorf("unknown selection: %d", r.options.Selection)
+	}
+
+	var names []string
+	capabilities := kurobako.AllCapabilities
+	for _, child := range r.children {
+		childSpec, err := child.Specification()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, childSpec.Name)
+		capabilities &= childSpec.Capabilities
+	}
+
+	spec := kurobako.NewSolverSpec(fmt.Sprintf("Portfolio (%s)", strings.Join(names, ", ")))
+	spec.Attrs["selection"] = r.options.Selection.String()
+	if r.options.ShareResults {
+		spec.Attrs["share_results"] = "true"
+	}
+	spec.Capabilities = capabilities
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+//
+// The children are created with the seeds derived from the given seed.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if len(r.children) == 0 {
+		return nil, errors.New("a portfolio requires at least one child")
+	}
+
+	options := r.options
+	switch options.Selection {
+	case RoundRobin:
+	case Weighted:
+		if len(options.Weights) != len(r.children) {
+			return nil, fmt.Errorf("expected %d weights, but got %d", len(r.children), len(options.Weights))
+		}
+		sum := 0.0
+		for _, w := range options.Weights {
+			if !(w >= 0) {
+				return nil, fmt.Errorf("weights must be non-negative: %v", options.Weights)
+			}
+			sum += w
+		}
+		if sum == 0 {
+			return nil, errors.New("at least one weight must be positive")
+		}
+	case Bandit:
+		if options.Window < 1 {
+			return nil, fmt.Errorf("window must be positive: %d", options.Window)
+		}
+	default:
+		return nil, fmt.Errorf("unknown selection: %d", options.Selection)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	children := make([]*child, len(r.children))
+	for i, factory := range r.children {
+		solver, err := factory.CreateSolver(rng.Int63(), problem)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = &child{solver: solver}
+	}
+
+	return &Solver{
+		options:  options,
+		lastStep: problem.Steps.Last(),
+		rng:      rng,
+		children: children,
+		trials:   map[uint64]*trialOwner{},
+		best:     math.Inf(0),
+	}, nil
+}
+
+type child struct {
+	solver    kurobako.Solver
+	exhausted bool
+
+	// rewards is the recent rewards of the child (only used by Bandit).
+	rewards []float64
+
+	// pulls is the number of the trials asked by the child.
+	pulls int
+}
+
+type trialOwner struct {
+	child  int
+	params []*float64
+
+	// stopped indicates that the owner has stopped the trial (i.e., returned it with NextStep == 0),
+	// and the trial waits for the follow-up tell.
+	stopped bool
+}
+
+// Solver is the portfolio solver.
+type Solver struct {
+	options  Options
+	lastStep uint64
+	rng      *rand.Rand
+	children []*child
+
+	// trials maps the IDs of the unfinished trials to their owners.
+	trials map[uint64]*trialOwner
+
+	next  int
+	pulls int
+	best  float64
+}
+
+// Ask asks the selected child for a trial.
+//
+// If the child has no more trials to ask (i.e., it returns kurobako.ErrorSolverExhausted), another child is selected.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	for {
+		i := r.selectChild()
+		if i < 0 {
+			return kurobako.NextTrial{}, fmt.Errorf("%w: all the children are exhausted", kurobako.ErrorSolverExhausted)
+		}
+
+		trial, err := r.children[i].solver.Ask(idg)
+		if errors.Is(err, kurobako.ErrorSolverExhausted) {
+			r.children[i].exhausted = true
+			continue
+		}
+		if err != nil {
+			return trial, err
+		}
+		r.children[i].pulls++
+		r.pulls++
+
+		if owner, ok := r.trials[trial.TrialID]; ok {
+			// The child resumes or stops one of its trials.
+			if owner.child != i {
+				return trial, fmt.Errorf("child %d asked the trial %d owned by child %d", i, trial.TrialID, owner.child)
+			}
+			if owner.stopped {
+				return trial, fmt.Errorf("child %d asked the stopped trial %d again", i, trial.TrialID)
+			}
+			owner.stopped = trial.NextStep == 0
+		} else {
+			r.trials[trial.TrialID] = &trialOwner{child: i, params: trial.Params, stopped: trial.NextStep == 0}
+		}
+		return trial, nil
+	}
+}
+
+// selectChild returns the index of the child that answers the next Ask (or -1 if all the children are exhausted).
+func (r *Solver) selectChild() int {
+	var candidates []int
+	for i, c := range r.children {
+		if !c.exhausted {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return -1
+	}
+
+	switch r.options.Selection {
+	case Weighted:
+		sum := 0.0
+		for _, i := range candidates {
+			sum += r.options.Weights[i]
+		}
+		if sum == 0 {
+			// Only the children with zero weights remain.
+			return candidates[r.rng.Intn(len(candidates))]
+		}
+		u := r.rng.Float64() * sum
+		for _, i := range candidates {
+			u -= r.options.Weights[i]
+			if u < 0 {
+				return i
+			}
+		}
+		return candidates[len(candidates)-1]
+	case Bandit:
+		best, bestScore := -1, math.Inf(-1)
+		for _, i := range candidates {
+			c := r.children[i]
+			if c.pulls == 0 {
+				best = i
+				break
+			}
+			mean := 0.0
+			for _, reward := range c.rewards {
+				mean += reward
+			}
+			if len(c.rewards) > 0 {
+				mean /= float64(len(c.rewards))
+			}
+			score := mean + r.options.Exploration*math.Sqrt(math.Log(float64(r.pulls))/float64(c.pulls))
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		return best
+	default:
+		for {
+			i := r.next
+			r.next = (r.next + 1) % len(r.children)
+			if !r.children[i].exhausted {
+				return i
+			}
+		}
+	}
+}
+
+// Tell tells the result of a trial to its owner (and shares it with the other children if the trial is finished).
+//
+// A stopped trial is finished by the tell that follows the stop, and its owner is forgotten at that time.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	owner, ok := r.trials[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	if err := r.children[owner.child].solver.Tell(trial); err != nil {
+		return err
+	}
+
+	if !owner.stopped && len(trial.Values) > 0 && trial.CurrentStep < r.lastStep {
+		// The owner may resume the trial later.
+		return nil
+	}
+	delete(r.trials, trial.TrialID)
+
+	reward := 0.0
+	if len(trial.Values) > 0 && trial.Values[0] < r.best {
+		r.best = trial.Values[0]
+		reward = 1
+	}
+	if r.options.Selection == Bandit {
+		c := r.children[owner.child]
+		c.rewards = append(c.rewards, reward)
+		if len(c.rewards) > r.options.Window {
+			c.rewards = c.rewards[1:]
+		}
+	}
+
+	if r.options.ShareResults {
+		for i, c := range r.children {
+			if observer, ok := c.solver.(Observer); ok && i != owner.child {
+				if err := observer.Observe(owner.params, trial); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
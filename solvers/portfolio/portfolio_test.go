@@ -0,0 +1,229 @@
+package portfolio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/solvers/asha"
+	"github.com/sile/kurobako-go/solvers/grid"
+	"github.com/sile/kurobako-go/solvers/neldermead"
+	"github.com/sile/kurobako-go/solvers/qmc"
+)
+
+// fixedFactory creates solvers that always ask the same point and record the results told or observed.
+type fixedFactory struct {
+	value    float64
+	told     []uint64
+	observed []uint64
+}
+
+func (r *fixedFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("fixed")
+	return &spec, nil
+}
+
+func (r *fixedFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	return &fixedSolver{r}, nil
+}
+
+type fixedSolver struct {
+	factory *fixedFactory
+}
+
+func (r *fixedSolver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	value := r.factory.value
+	return kurobako.NextTrial{TrialID: idg.Generate(), Params: []*float64{&value}, NextStep: 1}, nil
+}
+
+func (r *fixedSolver) Tell(trial kurobako.EvaluatedTrial) error {
+	r.factory.told = append(r.factory.told, trial.TrialID)
+	return nil
+}
+
+func (r *fixedSolver) Observe(params []*float64, trial kurobako.EvaluatedTrial) error {
+	r.factory.observed = append(r.factory.observed, trial.TrialID)
+	return nil
+}
+
+func problemSpec(t *testing.T) *kurobako.ProblemSpec {
+	spec, err := kurobako.NewProblemSpecBuilder("quadratic").
+		Continuous("x", 0.0, 1.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestSpecification(t *testing.T) {
+	factory := NewSolverFactory([]kurobako.SolverFactory{
+		qmc.NewSolverFactory(qmc.DefaultOptions()),
+		neldermead.NewSolverFactory(neldermead.DefaultOptions()),
+	}, DefaultOptions())
+
+	spec, err := factory.Specification()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != "Portfolio (Scrambled Sobol, Nelder-Mead)" {
+		t.Errorf("unexpected name: %q", spec.Name)
+	}
+	if spec.Capabilities&kurobako.Categorical != 0 || spec.Capabilities&kurobako.Conditional == 0 {
+		t.Errorf("unexpected capabilities: %v", spec.Capabilities)
+	}
+
+	options := DefaultOptions()
+	options.Selection = Bandit + 1
+	factory = NewSolverFactory([]kurobako.SolverFactory{qmc.NewSolverFactory(qmc.DefaultOptions())}, options)
+	if _, err := factory.Specification(); err == nil {
+		t.Fatal("expected an unknown selection error")
+	}
+}
+
+func TestSolverRoutesTrials(t *testing.T) {
+	spec := problemSpec(t)
+
+	for _, selection := range []Selection{RoundRobin, Weighted, Bandit} {
+		good := &fixedFactory{value: 0.1}
+		bad := &fixedFactory{value: 0.9}
+		options := DefaultOptions()
+		options.Selection = selection
+		options.Weights = []float64{1, 1}
+		options.ShareResults = true
+		solver, err := NewSolverFactory([]kurobako.SolverFactory{bad, good}, options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 100; i++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The improvements of the good child continue for a while.
+			x := *trial.Params[0]
+			value := float64(100-i) * x
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: 1}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(good.told)+len(bad.told) != 100 {
+			t.Fatalf("%v: expected 100 tells, got %d", selection, len(good.told)+len(bad.told))
+		}
+		if len(good.observed) != len(bad.told) || len(bad.observed) != len(good.told) {
+			t.Fatalf("%v: the results aren't shared", selection)
+		}
+		if selection == RoundRobin && len(good.told) != 50 {
+			t.Fatalf("round-robin: expected 50 tells, got %d", len(good.told))
+		}
+		if selection == Bandit && len(good.told) <= len(bad.told) {
+			t.Fatalf("bandit: the good child is selected only %d times", len(good.told))
+		}
+
+		if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: 1000}); err == nil {
+			t.Fatal("expected an unknown trial error")
+		}
+	}
+}
+
+func TestSolverSkipsExhaustedChildren(t *testing.T) {
+	spec := problemSpec(t)
+
+	options := grid.DefaultOptions()
+	options.Points = 3
+	options.Cycle = false
+	children := []kurobako.SolverFactory{grid.NewSolverFactory(options), grid.NewSolverFactory(options)}
+	for _, selection := range []Selection{RoundRobin, Bandit} {
+		options := DefaultOptions()
+		options.Selection = selection
+		solver, err := NewSolverFactory(children, options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var idg kurobako.TrialIDGenerator
+		for i := 0; i < 6; i++ {
+			trial, err := solver.Ask(&idg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{*trial.Params[0]}, CurrentStep: 1}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := solver.Ask(&idg); !errors.Is(err, kurobako.ErrorSolverExhausted) {
+			t.Fatalf("%v: expected ErrorSolverExhausted, got %v", selection, err)
+		}
+
+		// The asks that returned ErrorSolverExhausted aren't counted as pulls.
+		s := solver.(*Solver)
+		if s.pulls != 6 || s.children[0].pulls != 3 || s.children[1].pulls != 3 {
+			t.Fatalf("%v: unexpected pulls: %d (%d, %d)", selection, s.pulls, s.children[0].pulls, s.children[1].pulls)
+		}
+	}
+}
+
+func TestSolverRoutesFollowUpTellsOfStoppedTrials(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("curve").
+		Continuous("x", 0.0, 1.0).
+		Steps(1, 3, 9).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := asha.DefaultOptions()
+	options.MaxPausedTrials = 2
+	child := asha.NewSolverFactory(qmc.NewSolverFactory(qmc.DefaultOptions()), options)
+	s, err := NewSolverFactory([]kurobako.SolverFactory{child, child}, DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	xs := map[uint64]float64{}
+	steps := map[uint64]uint64{}
+	stopped := map[uint64]bool{}
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 200; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if trial.NextStep == 0 {
+			// The stopped trial is told once more, and the tell is routed to the child that stopped it.
+			stopped[trial.TrialID] = true
+			evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{}, CurrentStep: steps[trial.TrialID]}
+			if err := solver.Tell(evaluated); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := solver.trials[trial.TrialID]; ok {
+				t.Fatalf("the owner of the stopped trial %d isn't forgotten", trial.TrialID)
+			}
+			continue
+		}
+
+		if len(trial.Params) > 0 {
+			xs[trial.TrialID] = *trial.Params[0]
+		}
+		steps[trial.TrialID] = trial.NextStep
+		value := xs[trial.TrialID] + 1/float64(trial.NextStep)
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: trial.NextStep}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(stopped) == 0 {
+		t.Fatal("no trials have been stopped")
+	}
+}
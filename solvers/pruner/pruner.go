@@ -0,0 +1,372 @@
+// This package provides a wrapper solver that adds median-stopping (or percentile) pruning to any inner solver.
+//
+// The trials asked by the inner solver are evaluated step by step (at every checkpoint of the problem's steps),
+// and the intermediate values are recorded by step. A trial is stopped (i.e., returned from Ask with NextStep == 0)
+// when its value at a step is worse than the given percentile of the values of the completed trials at the step
+// (i.e., the trials that have reached the step requested by the inner solver or the last step).
+// Otherwise, the trial is resumed (i.e., returned from Ask with the next checkpoint) until it reaches the step
+// requested by the inner solver.
+//
+// The inner solver is told the result of a trial when the trial reaches the requested step or is stopped
+// (in the latter case, with the latest intermediate values). So it works with single-fidelity solvers that always ask
+// for the last step as well as with multi-fidelity ones.
+//
+// As with the pruned trials of the Goptuna solver, a stopped trial should be told once more (e.g., with empty values)
+// after it is returned from Ask. The tell is forwarded to the inner solver only if the inner solver has stopped
+// the trial.
+package pruner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the pruner.
+type Options struct {
+	// Percentile is the percentile (in [0, 100]) of the values of the completed trials used as the threshold.
+	//
+	// A trial is stopped if its value is greater (i.e., worse) than the threshold. 50 means the median stopping rule.
+	Percentile float64
+
+	// WarmupTrials is the minimum number of the completed trials that have reported a value at a step.
+	//
+	// No trials are stopped at a step until this number of the completed trials have been evaluated at the step.
+	WarmupTrials int
+
+	// WarmupSteps is the step before which no trials are stopped.
+	WarmupSteps uint64
+
+	// Interval is the interval (in the number of steps of the problem) of the checkpoints.
+	//
+	// The last step of the problem is always a checkpoint.
+	Interval int
+
+	// MaxIdleTrials is the maximum number of the idle trials (i.e., the trials that have reached the step requested by
+	// the inner solver) kept for the inner solver to resume.
+	//
+	// If more trials are idle, the trial that has been idle the longest is forgotten, and Ask returns an error
+	// if the inner solver resumes it later. If this is zero, idle trials are never forgotten.
+	MaxIdleTrials int
+}
+
+// DefaultOptions returns the default options of the pruner.
+func DefaultOptions() Options {
+	return Options{
+		Percentile:    50,
+		WarmupTrials:  5,
+		Interval:      1,
+		MaxIdleTrials: 1000,
+	}
+}
+
+// SolverFactory is a SolverFactory for the pruner.
+type SolverFactory struct {
+	inner   kurobako.SolverFactory
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance that wraps the given inner solver factory.
+func NewSolverFactory(inner kurobako.SolverFactory, options Options) *SolverFactory {
+	return &SolverFactory{inner, options}
+}
+
+// Specification returns the specification of the solver.
+//
+// The capabilities are the ones of the inner solver except for multi-objective optimization
+// (trials are compared by their first values).
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	innerSpec, err := r.inner.Specification()
+	if err != nil {
+		return nil, err
+	}
+
+	name := "Percentile Pruner"
+	if r.options.Percentile == 50 {
+		name = "Median Pruner"
+	}
+
+	spec := kurobako.NewSolverSpec(fmt.Sprintf("%s (%s)", name, innerSpec.Name))
+	for k, v := range innerSpec.Attrs {
+		spec.Attrs[k] = v
+	}
+	spec.Attrs["percentile"] = strconv.FormatFloat(r.options.Percentile, 'g', -1, 64)
+	spec.Attrs["warmup_trials"] = strconv.Itoa(r.options.WarmupTrials)
+	spec.Attrs["warmup_steps"] = strconv.FormatUint(r.options.WarmupSteps, 10)
+	spec.Capabilities = innerSpec.Capabilities &^ kurobako.MultiObjective
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	if r.options.Percentile < 0 || r.options.Percentile > 100 {
+		return nil, fmt.Errorf("percentile must be in [0, 100]: %v", r.options.Percentile)
+	}
+	if r.options.Interval < 1 {
+		return nil, fmt.Errorf("interval must be positive: %d", r.options.Interval)
+	}
+	if r.options.MaxIdleTrials < 0 {
+		return nil, fmt.Errorf("the maximum number of idle trials must not be negative: %d", r.options.MaxIdleTrials)
+	}
+
+	inner, err := r.inner.CreateSolver(seed, problem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Solver{
+		inner:     inner,
+		options:   r.options,
+		params:    len(problem.Params),
+		steps:     problem.Steps,
+		trials:    map[uint64]*trialState{},
+		completed: map[uint64][]float64{},
+	}, nil
+}
+
+type status int
+
+const (
+	running status = iota
+
+	// resuming indicates that the trial will be resumed by the succeeding asks.
+	resuming
+
+	// idle indicates that the trial has reached the step requested by the inner solver
+	// (and it may be resumed by the inner solver later).
+	idle
+
+	// stopping indicates that the trial will be stopped by the succeeding asks.
+	stopping
+
+	// stopped indicates that the trial has been stopped by an ask and waits for the follow-up tell.
+	stopped
+)
+
+type trialState struct {
+	id     uint64
+	status status
+
+	// target is the step requested by the inner solver.
+	target uint64
+
+	// step and values are the latest evaluation result of the trial.
+	step   uint64
+	values []float64
+
+	// intermediates is the intermediate values of the trial keyed by steps that haven't been added to the completed
+	// values yet (a value reported at the same step again overwrites the previous one).
+	intermediates map[uint64]float64
+
+	// idleSince is the sequence number at which the trial became idle.
+	idleSince uint64
+
+	// stoppedByInner indicates that the trial has been stopped by the inner solver
+	// (so the follow-up tell is forwarded to the inner solver).
+	stoppedByInner bool
+}
+
+// Solver is the pruner.
+type Solver struct {
+	inner   kurobako.Solver
+	options Options
+	params  int
+	steps   kurobako.Steps
+	trials  map[uint64]*trialState
+
+	// completed is the intermediate values of the trials that have reached their target steps keyed by steps.
+	completed map[uint64][]float64
+
+	// idle is the number of the idle trials, and idleSeq is the sequence number given to the next idle trial.
+	idle    int
+	idleSeq uint64
+
+	// stopped and resumed are the trials to be stopped or resumed by the succeeding asks.
+	stopped []*trialState
+	resumed []*trialState
+}
+
+// Ask stops a pruned trial, resumes a trial or asks the inner solver for a trial (in this order).
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	if len(r.stopped) > 0 {
+		t := r.stopped[0]
+		r.stopped = r.stopped[1:]
+		t.status = stopped
+		return kurobako.NextTrial{TrialID: t.id, Params: []*float64{}, NextStep: 0}, nil
+	}
+
+	if len(r.resumed) > 0 {
+		t := r.resumed[0]
+		r.resumed = r.resumed[1:]
+		t.status = running
+		return kurobako.NextTrial{TrialID: t.id, Params: []*float64{}, NextStep: r.nextCheckpoint(t)}, nil
+	}
+
+	trial, err := r.inner.Ask(idg)
+	if err != nil {
+		return trial, err
+	}
+
+	t, ok := r.trials[trial.TrialID]
+	if ok && t.status != idle {
+		return trial, fmt.Errorf("the inner solver asked the running trial %d again", trial.TrialID)
+	}
+	if ok {
+		r.idle--
+	}
+	if trial.NextStep == 0 {
+		// The inner solver has pruned the trial.
+		r.trials[trial.TrialID] = &trialState{id: trial.TrialID, status: stopped, stoppedByInner: true}
+		return trial, nil
+	}
+	if !ok && len(trial.Params) != r.params {
+		// Resumed trials have no params.
+		return trial, fmt.Errorf("the inner solver resumed the unknown (or forgotten) trial %d", trial.TrialID)
+	}
+	if !ok {
+		t = &trialState{id: trial.TrialID, intermediates: map[uint64]float64{}}
+		r.trials[trial.TrialID] = t
+	}
+	t.status = running
+	t.target = trial.NextStep
+	trial.NextStep = r.nextCheckpoint(t)
+	return trial, nil
+}
+
+// nextCheckpoint returns the smallest checkpoint that is greater than the current step of a trial
+// (or the target step of the trial if there are no such checkpoints before the target).
+//
+// The checkpoints are found by Steps.Next, so the cost doesn't depend on the number of the steps of the problem.
+func (r *Solver) nextCheckpoint(t *trialState) uint64 {
+	step, ok := r.steps.Next(t.step)
+	if !ok {
+		return t.target
+	}
+
+	// The checkpoints are every Interval-th step and the last step.
+	i, _ := r.steps.Index(step)
+	for ; (i+1)%r.options.Interval != 0 && step < t.target; i++ {
+		next, ok := r.steps.Next(step)
+		if !ok {
+			break
+		}
+		step = next
+	}
+	if step < t.target {
+		return step
+	}
+	return t.target
+}
+
+// Tell records the intermediate value of a trial, and decides whether to stop the trial.
+//
+// Unevalable trials are told to the inner solver immediately.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	t, ok := r.trials[trial.TrialID]
+	if ok && t.status == stopped {
+		delete(r.trials, t.id)
+		if t.stoppedByInner {
+			return r.inner.Tell(trial)
+		}
+		return nil
+	}
+	if !ok || t.status != running {
+		return fmt.Errorf("unknown or not running trial: %d", trial.TrialID)
+	}
+	t.step = trial.CurrentStep
+	t.values = trial.Values
+
+	if len(trial.Values) == 0 {
+		delete(r.trials, t.id)
+		return r.tellInner(t)
+	}
+
+	value := trial.Values[0]
+	if math.IsNaN(value) {
+		value = math.Inf(0)
+	}
+	t.intermediates[t.step] = value
+
+	if t.step >= r.steps.Last() {
+		delete(r.trials, t.id)
+		r.complete(t)
+		return r.tellInner(t)
+	}
+
+	if r.shouldPrune(t.step, value) {
+		t.status = stopping
+		r.stopped = append(r.stopped, t)
+		return r.tellInner(t)
+	}
+
+	if t.step >= t.target {
+		r.complete(t)
+		t.status = idle
+		t.idleSince = r.idleSeq
+		r.idleSeq++
+		r.idle++
+		if r.options.MaxIdleTrials > 0 && r.idle > r.options.MaxIdleTrials {
+			r.forgetOldestIdle()
+		}
+		return r.tellInner(t)
+	}
+
+	t.status = resuming
+	r.resumed = append(r.resumed, t)
+	return nil
+}
+
+// complete adds the intermediate values of a trial that has reached its target step to the completed values.
+//
+// The values are moved, so a trial resumed by the inner solver later adds only the values of the new steps.
+func (r *Solver) complete(t *trialState) {
+	for step, value := range t.intermediates {
+		r.completed[step] = append(r.completed[step], value)
+	}
+	t.intermediates = map[uint64]float64{}
+}
+
+func (r *Solver) forgetOldestIdle() {
+	var oldest *trialState
+	for _, t := range r.trials {
+		if t.status == idle && (oldest == nil || t.idleSince < oldest.idleSince) {
+			oldest = t
+		}
+	}
+	delete(r.trials, oldest.id)
+	r.idle--
+}
+
+func (r *Solver) tellInner(t *trialState) error {
+	return r.inner.Tell(kurobako.EvaluatedTrial{TrialID: t.id, Values: t.values, CurrentStep: t.step})
+}
+
+// shouldPrune returns whether the value at a step is worse than the percentile of the completed trials.
+func (r *Solver) shouldPrune(step uint64, value float64) bool {
+	if step < r.options.WarmupSteps {
+		return false
+	}
+
+	completed := r.completed[step]
+	if len(completed) == 0 || len(completed) < r.options.WarmupTrials {
+		return false
+	}
+	return value > percentile(completed, r.options.Percentile)
+}
+
+// percentile returns the p-th percentile of the values (linearly interpolated between the closest ranks).
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper || math.IsInf(sorted[upper], 0) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
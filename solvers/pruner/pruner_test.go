@@ -0,0 +1,248 @@
+package pruner
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/solvers/qmc"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 3, 2, 4}
+	for _, c := range []struct {
+		p        float64
+		expected float64
+	}{{0, 1}, {50, 3}, {25, 2}, {90, 4.6}, {100, 5}} {
+		if actual := percentile(values, c.p); math.Abs(actual-c.expected) > 1e-12 {
+			t.Errorf("percentile(%v): expected %v, got %v", c.p, c.expected, actual)
+		}
+	}
+
+	if actual := percentile([]float64{1, math.Inf(0)}, 50); actual != 1 {
+		t.Errorf("expected 1, got %v", actual)
+	}
+}
+
+func TestSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("curve").
+		Continuous("x", 0.0, 1.0).
+		Steps(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultOptions()
+	options.Interval = 3
+	solver, err := NewSolverFactory(qmc.NewSolverFactory(qmc.DefaultOptions()), options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// xs and steps are the params and the latest steps of the running trials.
+	xs := map[uint64]float64{}
+	steps := map[uint64]uint64{}
+	completed := 0
+	var stopped []float64
+	var idg kurobako.TrialIDGenerator
+	for i := 0; i < 300; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		x, ok := xs[trial.TrialID]
+		if ok {
+			if trial.NextStep == 0 {
+				stopped = append(stopped, x)
+				delete(xs, trial.TrialID)
+				if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{}, CurrentStep: steps[trial.TrialID]}); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if trial.NextStep <= steps[trial.TrialID] {
+				t.Fatalf("trial %d is resumed at step %d (<= %d)", trial.TrialID, trial.NextStep, steps[trial.TrialID])
+			}
+		} else {
+			if err := spec.CheckTrialParams(trial.Params); err != nil {
+				t.Fatal(err)
+			}
+			x = *trial.Params[0]
+			xs[trial.TrialID] = x
+		}
+
+		if trial.NextStep%3 != 0 && trial.NextStep != 10 {
+			t.Fatalf("step %d isn't a checkpoint", trial.NextStep)
+		}
+		steps[trial.TrialID] = trial.NextStep
+		if trial.NextStep == 10 {
+			completed++
+			delete(xs, trial.TrialID)
+		}
+
+		value := x + 1/float64(trial.NextStep)
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: trial.NextStep}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if completed < options.WarmupTrials || len(stopped) == 0 {
+		t.Fatalf("completed=%d, stopped=%d", completed, len(stopped))
+	}
+	for _, x := range stopped {
+		if x < 0.1 {
+			t.Errorf("a good trial (x=%v) is stopped", x)
+		}
+	}
+}
+
+// scriptedFactory creates a solver that asks the given trials in order.
+type scriptedFactory struct {
+	asks []kurobako.NextTrial
+	told []kurobako.EvaluatedTrial
+}
+
+func (r *scriptedFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Scripted")
+	return &spec, nil
+}
+
+func (r *scriptedFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	return r, nil
+}
+
+func (r *scriptedFactory) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	trial := r.asks[0]
+	r.asks = r.asks[1:]
+	return trial, nil
+}
+
+func (r *scriptedFactory) Tell(trial kurobako.EvaluatedTrial) error {
+	r.told = append(r.told, trial)
+	return nil
+}
+
+func TestSolverIdleTrials(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("curve").
+		Continuous("x", 0.0, 1.0).
+		Steps(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := 0.5
+	newTrial := func(id uint64) kurobako.NextTrial {
+		return kurobako.NextTrial{TrialID: id, Params: []*float64{&x}, NextStep: 3}
+	}
+	resumedTrial := func(id uint64) kurobako.NextTrial {
+		return kurobako.NextTrial{TrialID: id, Params: []*float64{}, NextStep: 10}
+	}
+	inner := &scriptedFactory{asks: []kurobako.NextTrial{
+		newTrial(0), newTrial(1), newTrial(2), newTrial(3), resumedTrial(2), resumedTrial(0),
+		{TrialID: 1, Params: []*float64{}, NextStep: 0},
+	}}
+
+	options := DefaultOptions()
+	options.Interval = 3
+	options.WarmupTrials = 2
+	options.MaxIdleTrials = 2
+	s, err := NewSolverFactory(inner, options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	var idg kurobako.TrialIDGenerator
+	for i, value := range []float64{1, 1, 1, 5} {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evaluated := kurobako.EvaluatedTrial{TrialID: trial.TrialID, Values: []float64{value}, CurrentStep: trial.NextStep}
+		if err := solver.Tell(evaluated); err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && (len(solver.completed[3]) != 3 || len(solver.trials) != 2) {
+			t.Fatalf("completed=%v, trials=%d", solver.completed, len(solver.trials))
+		}
+	}
+
+	// The trials that have reached their target steps are compared with the last one.
+	if trial, err := solver.Ask(&idg); err != nil || trial.TrialID != 3 || trial.NextStep != 0 {
+		t.Fatalf("the worse trial isn't stopped: %v (err=%v)", trial, err)
+	}
+
+	// The follow-up tell of the stopped trial isn't forwarded (the inner solver has been told the latest values).
+	told := len(inner.told)
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: 3, Values: []float64{}, CurrentStep: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.told) != told {
+		t.Fatalf("the follow-up tell is forwarded: %v", inner.told[told:])
+	}
+
+	// An idle trial is resumed from its step, and the forgotten one can't be resumed.
+	if trial, err := solver.Ask(&idg); err != nil || trial.TrialID != 2 || trial.NextStep != 6 {
+		t.Fatalf("unexpected resumed trial: %v (err=%v)", trial, err)
+	}
+	if _, err := solver.Ask(&idg); err == nil {
+		t.Fatal("the forgotten trial should be rejected")
+	}
+
+	// The follow-up tell of a trial stopped by the inner solver is forwarded to the inner solver.
+	if trial, err := solver.Ask(&idg); err != nil || trial.TrialID != 1 || trial.NextStep != 0 {
+		t.Fatalf("the trial stopped by the inner solver isn't returned: %v (err=%v)", trial, err)
+	}
+	told = len(inner.told)
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: 1, Values: []float64{}, CurrentStep: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.told) != told+1 || inner.told[told].TrialID != 1 {
+		t.Fatalf("the follow-up tell isn't forwarded: %v", inner.told[told:])
+	}
+	if _, ok := solver.trials[1]; ok {
+		t.Fatal("the stopped trial isn't forgotten after the follow-up tell")
+	}
+}
+
+func TestNextCheckpoint(t *testing.T) {
+	var sequential kurobako.Steps
+	if err := sequential.UnmarshalJSON([]byte("1000000000000")); err != nil {
+		t.Fatal(err)
+	}
+	geometric, err := kurobako.NewGeometricSteps(1, 81, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		steps    kurobako.Steps
+		interval int
+		step     uint64
+		target   uint64
+		expected uint64
+	}{
+		{sequential, 10, 0, sequential.Last(), 10},
+		{sequential, 10, 10, sequential.Last(), 20},
+		{sequential, 10, 15, sequential.Last(), 20},
+		{sequential, 10, 15, 18, 18},
+		{sequential, 10, sequential.Last() - 5, sequential.Last(), sequential.Last()},
+		{*geometric, 2, 0, 81, 3},
+		{*geometric, 2, 3, 81, 27},
+		{*geometric, 2, 27, 81, 81},
+		{*geometric, 2, 3, 9, 9},
+	} {
+		solver := &Solver{options: Options{Interval: c.interval}, steps: c.steps}
+		actual := solver.nextCheckpoint(&trialState{step: c.step, target: c.target})
+		if actual != c.expected {
+			t.Errorf("interval=%d, step=%d, target=%d: expected %d, got %d",
+				c.interval, c.step, c.target, c.expected, actual)
+		}
+	}
+}
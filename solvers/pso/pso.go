@@ -0,0 +1,287 @@
+// This package provides a solver based on particle swarm optimization (PSO) with constriction factors.
+//
+// Numerical parameters are optimized in the unit box, and mapped to their ranges by kurobako.Var.FromUnit
+// (so discrete parameters are rounded to integers). A particle that leaves the box is clipped to the box,
+// and the velocity of the coordinate is reset to zero.
+//
+// Categorical parameters are handled as choice indices: at each move, a particle switches to the choice of
+// its personal best or the global best with the probabilities proportional to the attraction coefficients
+// (c1 r1 and c2 r2), and to a choice selected uniformly at random with the probability Options.SwitchProb.
+//
+// A particle moves in the space of all the parameters, including the ones that are inactive at its position.
+// Their coordinates keep being attracted by the personal and global bests, but they are removed from the asked params.
+//
+// The solver is steady-state: the particles move one by one, and the personal and global bests are updated
+// as soon as each trial is evaluated, so any number of trials can be asked concurrently.
+//
+// See "The particle swarm - explosion, stability, and convergence in a multidimensional complex space"
+// (Clerc and Kennedy, 2002) for the constriction factors.
+package pso
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the PSO solver.
+type Options struct {
+	// SwarmSize is the number of the particles.
+	SwarmSize int
+
+	// C1 and C2 are the acceleration coefficients of the personal best and the global best.
+	//
+	// The constriction factor chi is derived from phi = C1 + C2 (which must be greater than 4).
+	C1 float64
+	C2 float64
+
+	// SwitchProb is the probability that a categorical parameter switches to a random choice.
+	SwitchProb float64
+}
+
+// DefaultOptions returns the default options of the PSO solver.
+func DefaultOptions() Options {
+	return Options{
+		SwarmSize:  20,
+		C1:         2.05,
+		C2:         2.05,
+		SwitchProb: 0.05,
+	}
+}
+
+// SolverFactory is a SolverFactory for the PSO solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("Particle Swarm Optimization")
+	spec.Attrs["swarm_size"] = strconv.Itoa(r.options.SwarmSize)
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.SwarmSize < 1 {
+		return nil, fmt.Errorf("swarm size must be positive: %d", options.SwarmSize)
+	}
+	phi := options.C1 + options.C2
+	if options.C1 < 0 || options.C2 < 0 || phi <= 4 {
+		return nil, fmt.Errorf("c1 (%v) and c2 (%v) must be non-negative and their sum must be greater than 4", options.C1, options.C2)
+	}
+	if options.SwitchProb < 0 || options.SwitchProb > 1 {
+		return nil, fmt.Errorf("switch probability must be in [0, 1]: %v", options.SwitchProb)
+	}
+
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	solver := &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		chi:         2 / math.Abs(2-phi-math.Sqrt(phi*phi-4*phi)),
+		globalBest:  math.Inf(0),
+		pending:     map[uint64]pendingPosition{},
+	}
+	for i := 0; i < options.SwarmSize; i++ {
+		solver.swarm = append(solver.swarm, solver.newParticle())
+	}
+	return solver, nil
+}
+
+type particle struct {
+	// x is the coordinates in the unit box (numerical parameters) or the choice indices (categorical parameters).
+	x        []float64
+	velocity []float64
+
+	best        []float64
+	bestFitness float64
+
+	// initialized indicates whether the initial position has been asked.
+	initialized bool
+
+	// generation is the number of the times the particle at the same index of the swarm has been replaced.
+	generation int
+}
+
+type pendingPosition struct {
+	particle int
+	x        []float64
+
+	// generation is the generation of the particle when the position was asked.
+	generation int
+}
+
+// Solver is the PSO solver.
+type Solver struct {
+	options     Options
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	rng         *rand.Rand
+	chi         float64
+
+	swarm []*particle
+	next  int
+
+	globalBestX []float64
+	globalBest  float64
+
+	// pending is the positions that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64]pendingPosition
+}
+
+// Ask moves the next particle and returns its new position (or its initial position).
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	k := r.next
+	r.next = (r.next + 1) % len(r.swarm)
+	p := r.swarm[k]
+	if p.initialized {
+		if r.globalBestX != nil {
+			r.move(p)
+		} else {
+			// Nothing has been told yet (i.e., there are more concurrent asks than the particles), so the particle
+			// restarts from a new random position instead of asking the same position again.
+			generation := p.generation + 1
+			p = r.newParticle()
+			p.generation = generation
+			r.swarm[k] = p
+		}
+	}
+	p.initialized = true
+
+	params, err := r.decode(p.x)
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = pendingPosition{k, append([]float64(nil), p.x...), p.generation}
+	return trial, nil
+}
+
+// Tell updates the personal best of the particle and the global best.
+//
+// An unevalable trial (or a NaN value) has the fitness +Inf, so it becomes a best only if nothing has been evaluated
+// before (which keeps the particles attracted to some position). A position asked before the particle was replaced
+// updates only the global best.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	position, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	fitness := math.Inf(0)
+	if len(trial.Values) > 0 && !math.IsNaN(trial.Values[0]) {
+		fitness = trial.Values[0]
+	}
+
+	p := r.swarm[position.particle]
+	if p.generation == position.generation && (p.best == nil || fitness < p.bestFitness) {
+		p.best = position.x
+		p.bestFitness = fitness
+	}
+	if r.globalBestX == nil || fitness < r.globalBest {
+		r.globalBestX = position.x
+		r.globalBest = fitness
+	}
+	return nil
+}
+
+func (r *Solver) newParticle() *particle {
+	n := len(r.problem.Params)
+	p := &particle{x: make([]float64, n), velocity: make([]float64, n), bestFitness: math.Inf(0)}
+	for i, v := range r.problem.Params {
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			p.x[i] = float64(r.rng.Intn(len(categorical.Choices)))
+		} else {
+			p.x[i] = r.rng.Float64()
+			p.velocity[i] = (r.rng.Float64() - p.x[i]) / 2
+		}
+	}
+	return p
+}
+
+// move updates the velocity and the position of a particle.
+func (r *Solver) move(p *particle) {
+	best := p.best
+	if best == nil {
+		// The initial position hasn't been evaluated yet.
+		best = p.x
+	}
+
+	for i, v := range r.problem.Params {
+		r1, r2 := r.rng.Float64(), r.rng.Float64()
+		a1, a2 := r.options.C1*r1, r.options.C2*r2
+
+		if categorical := v.Range.AsCategoricalRange(); categorical != nil {
+			// The current choice is kept with the probability proportional to the inertia (one).
+			u := r.rng.Float64() * (1 + a1 + a2)
+			switch {
+			case u < a1:
+				p.x[i] = best[i]
+			case u < a1+a2:
+				p.x[i] = r.globalBestX[i]
+			}
+			if r.rng.Float64() < r.options.SwitchProb {
+				p.x[i] = float64(r.rng.Intn(len(categorical.Choices)))
+			}
+			continue
+		}
+
+		p.velocity[i] = r.chi * (p.velocity[i] + a1*(best[i]-p.x[i]) + a2*(r.globalBestX[i]-p.x[i]))
+		p.x[i] += p.velocity[i]
+		if p.x[i] < 0 || p.x[i] > 1 {
+			p.x[i] = math.Min(math.Max(p.x[i], 0), 1)
+			p.velocity[i] = 0
+		}
+	}
+}
+
+// decode maps a position to the parameters (inactive ones are nil).
+func (r *Solver) decode(x []float64) ([]*float64, error) {
+	params := make([]*float64, len(x))
+	for i, v := range r.problem.Params {
+		value := x[i]
+		if v.Range.AsCategoricalRange() == nil {
+			value = v.FromUnit(x[i])
+		}
+		params[i] = &value
+	}
+
+	if _, err := r.constraints.DropInactive(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,170 @@
+package pso
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+)
+
+func TestSolverMinimizesSphere(t *testing.T) {
+	for _, swarmSize := range []int{10, 30} {
+		options := DefaultOptions()
+		options.SwarmSize = swarmSize
+		if best := solvertest.RunSphere(t, NewSolverFactory(options), 250, 4); best > 1e-2 {
+			t.Fatalf("swarmSize=%v: the solver didn't converge: %v", swarmSize, best)
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	evaluations := solvertest.RunConditional(t, NewSolverFactory(DefaultOptions()), 1, 50, 4)
+	if best := solvertest.Best(evaluations); best > 0.1 {
+		t.Fatalf("the solver didn't find a good point: %v", best)
+	}
+}
+
+func TestClampResetsVelocity(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("line").
+		Continuous("x", 0.0, 1.0).
+		Continuous("y", 0.0, 1.0).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	// The attractions vanish because the particle is at both of the bests, so only the inertia moves it.
+	p := &particle{x: []float64{0.9, 0.5}, velocity: []float64{1, 0.1}, best: []float64{0.9, 0.5}}
+	solver.globalBestX = []float64{0.9, 0.5}
+	solver.move(p)
+
+	if p.x[0] != 1 || p.velocity[0] != 0 {
+		t.Errorf("the coordinate leaving the box should be clipped with zero velocity: x=%v, v=%v", p.x[0], p.velocity[0])
+	}
+	if expected := 0.1 * solver.chi; math.Abs(p.velocity[1]-expected) > 1e-12 || math.Abs(p.x[1]-(0.5+expected)) > 1e-12 {
+		t.Errorf("the coordinate inside the box should keep its velocity: x=%v, v=%v", p.x[1], p.velocity[1])
+	}
+}
+
+func TestCategoricalSwitching(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("choices").
+		Categorical("c", "a", "b", "c", "d").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The probability of keeping the current choice is E[1 / (1 + c1 r1 + c2 r2)] (by the midpoint rule),
+	// and the rest is split evenly between the personal best and the global best.
+	options := DefaultOptions()
+	const n = 200
+	stay := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			r1, r2 := (float64(i)+0.5)/n, (float64(j)+0.5)/n
+			stay += 1 / (1 + options.C1*r1 + options.C2*r2) / (n * n)
+		}
+	}
+
+	for _, c := range []struct {
+		switchProb float64
+		expected   []float64
+	}{
+		{0, []float64{stay, (1 - stay) / 2, (1 - stay) / 2, 0}},
+		{1, []float64{0.25, 0.25, 0.25, 0.25}},
+	} {
+		options.SwitchProb = c.switchProb
+		s, err := NewSolverFactory(options).CreateSolver(0, *spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		solver := s.(*Solver)
+		solver.globalBestX = []float64{2}
+
+		const trials = 20000
+		counts := make([]float64, 4)
+		for i := 0; i < trials; i++ {
+			p := &particle{x: []float64{0}, velocity: []float64{0}, best: []float64{1}}
+			solver.move(p)
+			counts[int(p.x[0])]++
+		}
+		for k := range counts {
+			if math.Abs(counts[k]/trials-c.expected[k]) > 0.02 {
+				t.Errorf("switchProb=%v: expected frequencies %v, got %v", c.switchProb, c.expected, counts)
+				break
+			}
+		}
+	}
+}
+
+func TestMoreConcurrentAsksThanParticles(t *testing.T) {
+	spec := solvertest.SphereSpec(t)
+	options := DefaultOptions()
+	options.SwarmSize = 2
+	solver, err := NewSolverFactory(options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No trials are told, so the particles can't move yet.
+	var idg kurobako.TrialIDGenerator
+	seen := map[float64]bool{}
+	for i := 0; i < 3*options.SwarmSize; i++ {
+		trial, err := solver.Ask(&idg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[*trial.Params[0]] {
+			t.Fatalf("the same position is asked again: %v", *trial.Params[0])
+		}
+		seen[*trial.Params[0]] = true
+	}
+}
+
+func TestStaleResultUpdatesOnlyGlobalBest(t *testing.T) {
+	spec := solvertest.SphereSpec(t)
+	options := DefaultOptions()
+	options.SwarmSize = 1
+	s, err := NewSolverFactory(options).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	// The second ask replaces the particle because the first one hasn't been told yet.
+	var idg kurobako.TrialIDGenerator
+	old, err := solver.Ask(&idg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldX := solver.pending[old.TrialID].x
+	current, err := solver.Ask(&idg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: old.TrialID, Values: []float64{0}}); err != nil {
+		t.Fatal(err)
+	}
+	if solver.swarm[0].best != nil {
+		t.Fatalf("the old position becomes the personal best of the new particle: %v", solver.swarm[0].best)
+	}
+	if solver.globalBest != 0 || solver.globalBestX[0] != oldX[0] {
+		t.Fatalf("the old position doesn't become the global best: %v (%v)", solver.globalBestX, solver.globalBest)
+	}
+
+	if err := solver.Tell(kurobako.EvaluatedTrial{TrialID: current.TrialID, Values: []float64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if solver.swarm[0].best == nil || solver.swarm[0].bestFitness != 1 || solver.globalBest != 0 {
+		t.Fatalf("unexpected bests: personal=%v, global=%v", solver.swarm[0].bestFitness, solver.globalBest)
+	}
+}
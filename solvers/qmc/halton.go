@@ -0,0 +1,90 @@
+package qmc
+
+import (
+	"math/rand"
+)
+
+// haltonSequence generates the points of a (scrambled) Halton sequence.
+//
+// The j-th dimension is the radical inverse of the index in the base of the j-th prime number.
+type haltonSequence struct {
+	bases []int
+
+	// permutations[j][k] is the permutation applied to the k-th digit of the j-th dimension.
+	permutations [][][]int
+	rng          *rand.Rand
+	index        uint64
+}
+
+// newHaltonSequence creates a Halton sequence of the given dimension.
+//
+// If rng isn't nil, the sequence is scrambled by random digit permutations (that keep zero fixed)
+// chosen independently for each dimension and each digit position.
+func newHaltonSequence(dim int, rng *rand.Rand) *haltonSequence {
+	return &haltonSequence{
+		bases:        firstPrimes(dim),
+		permutations: make([][][]int, dim),
+		rng:          rng,
+		// The first point (all zeros) is skipped.
+		index: 1,
+	}
+}
+
+func (r *haltonSequence) next() []float64 {
+	point := make([]float64, len(r.bases))
+	for j, base := range r.bases {
+		point[j] = r.radicalInverse(j, base, r.index)
+	}
+	r.index++
+	return point
+}
+
+func (r *haltonSequence) radicalInverse(j int, base int, index uint64) float64 {
+	b := uint64(base)
+	scale := 1.0 / float64(base)
+	u := 0.0
+	for k := 0; index > 0; k++ {
+		digit := int(index % b)
+		if r.rng != nil {
+			digit = r.permutation(j, k)[digit]
+		}
+		u += float64(digit) * scale
+
+		index /= b
+		scale /= float64(base)
+	}
+	return u
+}
+
+func (r *haltonSequence) permutation(j int, k int) []int {
+	for len(r.permutations[j]) <= k {
+		base := r.bases[j]
+		permutation := make([]int, base)
+		for i, x := range r.rng.Perm(base - 1) {
+			permutation[i+1] = x + 1
+		}
+		r.permutations[j] = append(r.permutations[j], permutation)
+	}
+	return r.permutations[j][k]
+}
+
+// firstPrimes returns the first n prime numbers.
+func firstPrimes(n int) []int {
+	var primes []int
+	for x := 2; len(primes) < n; x++ {
+		isPrime := true
+		for _, p := range primes {
+			if p*p > x {
+				break
+			}
+			if x%p == 0 {
+				isPrime = false
+				break
+			}
+		}
+		if isPrime {
+			primes = append(primes, x)
+		}
+	}
+	return primes
+}
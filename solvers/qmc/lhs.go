@@ -0,0 +1,41 @@
+package qmc
+
+import (
+	"math/rand"
+)
+
+// latinHypercube generates the points of Latin hypercube designs of the given size.
+//
+// In each design, the projection of the points onto any dimension has exactly one point in each of
+// the size cells that equally divide the unit interval.
+// Once a design has been consumed, a new independent design is generated.
+type latinHypercube struct {
+	dim  int
+	size int
+	rng  *rand.Rand
+
+	// permutations[j][i] is the cell of the i-th point in the j-th dimension.
+	permutations [][]int
+	index        int
+}
+
+func newLatinHypercube(dim int, size int, rng *rand.Rand) *latinHypercube {
+	return &latinHypercube{dim: dim, size: size, rng: rng, index: size}
+}
+
+func (r *latinHypercube) next() []float64 {
+	if r.index == r.size {
+		r.permutations = make([][]int, r.dim)
+		for j := range r.permutations {
+			r.permutations[j] = r.rng.Perm(r.size)
+		}
+		r.index = 0
+	}
+
+	point := make([]float64, r.dim)
+	for j := range point {
+		point[j] = (float64(r.permutations[j][r.index]) + r.rng.Float64()) / float64(r.size)
+	}
+	r.index++
+	return point
+}
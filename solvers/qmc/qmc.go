@@ -0,0 +1,175 @@
+// This package provides solvers based on quasi-random (low-discrepancy) sequences.
+//
+// Each parameter is assigned a dimension of the unit hypercube, and the points of the sequence are mapped
+// to the parameters by kurobako.Var.FromUnit.
+// The solvers don't use the evaluation results, so they are useful as baselines that cover
+// the search space more evenly than random search for low budgets.
+package qmc
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Method is the kind of the quasi-random sequence.
+type Method int
+
+const (
+	// Sobol indicates the Sobol sequence.
+	Sobol Method = iota
+
+	// Halton indicates the Halton sequence.
+	Halton
+
+	// LatinHypercube indicates Latin hypercube sampling.
+	//
+	// This method requires Options.Budget.
+	LatinHypercube
+)
+
+// String returns the string representation of a Method value.
+func (r Method) String() string {
+	switch r {
+	case Sobol:
+		return "Sobol"
+	case Halton:
+		return "Halton"
+	case LatinHypercube:
+		return "Latin Hypercube"
+	default:
+		panic("unknown method")
+	}
+}
+
+// Options is the options of the quasi-random solvers.
+type Options struct {
+	// Method is the kind of the sequence.
+	Method Method
+
+	// Scramble indicates whether the Sobol or Halton sequence is randomized by the seed of CreateSolver.
+	//
+	// Scrambling keeps the low-discrepancy property of the sequence and makes runs with different seeds independent.
+	// Latin hypercube sampling is always randomized.
+	Scramble bool
+
+	// Budget is the number of the trials that are expected to be evaluated.
+	//
+	// This is the size of a Latin hypercube design (a new design is started once it has been consumed).
+	Budget int
+}
+
+// DefaultOptions returns the default options (i.e., scrambled Sobol sequence).
+func DefaultOptions() Options {
+	return Options{
+		Method:   Sobol,
+		Scramble: true,
+	}
+}
+
+// SolverFactory is a SolverFactory for the quasi-random solvers.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	if r.options.Method < Sobol || r.options.Method > LatinHypercube {
+		return nil, fmt.Errorf("unknown method: %d", r.options.Method)
+	}
+
+	name := r.options.Method.String()
+	if r.options.Scramble && r.options.Method != LatinHypercube {
+		name = "Scrambled " + name
+	}
+
+	spec := kurobako.NewSolverSpec(name)
+	if r.options.Method == LatinHypercube {
+		spec.Attrs["budget"] = strconv.Itoa(r.options.Budget)
+	}
+	spec.Capabilities = kurobako.AllCapabilities
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	for _, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	scrambler := rng
+	if !r.options.Scramble {
+		scrambler = nil
+	}
+
+	dim := len(problem.Params)
+	var seq sequence
+	switch r.options.Method {
+	case Sobol:
+		seq = newSobolSequence(dim, scrambler)
+	case Halton:
+		seq = newHaltonSequence(dim, scrambler)
+	case LatinHypercube:
+		if r.options.Budget <= 0 {
+			return nil, fmt.Errorf("latin hypercube sampling requires a positive budget: %d", r.options.Budget)
+		}
+		seq = newLatinHypercube(dim, r.options.Budget, rng)
+	default:
+		return nil, fmt.Errorf("unknown method: %d", r.options.Method)
+	}
+
+	return &Solver{problem, constraints, seq}, nil
+}
+
+type sequence interface {
+	// next returns the next point in the unit hypercube.
+	next() []float64
+}
+
+// Solver is a solver that asks the points of a quasi-random sequence.
+type Solver struct {
+	problem     kurobako.ProblemSpec
+	constraints *kurobako.CompiledConstraints
+	seq         sequence
+}
+
+// Ask maps the next point of the sequence to the parameters.
+//
+// The values of inactive conditional parameters are nil (their dimensions are just skipped).
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	point := r.seq.next()
+	for i, p := range r.problem.Params {
+		value := p.FromUnit(point[i])
+		trial.Params = append(trial.Params, &value)
+	}
+
+	if _, err := r.constraints.DropInactive(trial.Params); err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.NextStep = r.problem.Steps.Last()
+	return trial, nil
+}
+
+// Tell does nothing because the sequence doesn't depend on the evaluation results.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package qmc
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+)
+
+func TestSobolSequence(t *testing.T) {
+	seq := newSobolSequence(2, nil)
+	for _, expected := range [][]float64{{0, 0}, {0.5, 0.5}, {0.75, 0.25}, {0.25, 0.75}, {0.375, 0.375}} {
+		if point := seq.next(); !reflect.DeepEqual(point, expected) {
+			t.Fatalf("unexpected point: %v (expected %v)", point, expected)
+		}
+	}
+
+	// Each one-dimensional projection of the first 2^k points is stratified, even after scrambling and
+	// even for the dimensions that aren't covered by the table of the initial direction numbers.
+	checkStratified(t, newSobolSequence(40, nil), 40, 256)
+	checkStratified(t, newSobolSequence(40, rand.New(rand.NewSource(1))), 40, 256)
+
+	// The first two dimensions form a (0, m, 2)-net.
+	seq = newSobolSequence(2, rand.New(rand.NewSource(2)))
+	var points [][]float64
+	for i := 0; i < 64; i++ {
+		points = append(points, seq.next())
+	}
+	for k := 0; k <= 6; k++ {
+		seen := map[[2]int]bool{}
+		for _, p := range points {
+			cell := [2]int{int(p[0] * float64(int(1)<<uint(k))), int(p[1] * float64(int(1)<<uint(6-k)))}
+			if seen[cell] {
+				t.Fatalf("the elementary interval %v (k=%d) has multiple points", cell, k)
+			}
+			seen[cell] = true
+		}
+	}
+}
+
+func TestPrimitivePolynomials(t *testing.T) {
+	// Joe and Kuo's table starts with the following (degree, coefficients) pairs.
+	expected := []primitivePolynomial{{1, 0}, {2, 1}, {3, 1}, {3, 2}, {4, 1}, {4, 4}, {5, 2}, {5, 4}, {5, 7}}
+	if actual := primitivePolynomials(len(expected)); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected polynomials: %v", actual)
+	}
+}
+
+func TestHaltonSequence(t *testing.T) {
+	seq := newHaltonSequence(2, nil)
+	for _, expected := range [][]float64{{1.0 / 2, 1.0 / 3}, {1.0 / 4, 2.0 / 3}, {3.0 / 4, 1.0 / 9}} {
+		point := seq.next()
+		for j := range point {
+			if math.Abs(point[j]-expected[j]) > 1e-12 {
+				t.Fatalf("unexpected point: %v (expected %v)", point, expected)
+			}
+		}
+	}
+
+	// Scrambling keeps the stratification of the first base^k points (excluding zero).
+	seq = newHaltonSequence(3, rand.New(rand.NewSource(1)))
+	seen := map[int]bool{}
+	for i := 1; i < 25; i++ {
+		cell := int(seq.next()[2] * 25)
+		if seen[cell] || cell == 0 {
+			t.Fatalf("unexpected cell: %d", cell)
+		}
+		seen[cell] = true
+	}
+}
+
+func TestLatinHypercube(t *testing.T) {
+	checkStratified(t, newLatinHypercube(5, 20, rand.New(rand.NewSource(1))), 5, 20)
+}
+
+func TestSolver(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("foo").
+		Categorical("optimizer", "sgd", "adam").
+		Continuous("lr", 1e-4, 1.0).Log().If(kurobako.When("optimizer").Eq("adam")).
+		Discrete("layers", 1, 4).
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []Method{Sobol, Halton, LatinHypercube} {
+		options := DefaultOptions()
+		options.Method = method
+		options.Budget = 10
+		factory := NewSolverFactory(options)
+
+		var trials [2][]kurobako.NextTrial
+		for i := range trials {
+			solver, err := factory.CreateSolver(7, *spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var idg kurobako.TrialIDGenerator
+			for j := 0; j < 20; j++ {
+				trial, err := solver.Ask(&idg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := spec.CheckTrialParams(trial.Params); err != nil {
+					t.Fatalf("%v: %v", method, err)
+				}
+				trials[i] = append(trials[i], trial)
+			}
+		}
+		if !reflect.DeepEqual(trials[0], trials[1]) {
+			t.Fatalf("%v: the same seed should generate the same trials", method)
+		}
+	}
+
+	options := DefaultOptions()
+	options.Method = LatinHypercube
+	if _, err := NewSolverFactory(options).CreateSolver(0, *spec); err == nil {
+		t.Fatal("expected a budget error")
+	}
+
+	options.Method = LatinHypercube + 1
+	if _, err := NewSolverFactory(options).Specification(); err == nil {
+		t.Fatal("expected an unknown method error")
+	}
+	if _, err := NewSolverFactory(options).CreateSolver(0, *spec); err == nil {
+		t.Fatal("expected an unknown method error")
+	}
+}
+
+func checkStratified(t *testing.T, seq sequence, dim int, n int) {
+	var points [][]float64
+	for i := 0; i < n; i++ {
+		points = append(points, seq.next())
+	}
+
+	for j := 0; j < dim; j++ {
+		seen := make([]bool, n)
+		for _, p := range points {
+			cell := int(p[j] * float64(n))
+			if seen[cell] {
+				t.Fatalf("the cell %d of the dimension %d has multiple points", cell, j)
+			}
+			seen[cell] = true
+		}
+	}
+}
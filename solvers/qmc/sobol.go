@@ -0,0 +1,241 @@
+package qmc
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// sobolBits is the number of the bits of the points of the Sobol sequence.
+const sobolBits = 32
+
+// sobolInitialNumbers is the initial direction numbers of the second and the following dimensions
+// taken from Joe and Kuo's "new-joe-kuo-6.21201" table.
+//
+// The i-th entry corresponds to the i-th primitive polynomial enumerated by primitivePolynomials.
+var sobolInitialNumbers = [][]uint32{
+	{1},
+	{1, 3},
+	{1, 3, 1},
+	{1, 1, 1},
+	{1, 1, 3, 3},
+	{1, 3, 5, 13},
+	{1, 1, 5, 5, 17},
+	{1, 1, 5, 5, 5},
+	{1, 1, 7, 11, 19},
+	{1, 1, 5, 1, 1},
+	{1, 1, 1, 3, 11},
+	{1, 3, 5, 5, 31},
+	{1, 3, 3, 9, 7, 49},
+	{1, 1, 1, 15, 21, 21},
+	{1, 3, 1, 13, 27, 49},
+	{1, 1, 1, 15, 7, 5},
+	{1, 3, 1, 15, 13, 25},
+	{1, 1, 5, 5, 19, 61},
+	{1, 3, 7, 11, 23, 15, 103},
+	{1, 3, 7, 13, 13, 15, 69},
+}
+
+// sobolSequence generates the points of a (scrambled) Sobol sequence in the Gray code order.
+type sobolSequence struct {
+	directions [][sobolBits]uint32
+	shifts     []uint32
+	state      []uint32
+	index      uint64
+}
+
+// newSobolSequence creates a Sobol sequence of the given dimension.
+//
+// If rng isn't nil, the sequence is scrambled by a random linear matrix scramble and a random digital shift.
+func newSobolSequence(dim int, rng *rand.Rand) *sobolSequence {
+	directions := sobolDirections(dim)
+	shifts := make([]uint32, dim)
+	if rng != nil {
+		for j := range directions {
+			directions[j] = scrambleDirections(directions[j], rng)
+			shifts[j] = rng.Uint32()
+		}
+	}
+	return &sobolSequence{directions, shifts, make([]uint32, dim), 0}
+}
+
+func (r *sobolSequence) next() []float64 {
+	if r.index > 0 {
+		// The point differs from the previous one only in the direction of the lowest zero bit of the index.
+		c := bits.TrailingZeros64(r.index)
+		if c >= sobolBits {
+			// The sequence is exhausted, so it is restarted.
+			c = 0
+			r.index = 0
+			for j := range r.state {
+				r.state[j] = 0
+			}
+		} else {
+			for j := range r.state {
+				r.state[j] ^= r.directions[j][c]
+			}
+		}
+	}
+	r.index++
+
+	point := make([]float64, len(r.state))
+	for j, x := range r.state {
+		point[j] = float64(x^r.shifts[j]) / (1 << sobolBits)
+	}
+	return point
+}
+
+// sobolDirections returns the direction numbers (MSB first) of the given number of dimensions.
+//
+// Dimensions that aren't covered by sobolInitialNumbers use pseudo-random (but fixed) initial direction numbers.
+// They are still valid Sobol sequences, but the quality of their projections hasn't been optimized.
+func sobolDirections(dim int) [][sobolBits]uint32 {
+	directions := make([][sobolBits]uint32, dim)
+	if dim == 0 {
+		return directions
+	}
+
+	for k := 0; k < sobolBits; k++ {
+		directions[0][k] = 1 << (sobolBits - 1 - k)
+	}
+
+	rng := rand.New(rand.NewSource(0))
+	polynomials := primitivePolynomials(dim - 1)
+	for j := 1; j < dim; j++ {
+		p := polynomials[j-1]
+		s := p.degree
+
+		var m []uint32
+		if j-1 < len(sobolInitialNumbers) {
+			m = sobolInitialNumbers[j-1]
+		} else {
+			for k := 0; k < s; k++ {
+				m = append(m, (rng.Uint32()%(1<<uint(k+1)))|1)
+			}
+		}
+
+		v := &directions[j]
+		for k := 0; k < sobolBits; k++ {
+			if k < s {
+				v[k] = m[k] << uint(sobolBits-1-k)
+				continue
+			}
+
+			v[k] = v[k-s] ^ (v[k-s] >> uint(s))
+			for i := 1; i < s; i++ {
+				if (p.coefficients>>uint(s-1-i))&1 == 1 {
+					v[k] ^= v[k-i]
+				}
+			}
+		}
+	}
+	return directions
+}
+
+// scrambleDirections applies a random lower triangular binary matrix (with the unit diagonal) to the direction numbers.
+//
+// The scrambling preserves the net properties of the sequence.
+func scrambleDirections(v [sobolBits]uint32, rng *rand.Rand) [sobolBits]uint32 {
+	var rows [sobolBits]uint32
+	for i := range rows {
+		// The i-th row has random bits in the more significant positions than i, and the bit of i itself.
+		upper := ^uint32(0) << uint(sobolBits-i)
+		if i == 0 {
+			upper = 0
+		}
+		rows[i] = (rng.Uint32() & upper) | (1 << uint(sobolBits-1-i))
+	}
+
+	var scrambled [sobolBits]uint32
+	for k, x := range v {
+		for i, row := range rows {
+			if bits.OnesCount32(row&x)%2 == 1 {
+				scrambled[k] |= 1 << uint(sobolBits-1-i)
+			}
+		}
+	}
+	return scrambled
+}
+
+type primitivePolynomial struct {
+	degree int
+
+	// coefficients is the coefficients of the inner terms (i.e., excluding the highest and the lowest terms).
+	coefficients uint64
+}
+
+// primitivePolynomials enumerates the given number of primitive polynomials over GF(2) in the order of
+// their degrees and coefficients (i.e., the same order as Joe and Kuo's table).
+func primitivePolynomials(n int) []primitivePolynomial {
+	var polynomials []primitivePolynomial
+	for s := 1; len(polynomials) < n; s++ {
+		for a := uint64(0); a < 1<<uint(s-1) && len(polynomials) < n; a++ {
+			p := primitivePolynomial{s, a}
+			if p.isPrimitive() {
+				polynomials = append(polynomials, p)
+			}
+		}
+	}
+	return polynomials
+}
+
+// isPrimitive returns whether the order of x modulo the polynomial is 2^degree - 1.
+func (r primitivePolynomial) isPrimitive() bool {
+	if r.degree == 1 {
+		return true
+	}
+
+	order := uint64(1)<<uint(r.degree) - 1
+	if r.powX(order) != 1 {
+		return false
+	}
+	for _, q := range primeFactors(order) {
+		if r.powX(order/q) == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// powX returns x^e modulo the polynomial.
+func (r primitivePolynomial) powX(e uint64) uint64 {
+	result := uint64(1)
+	base := uint64(2)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = r.mulMod(result, base)
+		}
+		base = r.mulMod(base, base)
+	}
+	return result
+}
+
+func (r primitivePolynomial) mulMod(a uint64, b uint64) uint64 {
+	p := uint64(1)<<uint(r.degree) | r.coefficients<<1 | 1
+	var result uint64
+	for ; b > 0; b >>= 1 {
+		if b&1 == 1 {
+			result ^= a
+		}
+		a <<= 1
+		if (a>>uint(r.degree))&1 == 1 {
+			a ^= p
+		}
+	}
+	return result
+}
+
+func primeFactors(n uint64) []uint64 {
+	var factors []uint64
+	for d := uint64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			factors = append(factors, d)
+			for n%d == 0 {
+				n /= d
+			}
+		}
+	}
+	if n > 1 {
+		factors = append(factors, n)
+	}
+	return factors
+}
@@ -0,0 +1,200 @@
+package smac
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// forest is a random forest regression model.
+//
+// The features are either numerical (split by thresholds) or categorical (split by equality to a category).
+type forest struct {
+	trees []*tree
+}
+
+type forestOptions struct {
+	trees           int
+	minSamplesSplit int
+
+	// maxFeatures is the number of the features considered at each split.
+	maxFeatures int
+}
+
+type tree struct {
+	nodes []node
+}
+
+type node struct {
+	// leaf indicates whether the node is a leaf (and the other fields except for mean and variance are unused).
+	leaf     bool
+	mean     float64
+	variance float64
+
+	feature     int
+	categorical bool
+
+	// threshold is the upper bound of the left child (numerical) or the category of the left child (categorical).
+	threshold float64
+	left      int
+	right     int
+}
+
+// fitForest fits a random forest where each tree is fitted to a bootstrap sample of the data.
+func fitForest(xs [][]float64, ys []float64, categorical []bool, options forestOptions, rng *rand.Rand) *forest {
+	f := &forest{}
+	for k := 0; k < options.trees; k++ {
+		indices := make([]int, len(xs))
+		for i := range indices {
+			indices[i] = rng.Intn(len(xs))
+		}
+		t := &tree{}
+		t.grow(xs, ys, categorical, indices, options, rng)
+		f.trees = append(f.trees, t)
+	}
+	return f
+}
+
+// predict returns the mean and the variance of the prediction.
+//
+// The variance is the law-of-total-variance combination of the variances within and between the trees.
+func (r *forest) predict(x []float64) (float64, float64) {
+	mean, second := 0.0, 0.0
+	for _, t := range r.trees {
+		m, v := t.predict(x)
+		mean += m
+		second += v + m*m
+	}
+	n := float64(len(r.trees))
+	mean /= n
+	return mean, math.Max(second/n-mean*mean, 0)
+}
+
+func (r *tree) predict(x []float64) (float64, float64) {
+	n := r.nodes[0]
+	for !n.leaf {
+		var left bool
+		if n.categorical {
+			left = x[n.feature] == n.threshold
+		} else {
+			left = x[n.feature] <= n.threshold
+		}
+		if left {
+			n = r.nodes[n.left]
+		} else {
+			n = r.nodes[n.right]
+		}
+	}
+	return n.mean, n.variance
+}
+
+// grow adds the subtree for the given samples, and returns the index of its root.
+func (r *tree) grow(xs [][]float64, ys []float64, categorical []bool, indices []int, options forestOptions, rng *rand.Rand) int {
+	index := len(r.nodes)
+	mean, variance := meanAndVariance(ys, indices)
+	r.nodes = append(r.nodes, node{leaf: true, mean: mean, variance: variance})
+	if len(indices) < options.minSamplesSplit || variance == 0 {
+		return index
+	}
+
+	bestFeature, bestThreshold, bestScore := -1, 0.0, math.Inf(0)
+	features := rng.Perm(len(categorical))
+	if len(features) > options.maxFeatures {
+		features = features[:options.maxFeatures]
+	}
+	for _, j := range features {
+		threshold, score, ok := bestSplit(xs, ys, indices, j, categorical[j])
+		if ok && score < bestScore {
+			bestFeature, bestThreshold, bestScore = j, threshold, score
+		}
+	}
+	if bestFeature < 0 {
+		return index
+	}
+
+	var left, right []int
+	for _, i := range indices {
+		x := xs[i][bestFeature]
+		if (categorical[bestFeature] && x == bestThreshold) || (!categorical[bestFeature] && x <= bestThreshold) {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+
+	n := node{feature: bestFeature, categorical: categorical[bestFeature], threshold: bestThreshold}
+	n.left = r.grow(xs, ys, categorical, left, options, rng)
+	n.right = r.grow(xs, ys, categorical, right, options, rng)
+	r.nodes[index] = n
+	return index
+}
+
+// bestSplit returns the split of the given feature that minimizes the sum of the squared errors of the children.
+func bestSplit(xs [][]float64, ys []float64, indices []int, feature int, categorical bool) (float64, float64, bool) {
+	sorted := append([]int(nil), indices...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return xs[sorted[a]][feature] < xs[sorted[b]][feature]
+	})
+
+	total, totalSquared := 0.0, 0.0
+	for _, i := range sorted {
+		total += ys[i]
+		totalSquared += ys[i] * ys[i]
+	}
+	n := float64(len(sorted))
+	sse := func(sum, squared, count float64) float64 {
+		if count == 0 {
+			return 0
+		}
+		return squared - sum*sum/count
+	}
+
+	bestThreshold, bestScore, found := 0.0, math.Inf(0), false
+	sum, squared := 0.0, 0.0
+	for k := 0; k < len(sorted); {
+		// The samples that have the same value of the feature.
+		value := xs[sorted[k]][feature]
+		groupSum, groupSquared, count := 0.0, 0.0, 0.0
+		for ; k < len(sorted) && xs[sorted[k]][feature] == value; k++ {
+			groupSum += ys[sorted[k]]
+			groupSquared += ys[sorted[k]] * ys[sorted[k]]
+			count++
+		}
+
+		if categorical {
+			if count < n {
+				score := sse(groupSum, groupSquared, count) + sse(total-groupSum, totalSquared-groupSquared, n-count)
+				if score < bestScore {
+					bestThreshold, bestScore, found = value, score, true
+				}
+			}
+			continue
+		}
+
+		sum += groupSum
+		squared += groupSquared
+		left := float64(k)
+		if k < len(sorted) {
+			score := sse(sum, squared, left) + sse(total-sum, totalSquared-squared, n-left)
+			if score < bestScore {
+				next := xs[sorted[k]][feature]
+				bestThreshold, bestScore, found = (value+next)/2, score, true
+			}
+		}
+	}
+	return bestThreshold, bestScore, found
+}
+
+func meanAndVariance(ys []float64, indices []int) (float64, float64) {
+	mean := 0.0
+	for _, i := range indices {
+		mean += ys[i]
+	}
+	mean /= float64(len(indices))
+
+	variance := 0.0
+	for _, i := range indices {
+		variance += (ys[i] - mean) * (ys[i] - mean)
+	}
+	return mean, variance / float64(len(indices))
+}
@@ -0,0 +1,418 @@
+// This package provides a Bayesian optimization solver that uses a random forest as the surrogate model (SMAC-style).
+//
+// Each trial is encoded into features: numerical parameters are mapped by kurobako.Var.ToUnit,
+// categorical parameters are their choice indices, and inactive parameters are imputed by -1
+// (a value that no active parameter takes). The random forest predicts the mean and the variance of the objective,
+// and the next trial maximizes the expected improvement found by local search over the mixed space
+// (started from the best observed trials and random configurations).
+//
+// Neighbors in the local search are always valid configurations of the constraint graph: when a parameter
+// is changed, the parameters that become active are sampled at random, and the ones that become inactive are removed.
+// A random configuration is interleaved with the probability Options.RandomProb.
+//
+// See "Sequential Model-Based Optimization for General Algorithm Configuration" (Hutter et al., 2011) for the details.
+package smac
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sile/kurobako-go"
+)
+
+// Options is the options of the SMAC solver.
+type Options struct {
+	// InitialTrials is the number of the random trials evaluated before the model is used.
+	InitialTrials int
+
+	// Trees is the number of the trees of the random forest.
+	Trees int
+
+	// MinSamplesSplit is the minimum number of the samples required to split a node.
+	MinSamplesSplit int
+
+	// RandomProb is the probability that a random configuration is asked instead of the model's suggestion.
+	RandomProb float64
+
+	// LocalSearches is the number of the starting points of the local search.
+	//
+	// Half of them are the best observed trials and the others are random configurations.
+	LocalSearches int
+}
+
+// DefaultOptions returns the default options of the SMAC solver.
+func DefaultOptions() Options {
+	return Options{
+		InitialTrials:   10,
+		Trees:           10,
+		MinSamplesSplit: 3,
+		RandomProb:      0.2,
+		LocalSearches:   10,
+	}
+}
+
+const (
+	// inactiveFeature is the imputed value of an inactive parameter.
+	inactiveFeature = -1
+
+	// neighborScale is the standard deviation of the perturbation of a numerical parameter in the unit interval.
+	neighborScale = 0.2
+
+	// neighborsPerParam is the number of the neighbors generated for each numerical parameter in a local search step.
+	neighborsPerParam = 4
+
+	maxLocalSearchSteps = 100
+)
+
+// SolverFactory is a SolverFactory for the SMAC solver.
+type SolverFactory struct {
+	options Options
+}
+
+// NewSolverFactory creates a new SolverFactory instance.
+func NewSolverFactory(options Options) *SolverFactory {
+	return &SolverFactory{options}
+}
+
+// Specification returns the specification of the solver.
+func (r *SolverFactory) Specification() (*kurobako.SolverSpec, error) {
+	spec := kurobako.NewSolverSpec("SMAC")
+	spec.Attrs["trees"] = strconv.Itoa(r.options.Trees)
+	spec.Capabilities = kurobako.UniformContinuous |
+		kurobako.UniformDiscrete |
+		kurobako.LogUniformContinuous |
+		kurobako.LogUniformDiscrete |
+		kurobako.Categorical |
+		kurobako.Conditional |
+		kurobako.Concurrent
+	return &spec, nil
+}
+
+// CreateSolver creates a new solver instance.
+func (r *SolverFactory) CreateSolver(seed int64, problem kurobako.ProblemSpec) (kurobako.Solver, error) {
+	options := r.options
+	if options.Trees < 1 || options.MinSamplesSplit < 2 || options.LocalSearches < 1 {
+		return nil, fmt.Errorf("invalid number of trees (%d), min samples split (%d) or local searches (%d)",
+			options.Trees, options.MinSamplesSplit, options.LocalSearches)
+	}
+	if options.RandomProb < 0 || options.RandomProb > 1 {
+		return nil, fmt.Errorf("random probability must be in [0, 1]: %v", options.RandomProb)
+	}
+
+	categorical := make([]bool, len(problem.Params))
+	for i, v := range problem.Params {
+		if !v.Range.IsBounded() {
+			return nil, fmt.Errorf("param %q has an unbounded range", v.Name)
+		}
+		categorical[i] = v.Range.AsCategoricalRange() != nil
+	}
+
+	constraints, err := kurobako.CompileConstraints(problem.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	maxFeatures := int(math.Ceil(float64(len(problem.Params)) * 5 / 6))
+	return &Solver{
+		options:     options,
+		problem:     problem,
+		constraints: constraints,
+		rng:         rand.New(rand.NewSource(seed)),
+		categorical: categorical,
+		forestOptions: forestOptions{
+			trees:           options.Trees,
+			minSamplesSplit: options.MinSamplesSplit,
+			maxFeatures:     maxFeatures,
+		},
+		pending: map[uint64][]*float64{},
+		seen:    map[string]bool{},
+	}, nil
+}
+
+type observation struct {
+	params []*float64
+	value  float64
+}
+
+// Solver is the SMAC solver.
+type Solver struct {
+	options       Options
+	problem       kurobako.ProblemSpec
+	constraints   *kurobako.CompiledConstraints
+	rng           *rand.Rand
+	categorical   []bool
+	forestOptions forestOptions
+
+	observations []observation
+
+	// pending is the params of the trials that have been asked but not told yet (keyed by trial IDs).
+	pending map[uint64][]*float64
+
+	// seen is the keys of the params that have been asked.
+	seen map[string]bool
+}
+
+// Ask returns a random configuration or the maximizer of the expected improvement found by local search.
+//
+// If the suggestion has already been asked, a random configuration is returned instead.
+func (r *Solver) Ask(idg *kurobako.TrialIDGenerator) (kurobako.NextTrial, error) {
+	var trial kurobako.NextTrial
+
+	var params []*float64
+	var err error
+	if len(r.observations) < r.options.InitialTrials || len(r.observations) < 2 || r.rng.Float64() < r.options.RandomProb {
+		params, err = r.randomConfiguration()
+	} else {
+		params, err = r.suggest()
+		if err == nil && r.seen[key(params)] {
+			params, err = r.randomConfiguration()
+		}
+	}
+	if err != nil {
+		return trial, err
+	}
+
+	trial.TrialID = idg.Generate()
+	trial.Params = params
+	trial.NextStep = r.problem.Steps.Last()
+	r.pending[trial.TrialID] = params
+	r.seen[key(params)] = true
+	return trial, nil
+}
+
+// Tell records the result of a trial.
+//
+// Unevalable trials (and non-finite values) are regarded as having the worst value observed so far.
+func (r *Solver) Tell(trial kurobako.EvaluatedTrial) error {
+	params, ok := r.pending[trial.TrialID]
+	if !ok {
+		return fmt.Errorf("unknown trial: %d", trial.TrialID)
+	}
+	delete(r.pending, trial.TrialID)
+
+	r.observe(params, trial)
+	return nil
+}
+
+// Observe records the result of a trial that has been asked by another solver (e.g., a sibling in a portfolio).
+//
+// The params aren't asked by this solver afterwards.
+func (r *Solver) Observe(params []*float64, trial kurobako.EvaluatedTrial) error {
+	if len(params) != len(r.problem.Params) {
+		return fmt.Errorf("expected %d params, but got %d", len(r.problem.Params), len(params))
+	}
+	r.seen[key(params)] = true
+	r.observe(params, trial)
+	return nil
+}
+
+func (r *Solver) observe(params []*float64, trial kurobako.EvaluatedTrial) {
+	value := math.NaN()
+	if len(trial.Values) > 0 {
+		value = trial.Values[0]
+	}
+	r.observations = append(r.observations, observation{params, value})
+}
+
+// suggest fits the random forest and runs local searches on the expected improvement.
+func (r *Solver) suggest() ([]*float64, error) {
+	var xs [][]float64
+	var ys []float64
+	worst, best := math.Inf(-1), math.Inf(0)
+	for _, o := range r.observations {
+		if !math.IsNaN(o.value) && !math.IsInf(o.value, 0) {
+			worst = math.Max(worst, o.value)
+			best = math.Min(best, o.value)
+		}
+	}
+	if math.IsInf(best, 0) {
+		// No trials have been evaluable.
+		return r.randomConfiguration()
+	}
+	for _, o := range r.observations {
+		y := o.value
+		if math.IsNaN(y) || math.IsInf(y, 0) {
+			y = worst
+		}
+		xs = append(xs, r.encode(o.params))
+		ys = append(ys, y)
+	}
+
+	model := fitForest(xs, ys, r.categorical, r.forestOptions, r.rng)
+	ei := func(params []*float64) float64 {
+		mean, variance := model.predict(r.encode(params))
+		return expectedImprovement(best, mean, math.Sqrt(variance))
+	}
+
+	// The starting points: the best observed trials and random configurations.
+	sorted := append([]observation(nil), r.observations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i].value, sorted[j].value)
+	})
+	var starts [][]*float64
+	for i := 0; i < len(sorted) && len(starts) < r.options.LocalSearches/2; i++ {
+		starts = append(starts, sorted[i].params)
+	}
+	for len(starts) < r.options.LocalSearches {
+		params, err := r.randomConfiguration()
+		if err != nil {
+			return nil, err
+		}
+		starts = append(starts, params)
+	}
+
+	var bestParams []*float64
+	bestValue := math.Inf(-1)
+	for _, start := range starts {
+		params, value, err := r.localSearch(start, ei)
+		if err != nil {
+			return nil, err
+		}
+		if value > bestValue {
+			bestParams, bestValue = params, value
+		}
+	}
+	return bestParams, nil
+}
+
+// localSearch moves to the best neighbor while it improves the acquisition value.
+func (r *Solver) localSearch(params []*float64, acquisition func([]*float64) float64) ([]*float64, float64, error) {
+	value := acquisition(params)
+	for step := 0; step < maxLocalSearchSteps; step++ {
+		neighbors, err := r.neighbors(params)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		improved := false
+		for _, neighbor := range neighbors {
+			if v := acquisition(neighbor); v > value {
+				params, value, improved = neighbor, v, true
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return params, value, nil
+}
+
+// neighbors returns the configurations that differ from the given one in an active parameter
+// (and in the parameters whose activity is changed by it).
+func (r *Solver) neighbors(params []*float64) ([][]*float64, error) {
+	var neighbors [][]*float64
+	for i, v := range r.problem.Params {
+		if params[i] == nil {
+			continue
+		}
+
+		var values []float64
+		switch {
+		case v.Range.AsCategoricalRange() != nil:
+			for c := range v.Range.AsCategoricalRange().Choices {
+				if float64(c) != *params[i] {
+					values = append(values, float64(c))
+				}
+			}
+		case v.Range.AsDiscreteRange() != nil && v.Range.High()-v.Range.Low() <= neighborsPerParam+1:
+			for x := v.Range.Low(); x < v.Range.High(); x++ {
+				if x != *params[i] {
+					values = append(values, x)
+				}
+			}
+		default:
+			u := v.ToUnit(*params[i])
+			for k := 0; k < neighborsPerParam; k++ {
+				x := v.FromUnit(u + neighborScale*r.rng.NormFloat64())
+				if x != *params[i] {
+					values = append(values, x)
+				}
+			}
+		}
+
+		for _, x := range values {
+			neighbor := append([]*float64(nil), params...)
+			value := x
+			neighbor[i] = &value
+			neighbor, err := r.complete(neighbor)
+			if err != nil {
+				return nil, err
+			}
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, nil
+}
+
+func (r *Solver) randomConfiguration() ([]*float64, error) {
+	return r.complete(make([]*float64, len(r.problem.Params)))
+}
+
+// complete samples the missing parameters at random and removes the inactive ones.
+//
+// The activity of each parameter is determined by the preceding parameters, so the parameters that become active
+// get random values and the others keep their values.
+func (r *Solver) complete(params []*float64) ([]*float64, error) {
+	for i, v := range r.problem.Params {
+		if params[i] == nil {
+			value := v.FromUnit(r.rng.Float64())
+			params[i] = &value
+		}
+	}
+
+	if _, err := r.constraints.DropInactive(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func (r *Solver) encode(params []*float64) []float64 {
+	x := make([]float64, len(params))
+	for i, v := range r.problem.Params {
+		switch {
+		case params[i] == nil:
+			x[i] = inactiveFeature
+		case r.categorical[i]:
+			x[i] = *params[i]
+		default:
+			x[i] = v.ToUnit(*params[i])
+		}
+	}
+	return x
+}
+
+func expectedImprovement(best, mean, sd float64) float64 {
+	if sd <= 0 {
+		return math.Max(best-mean, 0)
+	}
+	z := (best - mean) / sd
+	cdf := 0.5 * math.Erfc(-z/math.Sqrt2)
+	pdf := math.Exp(-0.5*z*z) / math.Sqrt(2*math.Pi)
+	return sd*pdf + (best-mean)*cdf
+}
+
+// less compares objective values (NaN is regarded as the worst).
+func less(a, b float64) bool {
+	if math.IsNaN(b) {
+		return !math.IsNaN(a)
+	}
+	return a < b
+}
+
+// key returns a string that identifies params.
+func key(params []*float64) string {
+	var b strings.Builder
+	for _, p := range params {
+		if p == nil {
+			b.WriteString("-,")
+		} else {
+			b.WriteString(strconv.FormatFloat(*p, 'g', -1, 64))
+			b.WriteByte(',')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package smac
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/sile/kurobako-go"
+	"github.com/sile/kurobako-go/internal/solvertest"
+	"github.com/sile/kurobako-go/solvers/portfolio"
+)
+
+var _ portfolio.Observer = (*Solver)(nil)
+
+func TestForest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	f := func(x []float64) float64 {
+		y := 10 * x[0]
+		if x[1] == 2 {
+			y += 5
+		}
+		return y
+	}
+
+	var xs [][]float64
+	var ys []float64
+	for i := 0; i < 200; i++ {
+		x := []float64{rng.Float64(), float64(rng.Intn(3))}
+		xs = append(xs, x)
+		ys = append(ys, f(x))
+	}
+
+	options := forestOptions{trees: 10, minSamplesSplit: 3, maxFeatures: 2}
+	model := fitForest(xs, ys, []bool{false, true}, options, rng)
+	for i := 0; i < 100; i++ {
+		x := []float64{rng.Float64(), float64(rng.Intn(3))}
+		mean, variance := model.predict(x)
+		if math.Abs(mean-f(x)) > 1 || variance < 0 {
+			t.Fatalf("f(%v) = %v, but the prediction is %v (variance=%v)", x, f(x), mean, variance)
+		}
+	}
+}
+
+func TestSolverConditional(t *testing.T) {
+	evaluations := solvertest.RunConditional(t, NewSolverFactory(DefaultOptions()), 1, 50, 2)
+	if best := solvertest.Best(evaluations); best > 0.1 {
+		t.Fatalf("the solver didn't find a good point: %v", best)
+	}
+}
+
+func TestSolverObserve(t *testing.T) {
+	spec, err := kurobako.NewProblemSpecBuilder("choices").
+		Categorical("c", "a", "b").
+		Objective("v").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	c := 0.0
+	if err := solver.Observe([]*float64{&c}, kurobako.EvaluatedTrial{TrialID: 100, Values: []float64{1}, CurrentStep: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(solver.observations) != 1 || !solver.seen[key([]*float64{&c})] {
+		t.Fatalf("the observed trial isn't recorded: %v", solver.observations)
+	}
+
+	if err := solver.Observe(nil, kurobako.EvaluatedTrial{}); err == nil {
+		t.Fatal("params of a different problem should be rejected")
+	}
+}
+
+func TestNeighborsAreValid(t *testing.T) {
+	spec := solvertest.ConditionalSpec(t, 1)
+	s, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	for i := 0; i < 20; i++ {
+		params, err := solver.randomConfiguration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		neighbors, err := solver.neighbors(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, neighbor := range neighbors {
+			if err := spec.CheckTrialParams(neighbor); err != nil {
+				t.Fatalf("invalid neighbor %v of %v: %v", neighbor, params, err)
+			}
+			if key(neighbor) == key(params) {
+				t.Fatalf("a neighbor is the same as the origin: %v", params)
+			}
+
+			// Switching "kind" activates the subtree of the new choice.
+			if *neighbor[0] != *params[0] && *neighbor[0] == 1 && (neighbor[2] == nil || neighbor[3] == nil) {
+				t.Fatalf("the subtree of kind=b isn't sampled: %v", neighbor)
+			}
+		}
+	}
+}
+
+func TestInactiveImputation(t *testing.T) {
+	spec := solvertest.ConditionalSpec(t, 1)
+	s, err := NewSolverFactory(DefaultOptions()).CreateSolver(0, *spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := s.(*Solver)
+
+	a, b, x, n, m := 0.0, 1.0, 5.0, 1000.0, 1.0
+	inactive := solver.encode([]*float64{&b, nil, &n, &m})
+	active := solver.encode([]*float64{&a, &x, nil, nil})
+	if inactive[1] != inactiveFeature || active[1] != 1 || active[2] != inactiveFeature || inactive[2] != 1 {
+		t.Fatalf("unexpected features: %v, %v", inactive, active)
+	}
+
+	// The inactive samples are separated from the active ones by a single split, even at the lower bound.
+	xs := [][]float64{{inactiveFeature}, {inactiveFeature}, {0}, {0.5}}
+	ys := []float64{10, 10, 0, 1}
+	threshold, _, found := bestSplit(xs, ys, []int{0, 1, 2, 3}, 0, false)
+	if !found || threshold != float64(inactiveFeature)/2 {
+		t.Fatalf("unexpected split: %v (found=%v)", threshold, found)
+	}
+}
@@ -3,6 +3,8 @@ package kurobako
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 )
 
 // Steps represents a sequence of evaluable steps of a problem.
@@ -14,12 +16,15 @@ type Steps struct {
 }
 
 // NewSteps creates a new Steps instance.
+//
+// Only steps that are exactly 1, 2, ..., N are represented (and encoded to JSON) as the sequential steps N.
+// Other steps, including a single step other than 1 (e.g., []uint64{5}), are kept as they are.
 func NewSteps(steps []uint64) (*Steps, error) {
 	if len(steps) == 0 {
 		return nil, fmt.Errorf("empty steps isn't allowed")
 	}
 
-	isSequential := true
+	isSequential := steps[0] == 1
 	curr := steps[0]
 	for _, step := range steps[1:] {
 		if curr >= step {
@@ -37,11 +42,114 @@ func NewSteps(steps []uint64) (*Steps, error) {
 	return &Steps{false, steps}, nil
 }
 
+// NewGeometricSteps creates a new Steps instance that represents a geometric schedule such as Hyperband's rungs.
+//
+// The resulting steps are max, max/eta, max/eta^2, ... (rounded to integers) that are greater than min, and min itself.
+// For example, NewGeometricSteps(1, 81, 3) returns the steps 1, 3, 9, 27 and 81.
+func NewGeometricSteps(min uint64, max uint64, eta float64) (*Steps, error) {
+	if min == 0 || min > max {
+		return nil, fmt.Errorf("steps should satisfy 0 < min <= max: min=%v, max=%v", min, max)
+	}
+	if !(eta > 1.0) {
+		return nil, fmt.Errorf("eta should be greater than 1: eta=%v", eta)
+	}
+
+	var steps []uint64
+	for x := float64(max); ; x /= eta {
+		step := uint64(math.Round(x))
+		if step < min {
+			break
+		}
+		if len(steps) == 0 || steps[0] != step {
+			steps = append([]uint64{step}, steps...)
+		}
+	}
+	if steps[0] != min {
+		steps = append([]uint64{min}, steps...)
+	}
+	return NewSteps(steps)
+}
+
+// First returns the first step.
+func (r Steps) First() uint64 {
+	if r.isSequential {
+		return 1
+	}
+	return r.steps[0]
+}
+
 // Last returns the last step.
 func (r Steps) Last() uint64 {
 	return r.steps[len(r.steps)-1]
 }
 
+// Len returns the number of the steps.
+func (r Steps) Len() int {
+	if r.isSequential {
+		return int(r.Last())
+	}
+	return len(r.steps)
+}
+
+// Index returns the position of the given step in the sequence.
+//
+// If the sequence doesn't contain the step, this returns false as the second value.
+func (r Steps) Index(step uint64) (int, bool) {
+	if r.isSequential {
+		if step == 0 || step > r.Last() {
+			return 0, false
+		}
+		return int(step - 1), true
+	}
+
+	i := sort.Search(len(r.steps), func(i int) bool { return r.steps[i] >= step })
+	if i == len(r.steps) || r.steps[i] != step {
+		return 0, false
+	}
+	return i, true
+}
+
+// Contains returns whether the sequence contains the given step.
+func (r Steps) Contains(step uint64) bool {
+	_, ok := r.Index(step)
+	return ok
+}
+
+// Next returns the smallest step that is greater than the given step.
+//
+// If there is no such step, this returns false as the second value.
+func (r Steps) Next(step uint64) (uint64, bool) {
+	if step >= r.Last() {
+		return 0, false
+	}
+	if r.isSequential {
+		return step + 1, true
+	}
+
+	i := sort.Search(len(r.steps), func(i int) bool { return r.steps[i] > step })
+	return r.steps[i], true
+}
+
+// Rung returns the position of the largest step that is less than or equal to the given step.
+//
+// This can be used to bucket arbitrary steps into the rungs of a multi-fidelity schedule.
+// If the given step is less than the first step, this returns -1.
+func (r Steps) Rung(step uint64) int {
+	if r.isSequential {
+		if step > r.Last() {
+			step = r.Last()
+		}
+		return int(step) - 1
+	}
+
+	return sort.Search(len(r.steps), func(i int) bool { return r.steps[i] > step }) - 1
+}
+
+// Fidelity returns the given step as a fraction of the last step.
+func (r Steps) Fidelity(step uint64) float64 {
+	return float64(step) / float64(r.Last())
+}
+
 // AsSlice returns the steps as slice.
 func (r Steps) AsSlice() []uint64 {
 	if r.isSequential {
@@ -0,0 +1,111 @@
+package kurobako
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGeometricSteps(t *testing.T) {
+	for _, c := range []struct {
+		min      uint64
+		max      uint64
+		eta      float64
+		expected []uint64
+	}{
+		{1, 81, 3, []uint64{1, 3, 9, 27, 81}},
+		{1, 100, 3, []uint64{1, 4, 11, 33, 100}},
+		{5, 100, 3, []uint64{5, 11, 33, 100}},
+		{10, 10, 2, []uint64{10}},
+	} {
+		steps, err := NewGeometricSteps(c.min, c.max, c.eta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(steps.AsSlice(), c.expected) {
+			t.Fatalf("unexpected steps: %v", steps.AsSlice())
+		}
+	}
+
+	if _, err := NewGeometricSteps(0, 10, 2); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := NewGeometricSteps(1, 10, 1); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestStepsArithmetic(t *testing.T) {
+	sequential, _ := NewSteps([]uint64{1, 2, 3, 4})
+	sparse, _ := NewSteps([]uint64{2, 5, 10})
+
+	for _, c := range []struct {
+		steps    *Steps
+		step     uint64
+		index    int
+		contains bool
+		next     uint64
+		hasNext  bool
+		rung     int
+	}{
+		{sequential, 0, 0, false, 1, true, -1},
+		{sequential, 1, 0, true, 2, true, 0},
+		{sequential, 4, 3, true, 0, false, 3},
+		{sequential, 7, 0, false, 0, false, 3},
+		{sparse, 1, 0, false, 2, true, -1},
+		{sparse, 2, 0, true, 5, true, 0},
+		{sparse, 7, 0, false, 10, true, 1},
+		{sparse, 10, 2, true, 0, false, 2},
+	} {
+		index, ok := c.steps.Index(c.step)
+		if ok != c.contains || (ok && index != c.index) || c.steps.Contains(c.step) != c.contains {
+			t.Fatalf("unexpected index of %v: %v, %v", c.step, index, ok)
+		}
+
+		next, ok := c.steps.Next(c.step)
+		if ok != c.hasNext || next != c.next {
+			t.Fatalf("unexpected next of %v: %v, %v", c.step, next, ok)
+		}
+
+		if rung := c.steps.Rung(c.step); rung != c.rung {
+			t.Fatalf("unexpected rung of %v: %v", c.step, rung)
+		}
+	}
+
+	if sequential.First() != 1 || sparse.First() != 2 || sequential.Len() != 4 || sparse.Len() != 3 {
+		t.Fatal("unexpected first step or length")
+	}
+	if sparse.Fidelity(5) != 0.5 {
+		t.Fatalf("unexpected fidelity: %v", sparse.Fidelity(5))
+	}
+}
+
+func TestNewStepsNotStartingAtOne(t *testing.T) {
+	// A single step other than 1 isn't the sequence 1, 2, ..., N.
+	single, err := NewSteps([]uint64{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(single.AsSlice(), []uint64{5}) || single.Len() != 1 || single.First() != 5 {
+		t.Fatalf("unexpected steps: %v", single.AsSlice())
+	}
+	if i, ok := single.Index(5); !ok || i != 0 {
+		t.Fatalf("unexpected index: %v (ok=%v)", i, ok)
+	}
+	if single.Contains(1) {
+		t.Fatal("unexpected step 1")
+	}
+	if bytes, err := json.Marshal(single); err != nil || string(bytes) != "[5]" {
+		t.Fatalf("unexpected JSON: %s (err=%v)", bytes, err)
+	}
+
+	consecutive, _ := NewSteps([]uint64{3, 4, 5})
+	if !reflect.DeepEqual(consecutive.AsSlice(), []uint64{3, 4, 5}) {
+		t.Fatalf("unexpected steps: %v", consecutive.AsSlice())
+	}
+
+	sequential, _ := NewSteps([]uint64{1, 2, 3})
+	if bytes, err := json.Marshal(sequential); err != nil || string(bytes) != "3" {
+		t.Fatalf("unexpected JSON: %s (err=%v)", bytes, err)
+	}
+}
@@ -0,0 +1,364 @@
+package kurobako
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VarsFromStruct derives a parameters domain from the `kurobako` tags of the fields of the given struct.
+//
+// The v argument should be a struct or a pointer to a struct.
+// Each tag consists of a parameter name (the field name is used if it is empty) followed by comma-separated options:
+//
+//   - `range=LOW:HIGH` sets the range of a numerical parameter (LOW is inclusive and HIGH is exclusive).
+//   - `log` indicates that the parameter is log-uniformally distributed.
+//   - `choices=A|B|C` sets the choices of a categorical parameter.
+//   - `when=NAME OP VALUE` makes the parameter conditional (OP is one of `==`, `!=`, `<`, `<=`, `>` or `>=`).
+//     NAME should refer to a preceding parameter. If multiple `when` options are given, all of them should be satisfied.
+//
+// Float fields become continuous parameters, integer fields become discrete parameters and
+// string or bool fields become categorical parameters.
+// Pointer fields are set to nil by DecodeParams if the corresponding parameters are inactive.
+// Fields tagged with `kurobako:"-"` or without `kurobako` tags are ignored.
+//
+// For example:
+//
+//	type params struct {
+//	    LR       float64  `kurobako:"lr,range=1e-5:1e-1,log"`
+//	    Opt      string   `kurobako:"opt,choices=sgd|adam"`
+//	    Momentum *float64 `kurobako:"momentum,range=0:1,when=opt == sgd"`
+//	}
+func VarsFromStruct(v interface{}) ([]Var, error) {
+	fields, err := structFieldsOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]Var, 0, len(fields))
+	for _, f := range fields {
+		vars = append(vars, f.v)
+	}
+	return vars, nil
+}
+
+// DecodeParams stores the given parameter values into the struct pointed to by v.
+//
+// The layout of the values should be the same as the result of VarsFromStruct(v).
+// A nil element in the values argument means that the corresponding parameter is inactive.
+func DecodeParams(values []*float64, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a non-nil pointer to a struct, got %T", v)
+	}
+
+	fields, err := structFieldsOf(v)
+	if err != nil {
+		return err
+	}
+	if len(fields) != len(values) {
+		return fmt.Errorf("expected %d params, got %d", len(fields), len(values))
+	}
+
+	s := rv.Elem()
+	for i, f := range fields {
+		if err := f.decode(s.Field(f.index), values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode stores the parameter values into the struct pointed to by v.
+//
+// See DecodeParams for the details.
+func (r *Params) Decode(v interface{}) error {
+	vars, err := VarsFromStruct(v)
+	if err != nil {
+		return err
+	}
+
+	values := make([]*float64, len(vars))
+	for i, x := range vars {
+		j, ok := r.indices[x.Name]
+		if !ok {
+			return fmt.Errorf("unknown param: %q", x.Name)
+		}
+		values[i] = r.values[j]
+	}
+	return DecodeParams(values, v)
+}
+
+type structField struct {
+	index int
+	v     Var
+}
+
+func (r *structField) decode(field reflect.Value, value *float64) error {
+	if field.Kind() == reflect.Ptr {
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	} else if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	x := *value
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if x != math.Trunc(x) || math.IsInf(x, 0) {
+			return fmt.Errorf("param %q has a non-integral value: %v", r.v.Name, x)
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x < -(1<<63) || x >= 1<<63 || field.OverflowInt(int64(x)) {
+			return fmt.Errorf("param %q overflows %v: %v", r.v.Name, field.Type(), x)
+		}
+		field.SetInt(int64(x))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if x < 0 || x >= 1<<64 || field.OverflowUint(uint64(x)) {
+			return fmt.Errorf("param %q overflows %v: %v", r.v.Name, field.Type(), x)
+		}
+		field.SetUint(uint64(x))
+	case reflect.String, reflect.Bool:
+		choices := r.v.Range.AsCategoricalRange().Choices
+		index := int(x)
+		if index < 0 || index >= len(choices) {
+			return fmt.Errorf("param %q has an out of range choice index: %v", r.v.Name, x)
+		}
+		if field.Kind() == reflect.Bool {
+			field.SetBool(choices[index] == "true")
+		} else {
+			field.SetString(choices[index])
+		}
+	}
+	return nil
+}
+
+var structFieldsCache sync.Map
+
+func structFieldsOf(v interface{}) ([]structField, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %T", v)
+	}
+
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField), nil
+	}
+
+	fields, err := parseStructFields(t)
+	if err != nil {
+		return nil, err
+	}
+	structFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseStructFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	var vars []Var
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("kurobako")
+		if !ok || tag == "-" {
+			continue
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("field %s.%s is tagged but unexported", t.Name(), f.Name)
+		}
+
+		v, err := parseStructTag(f, tag, vars)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", t.Name(), f.Name, err)
+		}
+		for _, x := range vars {
+			if x.Name == v.Name {
+				return nil, fmt.Errorf("field %s.%s: duplicate param name %q", t.Name(), f.Name, v.Name)
+			}
+		}
+
+		fields = append(fields, structField{i, v})
+		vars = append(vars, v)
+	}
+	return fields, nil
+}
+
+func parseStructTag(f reflect.StructField, tag string, preceding []Var) (Var, error) {
+	items := strings.Split(tag, ",")
+	name := strings.TrimSpace(items[0])
+	if name == "" {
+		name = f.Name
+	}
+	v := NewVar(name)
+
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var low, high *float64
+	var choices []string
+	var cond *Condition
+	for _, item := range items[1:] {
+		item = strings.TrimSpace(item)
+		kv := strings.SplitN(item, "=", 2)
+		switch {
+		case item == "log":
+			v.Distribution = LogUniform
+		case kv[0] == "range" && len(kv) == 2:
+			bounds := strings.SplitN(kv[1], ":", 2)
+			if len(bounds) != 2 {
+				return v, fmt.Errorf("malformed range: %q", kv[1])
+			}
+			l, err := strconv.ParseFloat(strings.TrimSpace(bounds[0]), 64)
+			if err != nil {
+				return v, fmt.Errorf("malformed range: %q", kv[1])
+			}
+			h, err := strconv.ParseFloat(strings.TrimSpace(bounds[1]), 64)
+			if err != nil {
+				return v, fmt.Errorf("malformed range: %q", kv[1])
+			}
+			low, high = &l, &h
+		case kv[0] == "choices" && len(kv) == 2:
+			choices = strings.Split(kv[1], "|")
+		case kv[0] == "when" && len(kv) == 2:
+			c, err := parseWhenClause(kv[1], preceding)
+			if err != nil {
+				return v, err
+			}
+			if cond != nil {
+				c = cond.And(c)
+			}
+			cond = &c
+		default:
+			return v, fmt.Errorf("unknown option: %q", item)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if choices != nil {
+			return v, fmt.Errorf("choices can't be specified for a float field")
+		}
+		if low != nil {
+			v.Range = ContinuousRange{*low, *high}.ToRange()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if choices != nil {
+			return v, fmt.Errorf("choices can't be specified for an integer field")
+		}
+		if low == nil {
+			return v, fmt.Errorf("range is required for an integer field")
+		}
+		if *low != math.Trunc(*low) || *high != math.Trunc(*high) {
+			return v, fmt.Errorf("range of an integer field should consist of integers")
+		}
+		if isUnsignedKind(t.Kind()) && *low < 0 {
+			return v, fmt.Errorf("range of an unsigned integer field should be non-negative")
+		}
+		v.Range = DiscreteRange{int64(*low), int64(*high)}.ToRange()
+	case reflect.String:
+		if low != nil {
+			return v, fmt.Errorf("range can't be specified for a string field")
+		}
+		if choices == nil {
+			return v, fmt.Errorf("choices are required for a string field")
+		}
+		v.Range = CategoricalRange{choices}.ToRange()
+	case reflect.Bool:
+		if low != nil || choices != nil {
+			return v, fmt.Errorf("neither range nor choices can be specified for a bool field")
+		}
+		v.Range = CategoricalRange{[]string{"false", "true"}}.ToRange()
+	default:
+		return v, fmt.Errorf("unsupported field type: %v", f.Type)
+	}
+
+	if v.Range.Low() >= v.Range.High() {
+		return v, fmt.Errorf("empty range: %v:%v", v.Range.Low(), v.Range.High())
+	}
+	if v.Distribution == LogUniform && (v.Range.AsCategoricalRange() != nil || v.Range.Low() <= 0.0) {
+		return v, fmt.Errorf("log can only be specified for a numerical parameter that has a positive range")
+	}
+
+	if cond != nil {
+		constraint, err := cond.Lua()
+		if err != nil {
+			return v, err
+		}
+		v.Constraint = &constraint
+	}
+	return v, nil
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+var whenOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseWhenClause(clause string, preceding []Var) (Condition, error) {
+	for _, op := range whenOperators {
+		i := strings.Index(clause, op)
+		if i < 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(clause[:i])
+		text := strings.TrimSpace(clause[i+len(op):])
+
+		var value interface{} = text
+		for _, v := range preceding {
+			if v.Name == name && v.Range.AsCategoricalRange() == nil {
+				n, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return Condition{}, fmt.Errorf("when clause %q has a non-numerical value", clause)
+				}
+				value = n
+			}
+		}
+
+		var cond Condition
+		switch op {
+		case "==":
+			cond = When(name).Eq(value)
+		case "!=":
+			cond = When(name).Ne(value)
+		case "<=":
+			cond = When(name).Le(value)
+		case ">=":
+			cond = When(name).Ge(value)
+		case "<":
+			cond = When(name).Lt(value)
+		case ">":
+			cond = When(name).Gt(value)
+		}
+
+		if err := cond.check(preceding); err != nil {
+			return Condition{}, fmt.Errorf("when clause %q: %v", clause, err)
+		}
+		return cond, nil
+	}
+	return Condition{}, fmt.Errorf("malformed when clause: %q", clause)
+}
@@ -0,0 +1,118 @@
+package kurobako
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type taggedParams struct {
+	LR       float64  `kurobako:"lr,range=1e-5:1e-1,log"`
+	Opt      string   `kurobako:"opt,choices=sgd|adam"`
+	Momentum *float64 `kurobako:"momentum,range=0:1,when=opt == sgd"`
+	Layers   int      `kurobako:",range=1:5"`
+	Dropout  bool     `kurobako:"dropout,when=Layers >= 3"`
+	Ignored  string
+}
+
+func TestVarsFromStruct(t *testing.T) {
+	vars, err := VarsFromStruct(taggedParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"lr", "opt", "momentum", "Layers", "dropout"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	if vars[0].Distribution != LogUniform || vars[0].Range.Low() != 1e-5 || vars[0].Range.High() != 1e-1 {
+		t.Fatalf("unexpected lr: %v", vars[0])
+	}
+	if !reflect.DeepEqual(vars[1].Range, CategoricalRange{[]string{"sgd", "adam"}}.ToRange()) {
+		t.Fatalf("unexpected opt: %v", vars[1])
+	}
+	if !reflect.DeepEqual(vars[3].Range, DiscreteRange{1, 5}.ToRange()) {
+		t.Fatalf("unexpected Layers: %v", vars[3])
+	}
+
+	adam := 1.0
+	sgd := 0.0
+	for _, c := range []struct {
+		opt       *float64
+		satisfied bool
+	}{{&sgd, true}, {&adam, false}} {
+		ok, err := vars[2].IsConstraintSatisfied(vars, []*float64{nil, c.opt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.satisfied {
+			t.Fatalf("unexpected constraint result: %v", ok)
+		}
+	}
+
+	ok, err := vars[4].IsConstraintSatisfied(vars, []*float64{nil, nil, nil, nil})
+	if err != nil || ok {
+		t.Fatalf("unexpected constraint result: %v (err=%v)", ok, err)
+	}
+
+	for _, v := range []interface{}{
+		struct {
+			X int `kurobako:"x"`
+		}{},
+		struct {
+			X string `kurobako:"x,range=0:1"`
+		}{},
+		struct {
+			X float64 `kurobako:"x,when=y == 1"`
+		}{},
+		struct {
+			X float64 `kurobako:"x,range=0:1,log"`
+		}{},
+		struct {
+			X uint `kurobako:"x,range=-1:3"`
+		}{},
+	} {
+		if _, err := VarsFromStruct(v); err == nil {
+			t.Fatalf("expected an error: %T", v)
+		}
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	lr := 0.01
+	opt := 1.0
+	layers := 3.0
+	dropout := 1.0
+
+	var p taggedParams
+	if err := DecodeParams([]*float64{&lr, &opt, nil, &layers, &dropout}, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := taggedParams{LR: 0.01, Opt: "adam", Momentum: nil, Layers: 3, Dropout: true}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("unexpected params: %v", p)
+	}
+
+	if err := DecodeParams([]*float64{&lr}, &p); err == nil {
+		t.Fatal("expected a length mismatch error")
+	}
+
+	fraction := 2.5
+	if err := DecodeParams([]*float64{&lr, &opt, nil, &fraction, &dropout}, &p); err == nil {
+		t.Fatal("expected a non-integral value error")
+	}
+
+	var u struct {
+		X uint8 `kurobako:"x,range=0:300"`
+	}
+	for _, x := range []float64{1.5, -1, 256, math.Inf(0)} {
+		if err := DecodeParams([]*float64{&x}, &u); err == nil {
+			t.Fatalf("expected an error: %v", x)
+		}
+	}
+}
@@ -3,8 +3,6 @@ package kurobako
 import (
 	"fmt"
 	"math"
-
-	lua "github.com/yuin/gopher-lua"
 )
 
 // Var is a definition of a variable.
@@ -22,6 +20,7 @@ type Var struct {
 	//
 	// A constraint is represented by a Lua script.
 	// If the script returns true, it means the constraint is satisfied.
+	// Like kurobako itself, a script consisting of a single expression (e.g., `opt == "adam"`) is also accepted.
 	//
 	// If the constraint isn't satisfied, the variable won't be considered during the evaluation process.
 	Constraint *string `json:"constraint"`
@@ -38,38 +37,102 @@ func NewVar(name string) Var {
 }
 
 // IsConstraintSatisfied checks whether the constraint of the variable is satisfied under the given bound (i.e., already evaluated) variables.
+//
+// The compiled constraint script is cached, so calling this method repeatedly with the same constraint is cheap.
 func (r Var) IsConstraintSatisfied(vars []Var, vals []*float64) (bool, error) {
 	if r.Constraint == nil {
 		return true, nil
 	}
 
-	luaState := lua.NewState()
-	defer luaState.Close()
+	script, err := DefaultConstraintEvaluator().Compile(*r.Constraint)
+	if err != nil {
+		return false, err
+	}
 
-	for i := 0; i < len(vars) && i < len(vals); i++ {
-		if vals[i] == nil {
-			// This is a conditional variable and has n't been bound a value.
-			continue
+	return script.Eval(func(name string) (interface{}, error) {
+		// If there are multiple variables that have the same name, the last one takes precedence.
+		for i := len(vars) - 1; i >= 0; i-- {
+			if vars[i].Name == name && i < len(vals) {
+				return constraintValue(vars[i], vals[i])
+			}
 		}
+		return nil, nil
+	})
+}
+
+// FromUnit maps a value in the unit interval [0, 1) to a value in the range of the variable.
+//
+// The mapping follows the distribution of the variable (i.e., it is log-scaled for LogUniform).
+// For discrete and categorical ranges, the interval is divided into cells of equal width and
+// each cell is mapped to a value (or a choice index).
+// The range of the variable must be bounded.
+func (r Var) FromUnit(u float64) float64 {
+	if !(u >= 0) {
+		u = 0
+	} else if u >= 1 {
+		u = math.Nextafter(1, 0)
+	}
+
+	low := r.Range.Low()
+	high := r.Range.High()
+	isLog := r.Distribution == LogUniform && r.Range.AsCategoricalRange() == nil
+
+	var x float64
+	if isLog {
+		x = math.Exp(math.Log(low) + u*(math.Log(high)-math.Log(low)))
+	} else {
+		x = low + u*(high-low)
+	}
 
-		if x := vars[i].Range.AsContinuousRange(); x != nil {
-			luaState.SetGlobal(vars[i].Name, lua.LNumber(*vals[i]))
-		} else if x := vars[i].Range.AsDiscreteRange(); x != nil {
-			luaState.SetGlobal(vars[i].Name, lua.LNumber(int(*vals[i])))
-		} else if x := vars[i].Range.AsCategoricalRange(); x != nil {
-			index := int(*vals[i])
-			luaState.SetGlobal(vars[i].Name, lua.LString(x.Choices[index]))
+	if r.Range.AsContinuousRange() != nil {
+		if x >= high {
+			x = math.Nextafter(high, low)
 		}
+		return math.Max(x, low)
 	}
+	return math.Max(math.Min(math.Floor(x), high-1), low)
+}
 
-	if err := luaState.DoString(*r.Constraint); err != nil {
-		return false, err
+// ToUnit maps a value in the range of the variable to the unit interval [0, 1].
+//
+// This is the inverse of FromUnit. Discrete values and choice indices are mapped to the centers of their cells.
+func (r Var) ToUnit(value float64) float64 {
+	low := r.Range.Low()
+	high := r.Range.High()
+	isLog := r.Distribution == LogUniform && r.Range.AsCategoricalRange() == nil
+
+	var u float64
+	switch {
+	case r.Range.AsContinuousRange() != nil && isLog:
+		u = (math.Log(value) - math.Log(low)) / (math.Log(high) - math.Log(low))
+	case r.Range.AsContinuousRange() != nil:
+		u = (value - low) / (high - low)
+	case isLog:
+		center := (math.Log(value) + math.Log(value+1)) / 2
+		u = (center - math.Log(low)) / (math.Log(high) - math.Log(low))
+	default:
+		u = (value - low + 0.5) / (high - low)
+	}
+	return math.Max(math.Min(u, 1), 0)
+}
+
+// constraintValue converts the value of the given variable to the representation used in constraint scripts.
+//
+// The result is nil (unbound), a float64 (numerical variable) or a string (categorical variable).
+func constraintValue(v Var, value *float64) (interface{}, error) {
+	if value == nil {
+		// This is a conditional variable and hasn't been bound a value.
+		return nil, nil
 	}
 
-	value := luaState.Get(-1)
-	satisfied, ok := value.(lua.LBool)
-	if !ok {
-		return false, fmt.Errorf("expected a lua bool value, got %v", value)
+	if x := v.Range.AsDiscreteRange(); x != nil {
+		return float64(int(*value)), nil
+	} else if x := v.Range.AsCategoricalRange(); x != nil {
+		index := int(*value)
+		if index < 0 || index >= len(x.Choices) {
+			return nil, fmt.Errorf("param %q has an out of range choice index: %v", v.Name, *value)
+		}
+		return x.Choices[index], nil
 	}
-	return bool(satisfied), nil
+	return *value, nil
 }
@@ -0,0 +1,63 @@
+package kurobako
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVarUnitMapping(t *testing.T) {
+	continuous := NewVar("x")
+	continuous.Range = ContinuousRange{-1.0, 3.0}.ToRange()
+
+	logContinuous := NewVar("lr")
+	logContinuous.Range = ContinuousRange{1e-4, 1.0}.ToRange()
+	logContinuous.Distribution = LogUniform
+
+	discrete := NewVar("n")
+	discrete.Range = DiscreteRange{-2, 3}.ToRange()
+
+	logDiscrete := NewVar("units")
+	logDiscrete.Range = DiscreteRange{1, 1000}.ToRange()
+	logDiscrete.Distribution = LogUniform
+
+	categorical := NewVar("c")
+	categorical.Range = CategoricalRange{[]string{"a", "b", "c"}}.ToRange()
+
+	for _, c := range []struct {
+		v        Var
+		u        float64
+		expected float64
+	}{
+		{continuous, 0.0, -1.0},
+		{continuous, 0.5, 1.0},
+		{logContinuous, 0.5, 1e-2},
+		{discrete, 0.0, -2},
+		{discrete, 0.99, 2},
+		{logDiscrete, 0.5, 31},
+		{categorical, 0.5, 1},
+		{categorical, 1.5, 2},
+		{categorical, -1.0, 0},
+	} {
+		actual := c.v.FromUnit(c.u)
+		if math.Abs(actual-c.expected) > 1e-9 {
+			t.Fatalf("%q: FromUnit(%v) = %v, expected %v", c.v.Name, c.u, actual, c.expected)
+		}
+	}
+
+	for _, v := range []Var{continuous, logContinuous, discrete, logDiscrete, categorical} {
+		if x := v.FromUnit(1.0); !v.Range.Contains(x) {
+			t.Fatalf("%q: FromUnit(1.0) = %v is out of the range", v.Name, x)
+		}
+
+		for i := 0; i < 100; i++ {
+			u := float64(i) / 100
+			x := v.FromUnit(u)
+			if !v.Range.Contains(x) {
+				t.Fatalf("%q: FromUnit(%v) = %v is out of the range", v.Name, u, x)
+			}
+			if y := v.FromUnit(v.ToUnit(x)); math.Abs(x-y) > 1e-9*math.Max(1, math.Abs(x)) {
+				t.Fatalf("%q: round trip of %v failed: %v", v.Name, x, y)
+			}
+		}
+	}
+}